@@ -0,0 +1,95 @@
+package asciicast
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"time"
+)
+
+// Version is the asciicast format version this package reads and writes.
+const Version = 2
+
+// EventType identifies the kind of an event in a recording.
+type EventType string
+
+const (
+	EventOutput EventType = "o" // Data printed by the program.
+	EventInput  EventType = "i" // Data typed into the program.
+	EventResize EventType = "r" // A window size change, as "COLSxROWS".
+	EventMarker EventType = "m" // A labelled point of interest.
+)
+
+// Header is the first line of an asciicast v2 file.
+type Header struct {
+	Version       int               `json:"version"`
+	Width         int               `json:"width"`
+	Height        int               `json:"height"`
+	Timestamp     int64             `json:"timestamp,omitempty"`       // Unix time the recording started.
+	Duration      float64           `json:"duration,omitempty"`        // Total length in seconds, if known.
+	IdleTimeLimit float64           `json:"idle_time_limit,omitempty"` // Suggested cap on pauses during playback.
+	Command       string            `json:"command,omitempty"`
+	Title         string            `json:"title,omitempty"`
+	Env           map[string]string `json:"env,omitempty"` // Typically SHELL and TERM.
+}
+
+// Event is a single timed entry of a recording.
+type Event struct {
+	Time float64 // Seconds since the start of the recording.
+	Type EventType
+	Data string
+}
+
+// MarshalJSON encodes the event as asciicast's [time, type, data] array.
+func (e Event) MarshalJSON() ([]byte, error) {
+	// Microsecond precision, as written by asciinema itself.
+	t := strconv.FormatFloat(e.Time, 'f', 6, 64)
+	data, err := json.Marshal([]string{string(e.Type), e.Data})
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte("["+t+","), data[1:]...), nil
+}
+
+// UnmarshalJSON decodes an asciicast [time, type, data] array.
+func (e *Event) UnmarshalJSON(b []byte) error {
+	var raw []json.RawMessage
+	if err := json.Unmarshal(b, &raw); err != nil {
+		return err
+	}
+	if len(raw) != 3 {
+		return fmt.Errorf("event has %d fields, want 3", len(raw))
+	}
+	var typ string
+	if err := json.Unmarshal(raw[0], &e.Time); err != nil {
+		return fmt.Errorf("invalid event time: %w", err)
+	}
+	if err := json.Unmarshal(raw[1], &typ); err != nil {
+		return fmt.Errorf("invalid event type: %w", err)
+	}
+	if err := json.Unmarshal(raw[2], &e.Data); err != nil {
+		return fmt.Errorf("invalid event data: %w", err)
+	}
+	e.Type = EventType(typ)
+	return nil
+}
+
+// Offset returns the event's time as a duration since the start of the recording.
+func (e Event) Offset() time.Duration {
+	return time.Duration(e.Time * float64(time.Second))
+}
+
+// ParseSize decodes the "COLSxROWS" data of a resize event.
+func (e Event) ParseSize() (rows, cols uint16, err error) {
+	var c, r uint16
+	if _, err := fmt.Sscanf(e.Data, "%dx%d", &c, &r); err != nil {
+		return 0, 0, fmt.Errorf("invalid resize event %q: %w", e.Data, err)
+	}
+	return r, c, nil
+}
+
+// Cast is a decoded recording.
+type Cast struct {
+	Header Header
+	Events []Event
+}
@@ -0,0 +1,113 @@
+package asciicast
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+)
+
+// Decode reads a complete asciicast v2 recording.
+func Decode(r io.Reader) (*Cast, error) {
+	dec := json.NewDecoder(r)
+	var cast Cast
+	if err := dec.Decode(&cast.Header); err != nil {
+		return nil, fmt.Errorf("failed to read asciicast header: %w", err)
+	}
+	if cast.Header.Version != Version {
+		return nil, fmt.Errorf("unsupported asciicast version %d", cast.Header.Version)
+	}
+	for {
+		var e Event
+		err := dec.Decode(&e)
+		if errors.Is(err, io.EOF) {
+			return &cast, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read asciicast event %d: %w", len(cast.Events)+1, err)
+		}
+		cast.Events = append(cast.Events, e)
+	}
+}
+
+// Resizer is implemented by playback targets that track the terminal size,
+// such as shell.ReplayFeed.
+type Resizer interface {
+	Resize(rows, cols uint16) error
+}
+
+// PlayOptions controls the timing of Play.
+type PlayOptions struct {
+	Speed   float64       // Playback speed multiplier; 0 means real time, 2 twice as fast.
+	MaxIdle time.Duration // Caps pauses between events; 0 uses the header's idle_time_limit, if any.
+}
+
+// Play replays the output of a recording into w, honoring the recorded
+// timing scaled by opts. If w is a Resizer it is sized from the header and
+// receives every resize event. Input and marker events are skipped. Play
+// returns early with the context's error if ctx is done.
+func Play(ctx context.Context, cast *Cast, w io.Writer, opts PlayOptions) error {
+	speed := opts.Speed
+	if speed <= 0 {
+		speed = 1
+	}
+	maxIdle := opts.MaxIdle
+	if maxIdle == 0 && cast.Header.IdleTimeLimit > 0 {
+		maxIdle = time.Duration(cast.Header.IdleTimeLimit * float64(time.Second))
+	}
+
+	resizer, _ := w.(Resizer)
+	if resizer != nil && cast.Header.Width > 0 && cast.Header.Height > 0 {
+		if err := resizer.Resize(uint16(cast.Header.Height), uint16(cast.Header.Width)); err != nil {
+			return fmt.Errorf("failed to size playback target: %w", err)
+		}
+	}
+
+	start := time.Now()
+	var elapsed, prev time.Duration
+	timer := time.NewTimer(0)
+	defer timer.Stop()
+
+	for _, e := range cast.Events {
+		gap := e.Offset() - prev
+		prev = e.Offset()
+		if maxIdle > 0 && gap > maxIdle {
+			gap = maxIdle
+		}
+		elapsed += time.Duration(float64(gap) / speed)
+
+		// Sleep until the event is due, measured from the start so that
+		// rounding and write time do not accumulate.
+		if wait := time.Until(start.Add(elapsed)); wait > 0 {
+			timer.Reset(wait)
+			select {
+			case <-timer.C:
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		} else if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		switch e.Type {
+		case EventOutput:
+			if _, err := io.WriteString(w, e.Data); err != nil {
+				return fmt.Errorf("failed to write playback output: %w", err)
+			}
+		case EventResize:
+			if resizer == nil {
+				continue
+			}
+			rows, cols, err := e.ParseSize()
+			if err != nil {
+				return err
+			}
+			if err := resizer.Resize(rows, cols); err != nil {
+				return fmt.Errorf("failed to resize playback target: %w", err)
+			}
+		}
+	}
+	return nil
+}
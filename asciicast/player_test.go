@@ -0,0 +1,73 @@
+package asciicast_test
+
+import (
+	"context"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/owen-6936/termplex/asciicast"
+	"github.com/owen-6936/termplex/assert"
+	"github.com/owen-6936/termplex/shell"
+)
+
+func TestPlayScalesTimingAndCapsIdle(t *testing.T) {
+	cast := &asciicast.Cast{
+		Header: asciicast.Header{Version: 2, Width: 80, Height: 24},
+		Events: []asciicast.Event{
+			{Time: 0, Type: asciicast.EventOutput, Data: "one "},
+			{Time: 0.4, Type: asciicast.EventOutput, Data: "two "},
+			{Time: 60, Type: asciicast.EventOutput, Data: "three"},
+		},
+	}
+
+	// 1. Four times faster, with the minute-long pause capped at one second
+	// of recording time: 0.4s/4 + 1s/4 of playback.
+	var out strings.Builder
+	started := time.Now()
+	err := asciicast.Play(context.Background(), cast, &out, asciicast.PlayOptions{Speed: 4, MaxIdle: time.Second})
+	assert.NoError(t, err)
+	elapsed := time.Since(started)
+	assert.True(t, out.String() == "one two three", "Unexpected playback %q", out.String())
+	assert.True(t, elapsed >= 350*time.Millisecond && elapsed < 2*time.Second, "Expected about 350ms of playback, took %v", elapsed)
+
+	// 2. Cancellation stops playback between events.
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	err = asciicast.Play(ctx, cast, &strings.Builder{}, asciicast.PlayOptions{})
+	assert.True(t, err == context.DeadlineExceeded, "Expected the deadline to stop playback, got %v", err)
+}
+
+func TestPlayIntoReplaySession(t *testing.T) {
+	cast := &asciicast.Cast{
+		Header: asciicast.Header{Version: 2, Width: 40, Height: 10},
+		Events: []asciicast.Event{
+			{Time: 0, Type: asciicast.EventOutput, Data: "$ "},
+			{Time: 0.01, Type: asciicast.EventInput, Data: "make\r"},
+			{Time: 0.02, Type: asciicast.EventResize, Data: "60x12"},
+			{Time: 0.03, Type: asciicast.EventOutput, Data: "make\r\n\x1b[32mbuild ok\x1b[0m\r\n$ "},
+		},
+	}
+
+	// 1. Replay the recording into a fake shell.
+	session, feed := shell.NewReplaySession(shell.WindowSize{})
+	assert.NoError(t, asciicast.Play(context.Background(), cast, feed, asciicast.PlayOptions{Speed: 100}))
+	assert.NoError(t, feed.Close())
+
+	// 2. The fake shell sees the output, the screen and the final size.
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	match, err := session.Expect(ctx, regexp.MustCompile(`build (\w+)`))
+	assert.NoError(t, err)
+	assert.True(t, match.Groups[0] == "ok", "Unexpected match %+v", match)
+
+	snap, err := session.Screen()
+	assert.NoError(t, err)
+	assert.True(t, snap.Rows == 12 && snap.Cols == 60, "Expected the resize event to apply, got %dx%d", snap.Rows, snap.Cols)
+	assert.True(t, snap.Text() == "$ make\nbuild ok\n$", "Unexpected screen %q", snap.Text())
+
+	// 3. Once the feed is closed, waiting for more output reports EOF.
+	_, err = session.Expect(ctx, regexp.MustCompile(`never`))
+	assert.True(t, err != nil && strings.Contains(err.Error(), "EOF"), "Expected EOF, got %v", err)
+}
@@ -0,0 +1,139 @@
+package asciicast
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+// ErrClosed is returned by a Recorder after Close.
+var ErrClosed = errors.New("asciicast: recorder closed")
+
+// Recorder writes an asciicast v2 stream: a header line followed by one JSON
+// event per line. It satisfies shell.Tap, so it can be attached to a shell
+// directly. It is safe for concurrent use.
+type Recorder struct {
+	mu      sync.Mutex
+	enc     *json.Encoder
+	start   time.Time
+	last    float64              // Time of the last event, so times never go backwards.
+	pending map[EventType][]byte // Incomplete UTF-8 sequences held back per stream.
+	closed  bool
+}
+
+// NewRecorder writes header to w and returns a Recorder for the events that
+// follow. The version is always set to 2, and a zero Timestamp is filled in
+// with the current time.
+func NewRecorder(w io.Writer, header Header) (*Recorder, error) {
+	start := time.Now()
+	header.Version = Version
+	if header.Timestamp == 0 {
+		header.Timestamp = start.Unix()
+	}
+
+	enc := json.NewEncoder(w)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(header); err != nil {
+		return nil, fmt.Errorf("failed to write asciicast header: %w", err)
+	}
+	return &Recorder{enc: enc, start: start, pending: make(map[EventType][]byte)}, nil
+}
+
+// Output records data printed by the program.
+func (r *Recorder) Output(data []byte) error {
+	return r.Record(time.Now(), EventOutput, data)
+}
+
+// Input records data typed into the program.
+func (r *Recorder) Input(data []byte) error {
+	return r.Record(time.Now(), EventInput, data)
+}
+
+// Resize records a change of the terminal size.
+func (r *Recorder) Resize(rows, cols uint16) error {
+	return r.Record(time.Now(), EventResize, fmt.Appendf(nil, "%dx%d", cols, rows))
+}
+
+// Marker records a labelled point of interest, such as the start of a step.
+func (r *Recorder) Marker(label string) error {
+	return r.Record(time.Now(), EventMarker, []byte(label))
+}
+
+// Record writes an event that happened at the given time, such as the
+// Timestamp of a pane.PaneOutput. Times before the previous event are
+// clamped so the stream stays ordered. A UTF-8 sequence split across calls
+// is held back until it is complete.
+func (r *Recorder) Record(at time.Time, typ EventType, data []byte) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.closed {
+		return ErrClosed
+	}
+
+	if p := r.pending[typ]; len(p) > 0 {
+		data = append(p, data...)
+		r.pending[typ] = nil
+	}
+	if typ == EventOutput || typ == EventInput {
+		if tail := incompleteSuffix(data); tail > 0 {
+			r.pending[typ] = append([]byte(nil), data[len(data)-tail:]...)
+			data = data[:len(data)-tail]
+		}
+		if len(data) == 0 {
+			return nil
+		}
+	}
+	return r.writeLocked(at, typ, data)
+}
+
+// writeLocked encodes a single event.
+func (r *Recorder) writeLocked(at time.Time, typ EventType, data []byte) error {
+	t := at.Sub(r.start).Seconds()
+	if t < r.last {
+		t = r.last
+	}
+	r.last = t
+	if err := r.enc.Encode(Event{Time: t, Type: typ, Data: string(data)}); err != nil {
+		return fmt.Errorf("failed to write asciicast event: %w", err)
+	}
+	return nil
+}
+
+// Close flushes any held-back bytes and stops recording. It does not close
+// the underlying writer.
+func (r *Recorder) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.closed {
+		return nil
+	}
+	r.closed = true
+
+	now := time.Now()
+	var errs []error
+	for _, typ := range []EventType{EventOutput, EventInput} {
+		if p := r.pending[typ]; len(p) > 0 {
+			errs = append(errs, r.writeLocked(now, typ, p))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// incompleteSuffix returns the length of a truncated UTF-8 sequence at the
+// end of p, or 0 if p ends on a rune boundary.
+func incompleteSuffix(p []byte) int {
+	for i := 1; i <= utf8.UTFMax-1 && i <= len(p); i++ {
+		b := p[len(p)-i]
+		if utf8.RuneStart(b) {
+			if !utf8.FullRune(p[len(p)-i:]) {
+				return i
+			}
+			return 0
+		}
+	}
+	return 0
+}
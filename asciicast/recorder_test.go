@@ -0,0 +1,94 @@
+package asciicast_test
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/owen-6936/termplex/asciicast"
+	"github.com/owen-6936/termplex/assert"
+	"github.com/owen-6936/termplex/shell"
+)
+
+func TestRecorderRoundTrip(t *testing.T) {
+	var buf bytes.Buffer
+	rec, err := asciicast.NewRecorder(&buf, asciicast.Header{Width: 80, Height: 24, Title: "demo"})
+	assert.NoError(t, err)
+
+	// 1. Record output, input and a resize; split a wide character across writes.
+	wide := []byte("日")
+	assert.NoError(t, rec.Output([]byte("$ ")))
+	assert.NoError(t, rec.Input([]byte("ls\r")))
+	assert.NoError(t, rec.Output(wide[:1]))
+	assert.NoError(t, rec.Output(wide[1:]))
+	assert.NoError(t, rec.Resize(30, 100))
+	assert.NoError(t, rec.Close())
+	assert.True(t, rec.Output([]byte("late")) == asciicast.ErrClosed, "Expected ErrClosed after Close")
+
+	// 2. The first line is a v2 header and every event is a [time, type, data] array.
+	first, _, _ := strings.Cut(buf.String(), "\n")
+	assert.Contains(t, first, `"version":2`)
+	assert.Contains(t, buf.String(), `"o","$ "]`)
+
+	cast, err := asciicast.Decode(&buf)
+	assert.NoError(t, err)
+	assert.True(t, cast.Header.Width == 80 && cast.Header.Title == "demo", "Unexpected header %+v", cast.Header)
+	assert.True(t, cast.Header.Timestamp > 0, "Expected the timestamp to be filled in")
+
+	var types []string
+	for i, e := range cast.Events {
+		types = append(types, string(e.Type))
+		if i > 0 {
+			assert.True(t, e.Time >= cast.Events[i-1].Time, "Event times went backwards: %+v", cast.Events)
+		}
+	}
+	assert.True(t, strings.Join(types, "") == "oior", "Unexpected event types %v", types)
+	assert.True(t, cast.Events[2].Data == "日", "Expected the split character to be recorded whole, got %q", cast.Events[2].Data)
+
+	rows, cols, err := cast.Events[3].ParseSize()
+	assert.NoError(t, err)
+	assert.True(t, rows == 30 && cols == 100, "Unexpected resize %dx%d", rows, cols)
+}
+
+func TestRecorderClampsOutOfOrderTimestamps(t *testing.T) {
+	var buf bytes.Buffer
+	rec, err := asciicast.NewRecorder(&buf, asciicast.Header{Width: 80, Height: 24})
+	assert.NoError(t, err)
+
+	// Timestamps from a channel consumer may lag behind events recorded directly.
+	now := time.Now()
+	assert.NoError(t, rec.Record(now.Add(50*time.Millisecond), asciicast.EventOutput, []byte("a")))
+	assert.NoError(t, rec.Record(now, asciicast.EventOutput, []byte("b")))
+
+	cast, err := asciicast.Decode(&buf)
+	assert.NoError(t, err)
+	assert.True(t, cast.Events[1].Time == cast.Events[0].Time, "Expected the late event to be clamped, got %+v", cast.Events)
+}
+
+func TestRecordShellAppliesRedaction(t *testing.T) {
+	sm := shell.NewShellManager(nil)
+	t.Cleanup(func() { _ = sm.TerminateAllShells() })
+	session, err := sm.SpawnShellWithOptions(shell.SpawnOptions{
+		Interactive: true,
+		Command:     []string{"bash", "--norc", "--noprofile"},
+		Env:         map[string]string{"TOKEN": "s3cret-value"},
+		Filters:     []shell.Filter{shell.RedactValuesFilter("s3cret-value")},
+	})
+	assert.NoError(t, err)
+
+	var buf bytes.Buffer
+	stop, err := asciicast.RecordShell(session, &buf)
+	assert.NoError(t, err)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	_, err = session.Run(ctx, "echo $TOKEN")
+	assert.NoError(t, err)
+	assert.NoError(t, stop())
+
+	// The recording holds the filtered output, not the secret.
+	assert.True(t, !strings.Contains(buf.String(), "s3cret-value"), "The secret leaked into the recording: %q", buf.String())
+	assert.Contains(t, buf.String(), shell.Redacted)
+}
@@ -0,0 +1,42 @@
+package asciicast
+
+import (
+	"io"
+	"os"
+	"strings"
+
+	"github.com/owen-6936/termplex/shell"
+)
+
+// RecordShell starts recording s to w. The header is filled in from the
+// shell's terminal size, command line and TERM/SHELL environment. The
+// returned function stops the recording; it does not close w.
+func RecordShell(s *shell.ShellSession, w io.Writer) (stop func() error, err error) {
+	header := Header{Width: 80, Height: 24, Env: map[string]string{}}
+	if size, err := s.Size(); err == nil {
+		header.Width, header.Height = int(size.Cols), int(size.Rows)
+	}
+
+	env := os.Environ()
+	if s.Cmd != nil {
+		header.Command = strings.Join(s.Cmd.Args, " ")
+		if s.Cmd.Env != nil {
+			env = s.Cmd.Env
+		}
+	}
+	for _, kv := range env {
+		if k, v, ok := strings.Cut(kv, "="); ok && (k == "TERM" || k == "SHELL") {
+			header.Env[k] = v
+		}
+	}
+
+	rec, err := NewRecorder(w, header)
+	if err != nil {
+		return nil, err
+	}
+	remove := s.AddTap(rec)
+	return func() error {
+		remove()
+		return rec.Close()
+	}, nil
+}
@@ -0,0 +1,269 @@
+// Package cgroup manages cgroup v2 groups: creating them with memory, CPU and
+// process limits, moving processes into them and reading back their usage.
+package cgroup
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DefaultPeriod is the cpu.max period used to express CPU limits.
+const DefaultPeriod = 100 * time.Millisecond
+
+// Limits are the resource limits of a group. Zero values leave a limit
+// unchanged.
+type Limits struct {
+	MemoryMax int64   // Bytes of memory, written to memory.max.
+	CPUMax    float64 // CPUs the group may use, such as 0.5 or 2, written to cpu.max.
+	PidsMax   int64   // Number of processes, written to pids.max.
+}
+
+// controllers returns the controllers the limits need.
+func (l Limits) controllers() []string {
+	var names []string
+	if l.MemoryMax > 0 {
+		names = append(names, "memory")
+	}
+	if l.CPUMax > 0 {
+		names = append(names, "cpu")
+	}
+	if l.PidsMax > 0 {
+		names = append(names, "pids")
+	}
+	return names
+}
+
+// Group is a cgroup v2 directory.
+type Group struct {
+	path string
+}
+
+// Open returns the group at path, which must be a cgroup v2 directory.
+func Open(path string) (*Group, error) {
+	if _, err := os.Stat(filepath.Join(path, "cgroup.procs")); err != nil {
+		return nil, fmt.Errorf("%s is not a cgroup: %w", path, err)
+	}
+	return &Group{path: path}, nil
+}
+
+// Self returns the group of the calling process, found through the cgroup v2
+// mount point and /proc/self/cgroup.
+func Self() (*Group, error) {
+	root, err := mountPoint()
+	if err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile("/proc/self/cgroup")
+	if err != nil {
+		return nil, err
+	}
+	for _, line := range strings.Split(string(data), "\n") {
+		if rel, ok := strings.CutPrefix(line, "0::"); ok {
+			return Open(filepath.Join(root, rel))
+		}
+	}
+	return nil, errors.New("process is not in a cgroup v2 hierarchy")
+}
+
+// mountPoint returns where the cgroup v2 hierarchy is mounted.
+func mountPoint() (string, error) {
+	f, err := os.Open("/proc/self/mounts")
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) >= 3 && fields[2] == "cgroup2" {
+			return fields[1], nil
+		}
+	}
+	return "", errors.New("cgroup v2 is not mounted")
+}
+
+// Path returns the group's directory.
+func (g *Group) Path() string {
+	return g.path
+}
+
+// NewChild creates a group below g with the given limits, first enabling the
+// controllers they need for g's children. cgroup v2 only lets a group hand
+// out controllers while it has no processes of its own, so g is usually a
+// group created for the purpose rather than one a process runs in. An
+// existing child is reused.
+func (g *Group) NewChild(name string, limits Limits) (*Group, error) {
+	if name == "" || strings.ContainsRune(name, '/') {
+		return nil, fmt.Errorf("invalid cgroup name %q", name)
+	}
+	if err := g.EnableControllers(limits.controllers()...); err != nil {
+		return nil, err
+	}
+	child := &Group{path: filepath.Join(g.path, name)}
+	if err := os.Mkdir(child.path, 0o755); err != nil && !errors.Is(err, os.ErrExist) {
+		return nil, fmt.Errorf("failed to create cgroup %s: %w", child.path, err)
+	}
+	if err := child.SetLimits(limits); err != nil {
+		_ = child.Remove()
+		return nil, err
+	}
+	return child, nil
+}
+
+// EnableControllers makes controllers such as "memory" and "cpu" available
+// to g's children.
+func (g *Group) EnableControllers(names ...string) error {
+	if len(names) == 0 {
+		return nil
+	}
+	enabled, _ := g.readFile("cgroup.subtree_control")
+	var missing []string
+	for _, name := range names {
+		if !slices.Contains(strings.Fields(enabled), name) {
+			missing = append(missing, "+"+name)
+		}
+	}
+	if len(missing) == 0 {
+		return nil
+	}
+	if err := g.writeFile("cgroup.subtree_control", strings.Join(missing, " ")); err != nil {
+		return fmt.Errorf("failed to enable controllers %v: %w", names, err)
+	}
+	return nil
+}
+
+// SetLimits writes the non-zero limits to the group's interface files.
+func (g *Group) SetLimits(l Limits) error {
+	if l.MemoryMax > 0 {
+		if err := g.writeFile("memory.max", strconv.FormatInt(l.MemoryMax, 10)); err != nil {
+			return err
+		}
+	}
+	if l.CPUMax > 0 {
+		period := DefaultPeriod.Microseconds()
+		quota := int64(l.CPUMax * float64(period))
+		if err := g.writeFile("cpu.max", fmt.Sprintf("%d %d", quota, period)); err != nil {
+			return err
+		}
+	}
+	if l.PidsMax > 0 {
+		if err := g.writeFile("pids.max", strconv.FormatInt(l.PidsMax, 10)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Add moves a process into the group. Processes it starts later are born in
+// the group too.
+func (g *Group) Add(pid int) error {
+	return g.writeFile("cgroup.procs", strconv.Itoa(pid))
+}
+
+// Procs lists the processes in the group, not counting its children.
+func (g *Group) Procs() ([]int, error) {
+	data, err := g.readFile("cgroup.procs")
+	if err != nil {
+		return nil, err
+	}
+	var pids []int
+	for _, field := range strings.Fields(data) {
+		if pid, err := strconv.Atoi(field); err == nil {
+			pids = append(pids, pid)
+		}
+	}
+	return pids, nil
+}
+
+// Remove deletes the group. It fails while the group still has processes.
+func (g *Group) Remove() error {
+	if err := os.Remove(g.path); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("failed to remove cgroup %s: %w", g.path, err)
+	}
+	return nil
+}
+
+// Usage is a snapshot of a group's resource use, including its children.
+// Values whose controller is not enabled for the group are zero.
+type Usage struct {
+	SampledAt     time.Time
+	MemoryCurrent int64 // Bytes in use.
+	MemoryPeak    int64 // Highest memory use recorded, where the kernel reports it.
+	MemoryMax     int64 // The memory limit in bytes, or -1 for none.
+	OOMKills      uint64
+	CPUUsage      time.Duration // Total CPU time.
+	CPUUser       time.Duration
+	CPUSystem     time.Duration
+	Throttled     uint64        // Periods in which the group hit its CPU limit.
+	ThrottledTime time.Duration // Time the group spent throttled.
+	Pids          int64         // Processes currently in the group.
+}
+
+// Usage reads the group's usage from its interface files.
+func (g *Group) Usage() (*Usage, error) {
+	if _, err := os.Stat(g.path); err != nil {
+		return nil, fmt.Errorf("failed to read cgroup %s: %w", g.path, err)
+	}
+	u := &Usage{SampledAt: time.Now(), MemoryMax: -1}
+	u.MemoryCurrent = g.readInt("memory.current")
+	u.MemoryPeak = g.readInt("memory.peak")
+	if limit, err := g.readFile("memory.max"); err == nil && strings.TrimSpace(limit) != "max" {
+		u.MemoryMax = g.readInt("memory.max")
+	}
+	u.Pids = g.readInt("pids.current")
+
+	events := g.readKeyed("memory.events")
+	u.OOMKills = uint64(events["oom_kill"])
+
+	stat := g.readKeyed("cpu.stat")
+	u.CPUUsage = time.Duration(stat["usage_usec"]) * time.Microsecond
+	u.CPUUser = time.Duration(stat["user_usec"]) * time.Microsecond
+	u.CPUSystem = time.Duration(stat["system_usec"]) * time.Microsecond
+	u.Throttled = uint64(stat["nr_throttled"])
+	u.ThrottledTime = time.Duration(stat["throttled_usec"]) * time.Microsecond
+	return u, nil
+}
+
+// readInt reads a file holding a single number; missing files read as zero.
+func (g *Group) readInt(name string) int64 {
+	data, err := g.readFile(name)
+	if err != nil {
+		return 0
+	}
+	n, _ := strconv.ParseInt(strings.TrimSpace(data), 10, 64)
+	return n
+}
+
+// readKeyed reads a flat keyed file such as cpu.stat.
+func (g *Group) readKeyed(name string) map[string]int64 {
+	values := make(map[string]int64)
+	data, err := g.readFile(name)
+	if err != nil {
+		return values
+	}
+	for _, line := range strings.Split(data, "\n") {
+		if key, value, ok := strings.Cut(line, " "); ok {
+			values[key], _ = strconv.ParseInt(strings.TrimSpace(value), 10, 64)
+		}
+	}
+	return values
+}
+
+func (g *Group) readFile(name string) (string, error) {
+	data, err := os.ReadFile(filepath.Join(g.path, name))
+	return string(data), err
+}
+
+func (g *Group) writeFile(name, value string) error {
+	if err := os.WriteFile(filepath.Join(g.path, name), []byte(value), 0o644); err != nil {
+		return fmt.Errorf("failed to write %s of cgroup %s: %w", name, g.path, err)
+	}
+	return nil
+}
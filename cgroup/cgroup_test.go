@@ -0,0 +1,95 @@
+package cgroup_test
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/owen-6936/termplex/assert"
+	"github.com/owen-6936/termplex/cgroup"
+)
+
+// fakeGroup creates a directory that looks like a cgroup.
+func fakeGroup(t *testing.T, files map[string]string) *cgroup.Group {
+	dir := t.TempDir()
+	files["cgroup.procs"] += ""
+	for name, content := range files {
+		assert.NoError(t, os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644))
+	}
+	g, err := cgroup.Open(dir)
+	assert.NoError(t, err)
+	return g
+}
+
+func read(t *testing.T, path string) string {
+	data, err := os.ReadFile(path)
+	assert.NoError(t, err)
+	return string(data)
+}
+
+func TestNewChildWritesLimits(t *testing.T) {
+	parent := fakeGroup(t, map[string]string{"cgroup.subtree_control": "cpu\n"})
+
+	// 1. Only the missing controllers are enabled for the children.
+	child, err := parent.NewChild("ci-shell", cgroup.Limits{MemoryMax: 256 << 20, CPUMax: 1.5, PidsMax: 64})
+	assert.NoError(t, err)
+	assert.True(t, read(t, filepath.Join(parent.Path(), "cgroup.subtree_control")) == "+memory +pids", "Unexpected subtree_control")
+
+	// 2. The limits land in the child's interface files.
+	assert.True(t, read(t, filepath.Join(child.Path(), "memory.max")) == "268435456", "Unexpected memory.max")
+	assert.True(t, read(t, filepath.Join(child.Path(), "cpu.max")) == "150000 100000", "Unexpected cpu.max")
+	assert.True(t, read(t, filepath.Join(child.Path(), "pids.max")) == "64", "Unexpected pids.max")
+
+	// 3. Names that would escape the parent are rejected.
+	_, err = parent.NewChild("../escape", cgroup.Limits{})
+	assert.True(t, err != nil, "Expected an error for a name with a slash")
+	_, err = cgroup.Open(t.TempDir())
+	assert.True(t, err != nil, "Expected an error for a directory that is not a cgroup")
+}
+
+func TestUsageReadsInterfaceFiles(t *testing.T) {
+	g := fakeGroup(t, map[string]string{
+		"memory.current": "1048576\n",
+		"memory.peak":    "2097152\n",
+		"memory.max":     "max\n",
+		"memory.events":  "low 0\nhigh 0\nmax 3\noom 1\noom_kill 1\n",
+		"cpu.stat":       "usage_usec 1500000\nuser_usec 1000000\nsystem_usec 500000\nnr_periods 20\nnr_throttled 4\nthrottled_usec 250000\n",
+		"pids.current":   "3\n",
+	})
+
+	u, err := g.Usage()
+	assert.NoError(t, err)
+	assert.True(t, u.MemoryCurrent == 1<<20 && u.MemoryPeak == 2<<20, "Unexpected memory %+v", u)
+	assert.True(t, u.MemoryMax == -1, "Expected no memory limit, got %d", u.MemoryMax)
+	assert.True(t, u.OOMKills == 1, "Expected one OOM kill, got %d", u.OOMKills)
+	assert.True(t, u.CPUUsage == 1500*time.Millisecond && u.CPUUser == time.Second && u.CPUSystem == 500*time.Millisecond, "Unexpected CPU %+v", u)
+	assert.True(t, u.Throttled == 4 && u.ThrottledTime == 250*time.Millisecond, "Unexpected throttling %+v", u)
+	assert.True(t, u.Pids == 3, "Expected 3 processes, got %d", u.Pids)
+}
+
+func TestAddMovesProcess(t *testing.T) {
+	self, err := cgroup.Self()
+	if err != nil {
+		t.Skipf("cgroup v2 is not available: %v", err)
+	}
+	g, err := self.NewChild("termplex-test-"+strings.ReplaceAll(t.Name(), "/", "-"), cgroup.Limits{})
+	if err != nil {
+		t.Skipf("cgroup v2 is not writable: %v", err)
+	}
+	t.Cleanup(func() { _ = g.Remove() })
+
+	// 1. A process moved into the group is listed there.
+	proc, err := os.StartProcess("/bin/sleep", []string{"sleep", "5"}, &os.ProcAttr{})
+	assert.NoError(t, err)
+	assert.NoError(t, g.Add(proc.Pid))
+	pids, err := g.Procs()
+	assert.NoError(t, err)
+	assert.True(t, len(pids) == 1 && pids[0] == proc.Pid, "Expected the process in the group, got %v", pids)
+
+	// 2. An emptied group can be removed.
+	assert.NoError(t, proc.Kill())
+	_, _ = proc.Wait()
+	assert.NoError(t, g.Remove())
+}
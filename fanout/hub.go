@@ -0,0 +1,182 @@
+// Package fanout delivers a stream of values to any number of independent
+// subscribers, each with its own buffer and backpressure policy.
+package fanout
+
+import (
+	"sync"
+	"sync/atomic"
+)
+
+// Policy decides what a subscription does when its buffer is full.
+type Policy int
+
+const (
+	// Block makes the publisher wait until the subscriber has room. A slow
+	// subscriber stalls the stream for everyone, so use it only when no
+	// value may be lost.
+	Block Policy = iota
+	// DropOldest discards the oldest buffered value to make room.
+	DropOldest
+	// DropNewest discards the value being published.
+	DropNewest
+)
+
+// DefaultBuffer is the channel capacity used when Options.Buffer is zero.
+const DefaultBuffer = 100
+
+// Options configures a subscription.
+type Options struct {
+	Buffer int    // Channel capacity; zero uses DefaultBuffer.
+	Policy Policy // What to do when the buffer is full; defaults to Block.
+}
+
+// Subscription is a handle on a subscriber's channel.
+type Subscription struct {
+	done    chan struct{}
+	once    sync.Once
+	dropped atomic.Uint64
+	close   func() // Closes the channel once no publisher can send on it.
+	detach  func() // Removes the subscription from its hub.
+}
+
+// Cancel ends the subscription and closes its channel; values already
+// buffered can still be received. It is safe to call more than once.
+func (s *Subscription) Cancel() {
+	s.once.Do(func() {
+		close(s.done) // Releases a publisher blocked on this subscriber.
+		s.close()
+		s.detach()
+	})
+}
+
+// Done returns a channel that is closed once the subscription has ended.
+func (s *Subscription) Done() <-chan struct{} {
+	return s.done
+}
+
+// Dropped reports how many values the subscription has discarded because
+// its buffer was full.
+func (s *Subscription) Dropped() uint64 {
+	return s.dropped.Load()
+}
+
+// subscriber is the hub's side of a subscription.
+type subscriber[T any] struct {
+	*Subscription
+	mu     sync.Mutex // Serializes sends with closing the channel.
+	ch     chan T
+	policy Policy
+	closed bool
+}
+
+// send delivers v according to the subscriber's policy.
+func (s *subscriber[T]) send(v T) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return
+	}
+
+	switch s.policy {
+	case DropNewest:
+		select {
+		case s.ch <- v:
+		default:
+			s.dropped.Add(1)
+		}
+	case DropOldest:
+		for {
+			select {
+			case s.ch <- v:
+				return
+			default:
+			}
+			select {
+			case <-s.ch:
+				s.dropped.Add(1)
+			default: // The subscriber just made room.
+			}
+		}
+	default:
+		select {
+		case s.ch <- v:
+		case <-s.done:
+		}
+	}
+}
+
+// Hub fans published values out to its subscribers. The zero value is ready
+// to use.
+type Hub[T any] struct {
+	mu     sync.Mutex
+	subs   map[*subscriber[T]]struct{}
+	closed bool
+}
+
+// Subscribe registers a new subscriber. The channel is closed when the
+// subscription is cancelled or the hub is closed.
+func (h *Hub[T]) Subscribe(opts Options) (<-chan T, *Subscription) {
+	if opts.Buffer <= 0 {
+		opts.Buffer = DefaultBuffer
+	}
+	sub := &subscriber[T]{ch: make(chan T, opts.Buffer), policy: opts.Policy}
+	sub.Subscription = &Subscription{
+		done: make(chan struct{}),
+		close: func() {
+			sub.mu.Lock()
+			defer sub.mu.Unlock()
+			sub.closed = true
+			close(sub.ch)
+		},
+		detach: func() {
+			h.mu.Lock()
+			defer h.mu.Unlock()
+			delete(h.subs, sub)
+		},
+	}
+
+	h.mu.Lock()
+	closed := h.closed
+	if !closed {
+		if h.subs == nil {
+			h.subs = make(map[*subscriber[T]]struct{})
+		}
+		h.subs[sub] = struct{}{}
+	}
+	h.mu.Unlock()
+
+	if closed {
+		sub.Cancel()
+	}
+	return sub.ch, sub.Subscription
+}
+
+// Publish delivers v to every current subscriber, in turn.
+func (h *Hub[T]) Publish(v T) {
+	h.mu.Lock()
+	subs := make([]*subscriber[T], 0, len(h.subs))
+	for sub := range h.subs {
+		subs = append(subs, sub)
+	}
+	h.mu.Unlock()
+
+	for _, sub := range subs {
+		sub.send(v)
+	}
+}
+
+// Close cancels every subscription and rejects new ones, which receive an
+// already closed channel.
+func (h *Hub[T]) Close() {
+	h.mu.Lock()
+	h.closed = true
+	subs := make([]*subscriber[T], 0, len(h.subs))
+	for sub := range h.subs {
+		subs = append(subs, sub)
+	}
+	h.mu.Unlock()
+
+	for _, sub := range subs {
+		sub.Cancel()
+	}
+}
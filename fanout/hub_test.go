@@ -0,0 +1,108 @@
+package fanout_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/owen-6936/termplex/assert"
+	"github.com/owen-6936/termplex/fanout"
+)
+
+// drain reads everything currently buffered on ch.
+func drain(ch <-chan int) []int {
+	var got []int
+	for {
+		select {
+		case v, ok := <-ch:
+			if !ok {
+				return got
+			}
+			got = append(got, v)
+		default:
+			return got
+		}
+	}
+}
+
+func TestDropPoliciesCountDiscardedValues(t *testing.T) {
+	var hub fanout.Hub[int]
+	oldest, oldestSub := hub.Subscribe(fanout.Options{Buffer: 3, Policy: fanout.DropOldest})
+	newest, newestSub := hub.Subscribe(fanout.Options{Buffer: 3, Policy: fanout.DropNewest})
+
+	// 1. Nobody reads while five values are published into three-slot buffers.
+	for i := 1; i <= 5; i++ {
+		hub.Publish(i)
+	}
+
+	// 2. Each subscriber keeps a different window of the stream.
+	assert.True(t, equal(drain(oldest), []int{3, 4, 5}), "DropOldest should keep the latest values")
+	assert.True(t, equal(drain(newest), []int{1, 2, 3}), "DropNewest should keep the earliest values")
+	assert.True(t, oldestSub.Dropped() == 2, "Expected 2 drops, got %d", oldestSub.Dropped())
+	assert.True(t, newestSub.Dropped() == 2, "Expected 2 drops, got %d", newestSub.Dropped())
+}
+
+func TestBlockWaitsForSlowSubscriber(t *testing.T) {
+	var hub fanout.Hub[int]
+	ch, sub := hub.Subscribe(fanout.Options{Buffer: 1, Policy: fanout.Block})
+
+	// 1. The second publish waits until the subscriber reads.
+	hub.Publish(1)
+	published := make(chan struct{})
+	go func() {
+		hub.Publish(2)
+		close(published)
+	}()
+	select {
+	case <-published:
+		t.Fatal("Expected Publish to block on a full subscriber")
+	case <-time.After(50 * time.Millisecond):
+	}
+	assert.True(t, <-ch == 1, "Expected the first value")
+	<-published
+	assert.True(t, <-ch == 2, "Expected the second value")
+
+	// 2. Cancelling releases a blocked publisher and closes the channel.
+	hub.Publish(3)
+	go func() {
+		time.Sleep(20 * time.Millisecond)
+		sub.Cancel()
+	}()
+	hub.Publish(4)
+	assert.True(t, equal(drain(ch), []int{3}), "Expected buffered values to survive Cancel")
+	_, ok := <-ch
+	assert.True(t, !ok, "Expected the channel to be closed")
+	assert.True(t, sub.Dropped() == 0, "Block should never drop, got %d", sub.Dropped())
+}
+
+func TestCloseEndsEverySubscription(t *testing.T) {
+	var hub fanout.Hub[int]
+	first, _ := hub.Subscribe(fanout.Options{})
+	second, _ := hub.Subscribe(fanout.Options{Policy: fanout.DropNewest})
+
+	// 1. Every subscriber receives every value.
+	hub.Publish(7)
+	assert.True(t, <-first == 7 && <-second == 7, "Expected both subscribers to receive the value")
+
+	// 2. Close ends both streams, and late subscribers get a closed channel.
+	hub.Close()
+	hub.Publish(8)
+	_, ok := <-first
+	assert.True(t, !ok, "Expected the first channel to be closed")
+	_, ok = <-second
+	assert.True(t, !ok, "Expected the second channel to be closed")
+	late, _ := hub.Subscribe(fanout.Options{})
+	_, ok = <-late
+	assert.True(t, !ok, "Expected a late subscription to be closed")
+}
+
+func equal(a, b []int) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,193 @@
+// Package logfile provides an append-only log file that rotates by size or
+// age and prunes the files it has rotated out.
+package logfile
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// backupTimeFormat names rotated files, so they sort oldest first.
+const backupTimeFormat = "20060102-150405.000000"
+
+// Options decide when a Writer rotates and which rotated files it keeps.
+type Options struct {
+	MaxBytes   int64         // Rotate before a write would grow the file past this size; zero never rotates on size.
+	Interval   time.Duration // Rotate once the file has been written to for this long; zero never rotates on age.
+	MaxBackups int           // Rotated files to keep, newest first; zero keeps them all.
+	MaxAge     time.Duration // Delete rotated files last written longer ago than this; zero keeps them regardless of age.
+}
+
+// Writer appends to a log file, moving it aside as path.<timestamp> when it
+// is due for rotation and starting a new one. It is safe for concurrent use.
+type Writer struct {
+	mu       sync.Mutex
+	path     string
+	opts     Options
+	file     *os.File
+	size     int64
+	openedAt time.Time
+}
+
+// Open opens or creates the log file at path, and its directory, for
+// appending.
+func Open(path string, opts Options) (*Writer, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create log directory: %w", err)
+	}
+	w := &Writer{path: path, opts: opts}
+	if err := w.openLocked(); err != nil {
+		return nil, err
+	}
+	return w, nil
+}
+
+// Path returns the path of the current log file.
+func (w *Writer) Path() string {
+	return w.path
+}
+
+// Write appends p to the log file, rotating it first if it is due. A single
+// write is never split across files.
+func (w *Writer) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.file == nil {
+		return 0, fmt.Errorf("log file %s is closed", w.path)
+	}
+	if w.dueLocked(len(p)) {
+		if err := w.rotateLocked(); err != nil {
+			return 0, err
+		}
+	}
+	n, err := w.file.Write(p)
+	w.size += int64(n)
+	return n, err
+}
+
+// dueLocked reports whether the file should be rotated before writing n
+// bytes. An empty file is never rotated.
+func (w *Writer) dueLocked(n int) bool {
+	if w.size == 0 {
+		return false
+	}
+	if w.opts.MaxBytes > 0 && w.size+int64(n) > w.opts.MaxBytes {
+		return true
+	}
+	return w.opts.Interval > 0 && time.Since(w.openedAt) >= w.opts.Interval
+}
+
+// Rotate moves the current file aside and starts a new one, whether or not
+// it is due.
+func (w *Writer) Rotate() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.file == nil {
+		return fmt.Errorf("log file %s is closed", w.path)
+	}
+	return w.rotateLocked()
+}
+
+// rotateLocked renames the current file to its backup name, reopens the
+// path and prunes old backups.
+func (w *Writer) rotateLocked() error {
+	if err := w.file.Close(); err != nil {
+		return fmt.Errorf("failed to close log file %s: %w", w.path, err)
+	}
+	w.file = nil
+	backup := w.backupName(time.Now())
+	if err := os.Rename(w.path, backup); err != nil {
+		return fmt.Errorf("failed to rotate log file %s: %w", w.path, err)
+	}
+	if err := w.openLocked(); err != nil {
+		return err
+	}
+	return w.pruneLocked()
+}
+
+// backupName returns an unused name for a file rotated at t.
+func (w *Writer) backupName(t time.Time) string {
+	name := w.path + "." + t.Format(backupTimeFormat)
+	for i := 1; ; i++ {
+		if _, err := os.Lstat(name); errors.Is(err, os.ErrNotExist) {
+			return name
+		}
+		name = fmt.Sprintf("%s.%s-%d", w.path, t.Format(backupTimeFormat), i)
+	}
+}
+
+// openLocked opens the log file for appending.
+func (w *Writer) openLocked() error {
+	f, err := os.OpenFile(w.path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o644)
+	if err != nil {
+		return fmt.Errorf("failed to open log file: %w", err)
+	}
+	info, err := f.Stat()
+	if err != nil {
+		_ = f.Close()
+		return fmt.Errorf("failed to open log file: %w", err)
+	}
+	w.file, w.size, w.openedAt = f, info.Size(), time.Now()
+	return nil
+}
+
+// Backups lists the rotated files that are kept, oldest first.
+func (w *Writer) Backups() ([]string, error) {
+	dir, base := filepath.Split(w.path)
+	entries, err := os.ReadDir(filepath.Clean(dir))
+	if err != nil {
+		return nil, err
+	}
+	var backups []string
+	for _, e := range entries {
+		if !e.IsDir() && strings.HasPrefix(e.Name(), base+".") {
+			backups = append(backups, filepath.Join(dir, e.Name()))
+		}
+	}
+	slices.Sort(backups)
+	return backups, nil
+}
+
+// pruneLocked deletes the backups that MaxBackups and MaxAge no longer keep.
+func (w *Writer) pruneLocked() error {
+	if w.opts.MaxBackups <= 0 && w.opts.MaxAge <= 0 {
+		return nil
+	}
+	backups, err := w.Backups()
+	if err != nil {
+		return err
+	}
+	var errs []error
+	for i, path := range backups {
+		expired := w.opts.MaxBackups > 0 && len(backups)-i > w.opts.MaxBackups
+		if !expired && w.opts.MaxAge > 0 {
+			if info, err := os.Stat(path); err == nil && time.Since(info.ModTime()) > w.opts.MaxAge {
+				expired = true
+			}
+		}
+		if expired {
+			if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+				errs = append(errs, err)
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Close closes the log file. Later writes fail.
+func (w *Writer) Close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.file == nil {
+		return nil
+	}
+	err := w.file.Close()
+	w.file = nil
+	return err
+}
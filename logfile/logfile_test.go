@@ -0,0 +1,69 @@
+package logfile_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/owen-6936/termplex/assert"
+	"github.com/owen-6936/termplex/logfile"
+)
+
+func read(t *testing.T, path string) string {
+	data, err := os.ReadFile(path)
+	assert.NoError(t, err)
+	return string(data)
+}
+
+func TestWriterRotatesBySize(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "logs", "shell.log")
+	w, err := logfile.Open(path, logfile.Options{MaxBytes: 10, MaxBackups: 2})
+	assert.NoError(t, err)
+	t.Cleanup(func() { _ = w.Close() })
+
+	// 1. Writes that fit stay in one file; one that does not starts a new file.
+	for _, chunk := range []string{"12345", "6789", "abcdef", "ghij", "klmnopqrstuvwxyz", "end"} {
+		_, err := w.Write([]byte(chunk))
+		assert.NoError(t, err)
+	}
+	assert.True(t, read(t, path) == "end", "Unexpected current file %q", read(t, path))
+
+	// 2. Only the newest MaxBackups rotated files are kept, oldest first.
+	backups, err := w.Backups()
+	assert.NoError(t, err)
+	assert.True(t, len(backups) == 2, "Expected 2 backups, got %v", backups)
+	assert.True(t, read(t, backups[0]) == "abcdefghij", "Unexpected older backup %q", read(t, backups[0]))
+	assert.True(t, read(t, backups[1]) == "klmnopqrstuvwxyz", "Unexpected newer backup %q", read(t, backups[1]))
+}
+
+func TestWriterRotatesByAge(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "shell.log")
+	w, err := logfile.Open(path, logfile.Options{Interval: 50 * time.Millisecond, MaxAge: time.Hour})
+	assert.NoError(t, err)
+	t.Cleanup(func() { _ = w.Close() })
+
+	// 1. A write after the interval lands in a fresh file.
+	_, err = w.Write([]byte("before\n"))
+	assert.NoError(t, err)
+	time.Sleep(80 * time.Millisecond)
+	_, err = w.Write([]byte("after\n"))
+	assert.NoError(t, err)
+	assert.True(t, read(t, path) == "after\n", "Unexpected current file %q", read(t, path))
+
+	// 2. Backups older than MaxAge are pruned at the next rotation.
+	backups, err := w.Backups()
+	assert.NoError(t, err)
+	assert.True(t, len(backups) == 1, "Expected 1 backup, got %v", backups)
+	old := time.Now().Add(-2 * time.Hour)
+	assert.NoError(t, os.Chtimes(backups[0], old, old))
+	assert.NoError(t, w.Rotate())
+	backups, err = w.Backups()
+	assert.NoError(t, err)
+	assert.True(t, len(backups) == 1 && read(t, backups[0]) == "after\n", "Expected only the fresh backup, got %v", backups)
+
+	// 3. A closed writer refuses writes.
+	assert.NoError(t, w.Close())
+	_, err = w.Write([]byte("late"))
+	assert.True(t, err != nil, "Expected an error after Close")
+}
@@ -2,9 +2,13 @@ package main
 
 import (
 	"fmt"
+	"log/slog"
+	"os"
+	"regexp"
 	"sync"
 	"time"
 
+	"github.com/owen-6936/termplex/pane"
 	"github.com/owen-6936/termplex/session"
 )
 
@@ -14,6 +18,7 @@ func main() {
 
 	// 1. Initialize the top-level SessionManager
 	sm := session.NewSessionManager(5) // Allow up to 5 windows per session
+	sm.SetLogger(slog.New(slog.NewTextHandler(os.Stderr, nil)))
 
 	// 2. Create a new orchestration Session
 	sessionID, err := sm.CreateSession("DemoProject", map[string]string{"owner": "owen"})
@@ -33,12 +38,12 @@ func main() {
 	if err != nil {
 		panic(fmt.Sprintf("Failed to add pane: %v", err))
 	}
-	pane, _ := wm.GetPane(paneID)
+	pm, _ := wm.GetPane(paneID)
 
 	// 5. Start a goroutine to consume all multiplexed output from the pane
 	wg.Go(func() {
 		fmt.Println("\n--- 🎧 Listening for all output from Pane ---")
-		for output := range pane.OutputChan {
+		for output := range pm.OutputChan {
 			streamType := "STDOUT"
 			if output.IsStderr {
 				streamType = "STDERR"
@@ -50,20 +55,18 @@ func main() {
 
 	// 6. Spawn shells within the Pane
 	// Spawn an interactive shell
-	interactiveShell, err := pane.SpawnShell(true, "bash", "-i")
+	interactiveShell, err := pm.SpawnShell(true, "bash", "-i")
 	if err != nil {
 		panic(fmt.Sprintf("Failed to spawn interactive shell: %v", err))
 	}
 
-	// Spawn a non-interactive background task
-	go func() {
-		_, err := pane.SpawnShell(false, "bash", "-c", "echo 'Background service starting...'; sleep 1; echo 'Background service is ready.'")
-		if err != nil {
-			fmt.Printf("Error spawning background service: %v\n", err)
-		}
-		// After the shell command finishes, tag the pane.
-		pane.AddTag("service-status", "ready")
-	}()
+	// Spawn a non-interactive background task. A readiness probe tags the
+	// pane as soon as the service reports that it is ready.
+	err = pm.AddProbe(pane.Probe{Output: regexp.MustCompile(`service is ready`), Tag: "service-status", Value: "ready"})
+	if err != nil {
+		panic(fmt.Sprintf("Failed to add readiness probe: %v", err))
+	}
+	_, err = pm.SpawnShell(false, "bash", "-c", "echo 'Background service starting...'; sleep 1; echo 'Background service is ready.'")
 	if err != nil {
 		panic(fmt.Sprintf("Failed to spawn background shell: %v", err))
 	}
@@ -78,7 +81,7 @@ func main() {
 
 	// NEW: Wait for the background service to be ready before proceeding.
 	fmt.Println("\n--- ⏳ Waiting for background service to be ready ---")
-	err = pane.WaitForTag("service-status", "ready", 5*time.Second)
+	err = pm.WaitForTag("service-status", "ready", 5*time.Second)
 	if err != nil {
 		fmt.Printf("Error while waiting for service: %v\n", err)
 	} else {
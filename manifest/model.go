@@ -3,9 +3,10 @@ package manifest
 // Manifest defines the structure for a .termplex.json file, representing a complete
 // orchestration session.
 type Manifest struct {
-	SessionName string            `json:"sessionName"`
-	SessionTags map[string]string `json:"sessionTags"`
-	Windows     []WindowManifest  `json:"windows"`
+	SessionName string              `json:"sessionName"`
+	SessionTags map[string]string   `json:"sessionTags"`
+	Scrollback  *ScrollbackManifest `json:"scrollback,omitempty"`
+	Windows     []WindowManifest    `json:"windows"`
 }
 
 // WindowManifest describes a single window to be created within a session.
@@ -17,14 +18,72 @@ type WindowManifest struct {
 
 // PaneManifest describes a single pane to be created within a window.
 type PaneManifest struct {
-	PaneName        string            `json:"paneName,omitempty"`
-	PaneTags        map[string]string `json:"paneTags"`
-	StartupShell    ShellManifest     `json:"startupShell"`
-	StartupCommands []string          `json:"startupCommands"`
+	PaneName        string              `json:"paneName,omitempty"`
+	PaneTags        map[string]string   `json:"paneTags"`
+	Size            *SizeManifest       `json:"size,omitempty"`
+	Scrollback      *ScrollbackManifest `json:"scrollback,omitempty"`
+	StartupShell    ShellManifest       `json:"startupShell"`
+	StartupCommands []string            `json:"startupCommands"`
+	Probes          []ProbeManifest     `json:"probes,omitempty"`
+	Logs            *LogsManifest       `json:"logs,omitempty"`
+}
+
+// LogsManifest overrides the session manager's output logs for a pane.
+// Omitted fields keep the session manager's settings. Durations use Go
+// syntax, such as "1h".
+type LogsManifest struct {
+	Disabled   bool   `json:"disabled,omitempty"` // Log nothing for this pane.
+	Dir        string `json:"dir,omitempty"`
+	MaxBytes   int64  `json:"maxBytes,omitempty"`
+	Interval   string `json:"interval,omitempty"`
+	MaxBackups int    `json:"maxBackups,omitempty"`
+	MaxAge     string `json:"maxAge,omitempty"`
+	NDJSON     *bool  `json:"ndjson,omitempty"`
+}
+
+// ProbeManifest describes a readiness probe on a pane. Exactly one of Output
+// (a regular expression), Command, Port and File is set. Durations use Go
+// syntax, such as "500ms".
+type ProbeManifest struct {
+	Output   string   `json:"output,omitempty"`
+	Command  []string `json:"command,omitempty"`
+	Port     int      `json:"port,omitempty"`
+	File     string   `json:"file,omitempty"`
+	Tag      string   `json:"tag,omitempty"`
+	Value    string   `json:"value,omitempty"`
+	Interval string   `json:"interval,omitempty"`
+	Timeout  string   `json:"timeout,omitempty"`
+}
+
+// SizeManifest describes the terminal dimensions of a pane, in character cells.
+type SizeManifest struct {
+	Rows uint16 `json:"rows"`
+	Cols uint16 `json:"cols"`
 }
 
 // ShellManifest describes the shell process to be spawned in a pane.
 type ShellManifest struct {
-	Interactive bool     `json:"interactive"`
-	Command     []string `json:"command"`
+	Interactive bool              `json:"interactive"`
+	Command     []string          `json:"command"`
+	Cwd         string            `json:"cwd,omitempty"`
+	Env         map[string]string `json:"env,omitempty"`
+	Restart     *RestartManifest  `json:"restart,omitempty"`
+	Dialect     string            `json:"dialect,omitempty"` // "bash", "zsh", "sh", "fish", "python" or "node"; detected from command when empty.
+}
+
+// RestartManifest describes how a supervised background shell is restarted
+// after it exits. Durations use Go syntax, such as "500ms" or "1m".
+type RestartManifest struct {
+	Policy         string `json:"policy"` // "never", "on-failure" or "always".
+	InitialBackoff string `json:"initialBackoff,omitempty"`
+	MaxBackoff     string `json:"maxBackoff,omitempty"`
+	MaxRestarts    int    `json:"maxRestarts,omitempty"`
+	Window         string `json:"window,omitempty"`
+}
+
+// ScrollbackManifest bounds how much output the shells of a session or pane
+// retain. A zero or omitted field means that dimension is unlimited.
+type ScrollbackManifest struct {
+	MaxLines int `json:"maxLines"`
+	MaxBytes int `json:"maxBytes"`
 }
@@ -19,7 +19,13 @@ func TestLoadFromFile_Success(t *testing.T) {
 				"windowName": "TestWindow",
 				"panes": [
 					{
-						"startupShell": { "interactive": true, "command": ["bash", "-i"] },
+						"startupShell": {
+							"interactive": true,
+							"command": ["bash", "-i"],
+							"dialect": "bash",
+							"cwd": "/srv/app",
+							"env": { "APP_MODE": "dev" }
+						},
 						"startupCommands": ["echo 'hello'"]
 					}
 				]
@@ -56,6 +62,15 @@ func TestLoadFromFile_Success(t *testing.T) {
 	if !m.Windows[0].Panes[0].StartupShell.Interactive {
 		t.Error("expected startupShell.interactive to be true")
 	}
+	if m.Windows[0].Panes[0].StartupShell.Dialect != "bash" {
+		t.Errorf("expected startupShell.dialect to be 'bash', got %q", m.Windows[0].Panes[0].StartupShell.Dialect)
+	}
+	if m.Windows[0].Panes[0].StartupShell.Cwd != "/srv/app" {
+		t.Errorf("expected startupShell.cwd to be '/srv/app', got %q", m.Windows[0].Panes[0].StartupShell.Cwd)
+	}
+	if m.Windows[0].Panes[0].StartupShell.Env["APP_MODE"] != "dev" {
+		t.Errorf("expected startupShell.env APP_MODE to be 'dev', got %q", m.Windows[0].Panes[0].StartupShell.Env["APP_MODE"])
+	}
 	if m.Windows[0].Panes[0].StartupCommands[0] != "echo 'hello'" {
 		t.Errorf("expected startup command to be 'echo 'hello'', got %q", m.Windows[0].Panes[0].StartupCommands[0])
 	}
@@ -92,3 +107,105 @@ func TestLoadFromFile_InvalidJSON(t *testing.T) {
 	}
 	assert.Contains(t, err.Error(), "parse manifest JSON")
 }
+
+func TestLoadFromFile_PaneSize(t *testing.T) {
+	content := []byte(`{
+		"sessionName": "SizedSession",
+		"windows": [
+			{
+				"windowName": "Editor",
+				"panes": [
+					{
+						"size": { "rows": 50, "cols": 160 },
+						"startupShell": { "interactive": true, "command": ["bash", "-i"] }
+					}
+				]
+			}
+		]
+	}`)
+
+	filePath := filepath.Join(t.TempDir(), "sized.termplex.json")
+	assert.NoError(t, os.WriteFile(filePath, content, 0644))
+
+	m, err := manifest.LoadFromFile(filePath)
+	assert.NoError(t, err)
+
+	size := m.Windows[0].Panes[0].Size
+	if size == nil {
+		t.Fatal("expected pane size to be parsed, got nil")
+	}
+	if size.Rows != 50 || size.Cols != 160 {
+		t.Errorf("expected pane size 50x160, got %dx%d", size.Rows, size.Cols)
+	}
+}
+
+func TestLoadFromFile_RestartPolicy(t *testing.T) {
+	content := []byte(`{
+		"sessionName": "Workers",
+		"windows": [
+			{
+				"windowName": "Queue",
+				"panes": [
+					{
+						"startupShell": {
+							"interactive": false,
+							"command": ["./worker"],
+							"restart": { "policy": "on-failure", "initialBackoff": "500ms", "maxRestarts": 5, "window": "1m" }
+						}
+					}
+				]
+			}
+		]
+	}`)
+
+	filePath := filepath.Join(t.TempDir(), "workers.termplex.json")
+	assert.NoError(t, os.WriteFile(filePath, content, 0644))
+
+	m, err := manifest.LoadFromFile(filePath)
+	assert.NoError(t, err)
+
+	restart := m.Windows[0].Panes[0].StartupShell.Restart
+	if restart == nil {
+		t.Fatal("expected restart block to be parsed, got nil")
+	}
+	if restart.Policy != "on-failure" || restart.InitialBackoff != "500ms" || restart.MaxRestarts != 5 || restart.Window != "1m" {
+		t.Errorf("unexpected restart block: %+v", *restart)
+	}
+}
+
+func TestLoadFromFile_Probes(t *testing.T) {
+	content := []byte(`{
+		"sessionName": "Probed",
+		"windows": [
+			{
+				"windowName": "Services",
+				"panes": [
+					{
+						"startupShell": { "interactive": false, "command": ["./api"] },
+						"probes": [
+							{ "output": "listening on :\\d+" },
+							{ "port": 8080, "tag": "api", "value": "up", "interval": "100ms", "timeout": "2s" }
+						]
+					}
+				]
+			}
+		]
+	}`)
+
+	filePath := filepath.Join(t.TempDir(), "probed.termplex.json")
+	assert.NoError(t, os.WriteFile(filePath, content, 0644))
+
+	m, err := manifest.LoadFromFile(filePath)
+	assert.NoError(t, err)
+
+	probes := m.Windows[0].Panes[0].Probes
+	if len(probes) != 2 {
+		t.Fatalf("expected 2 probes, got %d", len(probes))
+	}
+	if probes[0].Output != `listening on :\d+` {
+		t.Errorf("unexpected output pattern %q", probes[0].Output)
+	}
+	if probes[1].Port != 8080 || probes[1].Tag != "api" || probes[1].Value != "up" || probes[1].Interval != "100ms" || probes[1].Timeout != "2s" {
+		t.Errorf("unexpected port probe: %+v", probes[1])
+	}
+}
@@ -0,0 +1,220 @@
+package pane
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"time"
+
+	"github.com/owen-6936/termplex/fanout"
+	"github.com/owen-6936/termplex/logfile"
+)
+
+// NDJSONLogName is the name of a pane's NDJSON output log.
+const NDJSONLogName = "output.ndjson"
+
+// LogOptions configure the files a pane persists its output to. Each pane
+// writes below Dir/<pane ID>: a plain-text <shell ID>.log per shell and,
+// with NDJSON set, an NDJSON log of every output chunk.
+type LogOptions struct {
+	Dir      string          // Root directory for pane logs.
+	Rotation logfile.Options // When logs rotate and which rotated files are kept.
+	NDJSON   bool            // Also write output.ndjson.
+}
+
+// LogRecord is one line of a pane's NDJSON log. Data holds the output as
+// text, with invalid UTF-8 replaced.
+type LogRecord struct {
+	ShellID   string
+	Timestamp time.Time
+	IsStderr  bool
+	Data      string
+}
+
+// outputLog writes a pane's output stream to its log files.
+type outputLog struct {
+	dir    string
+	opts   LogOptions
+	shells map[string]*logfile.Writer
+	exited map[string]bool // Shells whose exit has been seen.
+	exits  chan string     // IDs of shells that exited, to close their logs.
+	ndjson *logfile.Writer
+	sub    *fanout.Subscription
+	done   chan struct{}
+	err    error // The first error met while writing or closing the files.
+}
+
+// EnableLogs starts persisting everything the pane's shells print, as
+// delivered to Subscribe, replacing any logs enabled before. Files are
+// created as output arrives.
+func (pm *PaneManager) EnableLogs(opts LogOptions) error {
+	if opts.Dir == "" {
+		return errors.New("LogOptions.Dir is required")
+	}
+	if err := pm.DisableLogs(); err != nil {
+		return err
+	}
+	l := &outputLog{
+		dir:    filepath.Join(opts.Dir, pm.ID),
+		opts:   opts,
+		shells: make(map[string]*logfile.Writer),
+		exited: make(map[string]bool),
+		exits:  make(chan string),
+		done:   make(chan struct{}),
+	}
+	// Logs may not lose output, so a slow disk holds up the shells.
+	var output <-chan PaneOutput
+	output, l.sub = pm.Subscribe(fanout.Options{Policy: fanout.Block})
+	go pm.writeLogs(l, output)
+
+	pm.logsMu.Lock()
+	pm.logs = l
+	pm.logsMu.Unlock()
+	return nil
+}
+
+// DisableLogs stops persisting output and closes the log files, returning
+// any error met while writing them.
+func (pm *PaneManager) DisableLogs() error {
+	if err := pm.stopLogs(); err != nil {
+		return fmt.Errorf("pane %s: %w", pm.ID, err)
+	}
+	return nil
+}
+
+// stopLogs ends logging and waits for the files to be closed.
+func (pm *PaneManager) stopLogs() error {
+	pm.logsMu.Lock()
+	l := pm.logs
+	pm.logs = nil
+	pm.logsMu.Unlock()
+	if l == nil {
+		return nil
+	}
+	l.sub.Cancel()
+	<-l.done
+	return l.err
+}
+
+// LogDir returns the directory the pane writes its logs to, or "" if
+// logging is off.
+func (pm *PaneManager) LogDir() string {
+	pm.logsMu.Lock()
+	defer pm.logsMu.Unlock()
+	if pm.logs == nil {
+		return ""
+	}
+	return pm.logs.dir
+}
+
+// closeShellLog closes the log of a shell that exited, so a pane running
+// many short-lived shells does not keep a file open for each of them.
+func (pm *PaneManager) closeShellLog(shellID string) {
+	pm.logsMu.Lock()
+	l := pm.logs
+	pm.logsMu.Unlock()
+	if l == nil {
+		return
+	}
+	select {
+	case l.exits <- shellID:
+	case <-l.done:
+	}
+}
+
+// shellRunning reports whether the pane has a live shell with the ID, such
+// as one restarted by its policy.
+func (pm *PaneManager) shellRunning(shellID string) bool {
+	for _, s := range pm.Shells.List() {
+		if s.ID == shellID {
+			_, exited := s.ExitStatus()
+			return !exited
+		}
+	}
+	return false
+}
+
+// writeLogs writes output to the log files until the subscription ends.
+// A shell's log is closed when it exits. Output still in flight after the
+// exit reopens the log just long enough to append it.
+func (pm *PaneManager) writeLogs(l *outputLog, output <-chan PaneOutput) {
+	defer close(l.done)
+	for output != nil {
+		select {
+		case out, ok := <-output:
+			if !ok {
+				output = nil
+				continue
+			}
+			if err := l.write(out); err != nil {
+				pm.log().Warn("failed to write pane log", "shell_id", out.ShellID, "error", err)
+				l.fail(err)
+			}
+			if l.exited[out.ShellID] {
+				if pm.shellRunning(out.ShellID) {
+					delete(l.exited, out.ShellID)
+				} else {
+					l.fail(l.closeShell(out.ShellID))
+				}
+			}
+		case id := <-l.exits:
+			l.exited[id] = true
+			l.fail(l.closeShell(id))
+		}
+	}
+	for _, w := range l.shells {
+		l.fail(w.Close())
+	}
+	if l.ndjson != nil {
+		l.fail(l.ndjson.Close())
+	}
+}
+
+// fail records err unless an earlier error was already recorded.
+func (l *outputLog) fail(err error) {
+	if l.err == nil {
+		l.err = err
+	}
+}
+
+// closeShell closes a shell's log, if it is open.
+func (l *outputLog) closeShell(shellID string) error {
+	w, ok := l.shells[shellID]
+	if !ok {
+		return nil
+	}
+	delete(l.shells, shellID)
+	return w.Close()
+}
+
+// write appends one output chunk to its shell's log and the NDJSON log.
+func (l *outputLog) write(out PaneOutput) error {
+	w, ok := l.shells[out.ShellID]
+	if !ok {
+		var err error
+		if w, err = logfile.Open(filepath.Join(l.dir, out.ShellID+".log"), l.opts.Rotation); err != nil {
+			return err
+		}
+		l.shells[out.ShellID] = w
+	}
+	if _, err := w.Write(out.Data); err != nil {
+		return err
+	}
+	if !l.opts.NDJSON {
+		return nil
+	}
+
+	if l.ndjson == nil {
+		var err error
+		if l.ndjson, err = logfile.Open(filepath.Join(l.dir, NDJSONLogName), l.opts.Rotation); err != nil {
+			return err
+		}
+	}
+	line, err := json.Marshal(LogRecord{ShellID: out.ShellID, Timestamp: out.Timestamp, IsStderr: out.IsStderr, Data: string(out.Data)})
+	if err != nil {
+		return err
+	}
+	_, err = l.ndjson.Write(append(line, '\n'))
+	return err
+}
@@ -1,10 +1,19 @@
 package pane
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"io"
+	"log/slog"
+	"os"
 	"sync"
 	"time"
 
+	"github.com/owen-6936/termplex/asciicast"
+	"github.com/owen-6936/termplex/cgroup"
+	"github.com/owen-6936/termplex/fanout"
+	"github.com/owen-6936/termplex/screen"
 	"github.com/owen-6936/termplex/shell"
 )
 
@@ -15,30 +24,43 @@ func NewPaneManager(paneID, name string) *PaneManager {
 		Name:      name,
 		CreatedAt: time.Now(),
 		// Each pane gets its own dedicated shell manager.
-		Shells:     shell.NewShellManager(nil),
-		Tags:       make(map[string]string),
-		OutputChan: make(chan PaneOutput, 100), // Buffered channel
-		closeChan:  make(chan struct{}),
+		Shells:    shell.NewShellManager(nil),
+		Tags:      make(map[string]string),
+		ExitChan:  make(chan shell.ExitStatus, 100),
+		closeChan: make(chan struct{}),
 	}
 	pm.tagsCond = sync.NewCond(&pm.tagsMu)
+	// Nobody may be reading the legacy channel; it must never stall a shell.
+	pm.OutputChan, _ = pm.output.Subscribe(fanout.Options{Policy: fanout.DropOldest})
 	// Start a single goroutine to forward all output from the shell manager.
-	go pm.forwardShellOutput()
+	// Its subscription blocks, so each pane subscriber's own policy decides
+	// whether a slow reader holds up the shells.
+	shellOutput, _ := pm.Shells.Subscribe(fanout.Options{Policy: fanout.Block})
+	go pm.forwardShellOutput(shellOutput)
+	go pm.forwardShellExits()
 	return pm
 }
 
 // SpawnShell creates and registers a new shell process within the pane.
 func (pm *PaneManager) SpawnShell(interactive bool, command ...string) (*shell.ShellSession, error) {
+	return pm.SpawnShellWithOptions(shell.SpawnOptions{Interactive: interactive, Command: command})
+}
+
+// SpawnShellWithOptions creates and registers a new shell process within the
+// pane, with control over its working directory, environment and PTY size.
+func (pm *PaneManager) SpawnShellWithOptions(opts shell.SpawnOptions) (*shell.ShellSession, error) {
+	interactive := opts.Interactive
 	if interactive {
 		// If an interactive shell already exists, gracefully terminate it before spawning the new one.
 		if pm.InteractiveShell != nil {
-			fmt.Printf("🔄 Replacing existing interactive shell %s\n", pm.InteractiveShell.ID)
+			pm.log().Info("replacing interactive shell", "shell_id", pm.InteractiveShell.ID)
 			// Use a 5-second grace period as suggested.
 			_, _ = pm.TerminateShell(pm.InteractiveShell.ID, 5*time.Second)
 		}
 	}
 
 	// Delegate shell creation to the pane's own shell manager.
-	newShell, err := pm.Shells.SpawnShell(interactive, command...)
+	newShell, err := pm.Shells.SpawnShellWithOptions(opts)
 	if err != nil {
 		return nil, fmt.Errorf("failed to spawn shell via manager: %w", err)
 	}
@@ -51,21 +73,57 @@ func (pm *PaneManager) SpawnShell(interactive bool, command ...string) (*shell.S
 	return newShell, nil
 }
 
-// forwardShellOutput listens to the dedicated shell manager's output channel
-// and forwards it to the pane's own channel. This is the new, clean abstraction.
-func (pm *PaneManager) forwardShellOutput() {
+// forwardShellOutput relays output from the dedicated shell manager to the
+// pane's subscribers, tagged with the pane ID, until the shells terminate.
+func (pm *PaneManager) forwardShellOutput(shellOutput <-chan shell.PaneOutput) {
+	for output := range shellOutput {
+		pm.matchOutputProbes(output.ShellID, output.Data)
+		// Convert shell.PaneOutput to pane.PaneOutput
+		pm.output.Publish(PaneOutput{
+			PaneID:    pm.ID,
+			ShellID:   output.ShellID,
+			Timestamp: output.Timestamp,
+			Data:      output.Data,
+			IsStderr:  output.IsStderr})
+	}
+}
+
+// Subscribe returns a new, independent stream of output from every shell in
+// the pane. Each subscriber has its own buffer and backpressure policy; the
+// channel is closed when the subscription is cancelled or the pane terminates.
+func (pm *PaneManager) Subscribe(opts fanout.Options) (<-chan PaneOutput, *fanout.Subscription) {
+	return pm.output.Subscribe(opts)
+}
+
+// Lines returns a stream of whole lines from every shell in the pane, with
+// partial lines flushed after opts.FlushTimeout and multi-byte characters
+// kept intact. The channel is closed when the subscription is cancelled or
+// the pane terminates.
+func (pm *PaneManager) Lines(opts shell.LineOptions) (<-chan shell.Line, *fanout.Subscription) {
+	return pm.Shells.Lines(opts)
+}
+
+// forwardShellExits relays exit notifications from the shell manager to the
+// pane's ExitChan, closing the exited shell's log on the way. It owns
+// ExitChan and closes it when the pane terminates. Like the shell manager,
+// it drops the oldest notification rather than wait for a reader.
+func (pm *PaneManager) forwardShellExits() {
+	defer close(pm.ExitChan)
 	for {
 		select {
-		case output, ok := <-pm.Shells.OutputChan:
-			if !ok {
-				return // ShellManager's channel was closed.
+		case status := <-pm.Shells.ExitChan:
+			pm.closeShellLog(status.ShellID)
+			for sent := false; !sent; {
+				select {
+				case pm.ExitChan <- status:
+					sent = true
+				default:
+					select {
+					case <-pm.ExitChan:
+					default:
+					}
+				}
 			}
-			// Convert shell.PaneOutput to pane.PaneOutput
-			pm.OutputChan <- PaneOutput{
-				ShellID:   output.ShellID,
-				Timestamp: output.Timestamp,
-				Data:      output.Data,
-				IsStderr:  output.IsStderr}
 		case <-pm.closeChan:
 			return
 		}
@@ -77,7 +135,7 @@ func (pm *PaneManager) AddTag(key, value string) {
 	pm.tagsMu.Lock()
 	defer pm.tagsMu.Unlock()
 
-	fmt.Printf("MILESTONE: Pane %s tagged '%s' = '%s'\n", pm.ID, key, value)
+	pm.log().Info("pane tagged", "key", key, "value", value)
 	pm.Tags[key] = value
 
 	// Wake up all goroutines waiting on a tag change.
@@ -86,31 +144,53 @@ func (pm *PaneManager) AddTag(key, value string) {
 
 // WaitForTag blocks until a specific tag has a specific value, or until the timeout is reached.
 func (pm *PaneManager) WaitForTag(key, value string, timeout time.Duration) error {
+	deadline := time.Now().Add(timeout)
+	// Wake the waiter once the timeout expires, so it can give up.
+	timer := time.AfterFunc(timeout, func() {
+		pm.tagsMu.Lock()
+		defer pm.tagsMu.Unlock()
+		pm.tagsCond.Broadcast()
+	})
+	defer timer.Stop()
+
 	pm.tagsMu.Lock()
 	defer pm.tagsMu.Unlock()
-
-	// Channel to signal when the condition is met or timed out.
-	done := make(chan struct{})
-
-	go func() {
-		// This loop is the core of the waiting logic.
-		for pm.Tags[key] != value {
-			// cond.Wait() atomically unlocks the mutex and waits for a signal.
-			// When woken up, it re-locks the mutex before proceeding.
-			pm.tagsCond.Wait()
+	for pm.Tags[key] != value {
+		if !time.Now().Before(deadline) {
+			return fmt.Errorf("timed out waiting for tag '%s' = '%s' on pane %s", key, value, pm.ID)
 		}
-		// The condition is met, close the channel to unblock the select.
-		close(done)
-	}()
+		// cond.Wait() atomically unlocks the mutex and waits for a signal.
+		// When woken up, it re-locks the mutex before proceeding.
+		pm.tagsCond.Wait()
+	}
+	return nil
+}
 
-	select {
-	case <-done:
-		// The tag was found.
-		return nil
-	case <-time.After(timeout):
-		// The timeout was reached.
-		return fmt.Errorf("timed out waiting for tag '%s' = '%s' on pane %s", key, value, pm.ID)
+// SetLogger sets the structured logger for the pane and its shells. Records
+// carry a pane_id attribute; a nil logger discards them.
+func (pm *PaneManager) SetLogger(logger *slog.Logger) {
+	if logger == nil {
+		logger = slog.New(slog.DiscardHandler)
 	}
+	pm.Shells.SetLogger(logger.With("pane_id", pm.ID))
+}
+
+// log returns the pane's logger, which its shell manager holds.
+func (pm *PaneManager) log() *slog.Logger {
+	return pm.Shells.Logger()
+}
+
+// SetEcho copies everything the pane's shells print to w, such as os.Stdout.
+// A nil writer turns the echo off, which is the default.
+func (pm *PaneManager) SetEcho(w io.Writer) {
+	pm.Shells.SetEcho(w)
+}
+
+// SetFilters sets the output filter chain for every shell in the pane,
+// including shells spawned later, such as shell.StripANSIFilter or
+// shell.RedactEnvFilter.
+func (pm *PaneManager) SetFilters(filters ...shell.Filter) {
+	pm.Shells.SetFilters(filters...)
 }
 
 // SendCommand delegates to the pane's shell manager to send a command to a specific shell.
@@ -119,6 +199,188 @@ func (pm *PaneManager) SendCommand(shellID, command string) error {
 	return err
 }
 
+// History returns the commands sent to every shell in the pane, in the
+// order they were sent.
+func (pm *PaneManager) History() []shell.HistoryEntry {
+	var history []shell.HistoryEntry
+	for _, s := range pm.Shells.List() {
+		history = append(history, s.History()...)
+	}
+	shell.SortHistory(history)
+	return history
+}
+
+// ExportHistory writes the pane's history to w as JSON, for replaying what
+// was typed into it.
+func (pm *PaneManager) ExportHistory(w io.Writer) error {
+	return shell.WriteHistory(w, pm.History())
+}
+
+// Signal delivers sig to the foreground of a specific shell in the pane.
+func (pm *PaneManager) Signal(shellID string, sig os.Signal) error {
+	return pm.Shells.Signal(shellID, sig)
+}
+
+// Interrupt sends SIGINT to a specific shell in the pane, like Ctrl-C.
+func (pm *PaneManager) Interrupt(shellID string) error {
+	return pm.Shells.Interrupt(shellID)
+}
+
+// Suspend sends SIGTSTP to a specific shell in the pane, like Ctrl-Z.
+func (pm *PaneManager) Suspend(shellID string) error {
+	return pm.Shells.Suspend(shellID)
+}
+
+// Resume continues a suspended shell in the pane.
+func (pm *PaneManager) Resume(shellID string) error {
+	return pm.Shells.Resume(shellID)
+}
+
+// SendControl sends the control character for key to a specific shell in the pane.
+func (pm *PaneManager) SendControl(shellID string, key byte) error {
+	return pm.Shells.SendControl(shellID, key)
+}
+
+// SendKeys types tmux-style keys, such as "C-c" or "Up", into a specific
+// shell in the pane.
+func (pm *PaneManager) SendKeys(shellID string, keys ...string) error {
+	return pm.Shells.SendKeys(shellID, keys...)
+}
+
+// Attach connects the local terminal to the pane's interactive shell until
+// the detach keys are typed, like tmux attach. The shell keeps running.
+func (pm *PaneManager) Attach(ctx context.Context, in *os.File, out io.Writer, opts shell.AttachOptions) error {
+	if pm.InteractiveShell == nil {
+		return fmt.Errorf("pane %s has no interactive shell", pm.ID)
+	}
+	return pm.InteractiveShell.Attach(ctx, in, out, opts)
+}
+
+// Resize sets the pane's terminal size. Every interactive shell in the pane is
+// resized immediately, and shells spawned later start with this size.
+func (pm *PaneManager) Resize(rows, cols uint16) error {
+	if err := pm.Shells.Resize(rows, cols); err != nil {
+		return fmt.Errorf("failed to resize pane %s: %w", pm.ID, err)
+	}
+	return nil
+}
+
+// SetScrollbackLimits bounds the output retained by every shell in the pane,
+// including shells spawned later.
+func (pm *PaneManager) SetScrollbackLimits(limits shell.ScrollbackLimits) {
+	pm.Shells.SetScrollbackLimits(limits)
+}
+
+// SetResourceLimits sets the setrlimit limits of shells spawned in the pane
+// from now on.
+func (pm *PaneManager) SetResourceLimits(limits shell.ResourceLimits) {
+	pm.Shells.SetResourceLimits(limits)
+}
+
+// SetCgroup runs shells spawned in the pane from now on in group, so the
+// whole pane shares its memory and CPU limits.
+func (pm *PaneManager) SetCgroup(group *cgroup.Group) {
+	pm.Shells.SetCgroup(group)
+}
+
+// SetShellPool makes the pane take new shells from pool, when it has a ready
+// shell for the same command, directory and environment, instead of
+// spawning them. A nil pool turns this off.
+func (pm *PaneManager) SetShellPool(pool *shell.ShellPool) {
+	pm.Shells.SetPool(pool)
+}
+
+// CgroupUsage reads the usage of the pane's cgroup, set with SetCgroup.
+func (pm *PaneManager) CgroupUsage() (*cgroup.Usage, error) {
+	group := pm.Shells.Cgroup()
+	if group == nil {
+		return nil, fmt.Errorf("pane %s has no cgroup", pm.ID)
+	}
+	return group.Usage()
+}
+
+// Screen returns a snapshot of what the pane's interactive shell is
+// displaying right now, including colors and cursor position.
+func (pm *PaneManager) Screen() (screen.Snapshot, error) {
+	if pm.InteractiveShell == nil {
+		return screen.Snapshot{}, fmt.Errorf("pane %s has no interactive shell", pm.ID)
+	}
+	return pm.InteractiveShell.Screen()
+}
+
+// Capture returns the visible text of the pane's interactive shell, like
+// tmux's capture-pane.
+func (pm *PaneManager) Capture() (string, error) {
+	snap, err := pm.Screen()
+	if err != nil {
+		return "", err
+	}
+	return snap.Text(), nil
+}
+
+// Inspect reads the process trees of every running shell in the pane from
+// /proc and totals their CPU time, memory and open files, so tooling can show
+// which pane is busy. Shells that have already exited are skipped.
+func (pm *PaneManager) Inspect() (*PaneInspection, error) {
+	inspection := &PaneInspection{PaneID: pm.ID, Name: pm.Name, SampledAt: time.Now()}
+	var errs []error
+	for _, s := range pm.Shells.List() {
+		info, err := s.Inspect()
+		if errors.Is(err, os.ErrProcessDone) {
+			continue
+		}
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		inspection.Shells = append(inspection.Shells, ShellInspection{ShellID: s.ID, Interactive: s.Interactive, Process: info})
+
+		cpu, rss, openFiles, processes := info.Totals()
+		inspection.CPUTime += cpu
+		inspection.RSS += rss
+		inspection.OpenFiles += openFiles
+		inspection.Processes += processes
+	}
+	return inspection, errors.Join(errs...)
+}
+
+// Record starts recording the pane to w as an asciicast v2 stream. A pane
+// with an interactive shell records that shell, including input and
+// resizes; otherwise the output of every shell in the pane is recorded as
+// delivered to Subscribe. The returned function stops the recording; it
+// does not close w.
+func (pm *PaneManager) Record(w io.Writer) (stop func() error, err error) {
+	if pm.InteractiveShell != nil {
+		return asciicast.RecordShell(pm.InteractiveShell, w)
+	}
+
+	size := pm.Shells.Size()
+	header := asciicast.Header{Width: int(size.Cols), Height: int(size.Rows), Title: pm.Name}
+	if header.Width == 0 || header.Height == 0 {
+		header.Width, header.Height = 80, 24
+	}
+	rec, err := asciicast.NewRecorder(w, header)
+	if err != nil {
+		return nil, err
+	}
+	// A recording may not lose output, like the logs.
+	output, sub := pm.Subscribe(fanout.Options{Policy: fanout.Block})
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for out := range output {
+			if err := rec.Record(out.Timestamp, asciicast.EventOutput, out.Data); err != nil {
+				pm.log().Warn("failed to record pane output", "shell_id", out.ShellID, "error", err)
+			}
+		}
+	}()
+	return func() error {
+		sub.Cancel()
+		<-done
+		return rec.Close()
+	}, nil
+}
+
 // TerminateShell attempts a graceful shutdown of a specific shell session.
 func (pm *PaneManager) TerminateShell(shellID string, gracePeriod time.Duration) (bool, error) {
 	// Delegate termination to the pane's shell manager.
@@ -130,12 +392,19 @@ func (pm *PaneManager) TerminateShell(shellID string, gracePeriod time.Duration)
 	return true, pm.Shells.TerminateShell(shellID)
 }
 
-// TerminatePane cleans up all shells in the pane by gracefully shutting them down.
-func (pm *PaneManager) TerminatePane(gracePeriod time.Duration) {
-	// Signal to all forwarding handlers that they should stop sending to OutputChan.
+// TerminatePane cleans up all shells in the pane by gracefully shutting them down,
+// reporting any processes they left behind.
+func (pm *PaneManager) TerminatePane(gracePeriod time.Duration) error {
+	// Signal to all forwarding handlers that they should stop.
 	close(pm.closeChan)
-	pm.Shells.TerminateAllShells()
+	err := pm.Shells.TerminateAllShells()
 
-	// Close the main output channel to signal the end of the stream.
-	close(pm.OutputChan)
+	// Close every output subscription, including OutputChan, to signal the
+	// end of the stream, and let the logs write what they have left.
+	pm.output.Close()
+	err = errors.Join(err, pm.stopLogs())
+	if err != nil {
+		return fmt.Errorf("pane %s: %w", pm.ID, err)
+	}
+	return nil
 }
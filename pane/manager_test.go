@@ -1,13 +1,22 @@
 package pane_test
 
 import (
+	"bytes"
+	"context"
+	"encoding/json"
 	"fmt"
+	"os"
+	"path/filepath"
 	"strings"
 	"sync"
 	"testing"
 	"time"
 
+	"github.com/owen-6936/termplex/asciicast"
+	"github.com/owen-6936/termplex/fanout"
+	"github.com/owen-6936/termplex/logfile"
 	"github.com/owen-6936/termplex/pane"
+	"github.com/owen-6936/termplex/shell"
 	"github.com/owen-6936/termplex/window"
 )
 
@@ -15,7 +24,7 @@ func TestPaneOutputMultiplexing(t *testing.T) {
 	// 1. Initialize a PaneManager.
 	pm := pane.NewPaneManager("test-mux-pane", "multiplexer")
 	// Ensure the pane and its resources are cleaned up when the test finishes.
-	t.Cleanup(func() { pm.TerminatePane(2 * time.Second) })
+	t.Cleanup(func() { _ = pm.TerminatePane(2 * time.Second) })
 
 	const numShells = 5
 	// We expect two outputs (stdout, stderr) from each shell.
@@ -85,7 +94,7 @@ func TestPaneOutputMultiplexing(t *testing.T) {
 func TestPaneManagerSingleInteractiveShellRule(t *testing.T) {
 	// 1. Initialize a PaneManager.
 	pm := pane.NewPaneManager("test-interactive-rule-pane", "interactive-tester")
-	t.Cleanup(func() { pm.TerminatePane(2 * time.Second) })
+	t.Cleanup(func() { _ = pm.TerminatePane(2 * time.Second) })
 
 	// 2. Successfully spawn the first interactive shell.
 	firstShell, err := pm.SpawnShell(true, "bash", "-i")
@@ -122,7 +131,7 @@ func TestPaneOutputMetadata(t *testing.T) {
 
 	// 1. Initialize a PaneManager.
 	pm := pane.NewPaneManager("test-metadata-pane", "metadata-tester")
-	t.Cleanup(func() { pm.TerminatePane(2 * time.Second) })
+	t.Cleanup(func() { _ = pm.TerminatePane(2 * time.Second) })
 
 	// 2. Spawn two distinct shells.
 	shell1, err := pm.SpawnShell(false, "bash", "-c", "echo 'out 1'; >&2 echo 'err 1'")
@@ -182,7 +191,7 @@ func TestInteractiveShellOutput(t *testing.T) {
 
 	// 1. Create a single pane.
 	pm := pane.NewPaneManager("test-interactive-pane", "interactive-output-tester")
-	t.Cleanup(func() { pm.TerminatePane(2 * time.Second) })
+	t.Cleanup(func() { _ = pm.TerminatePane(2 * time.Second) })
 
 	// 2. Spawn one interactive shell.
 	shell, err := pm.SpawnShell(true, "bash", "-i")
@@ -230,7 +239,7 @@ func TestSendCommandToShellInPane(t *testing.T) {
 	if !exists {
 		t.Fatalf("Failed to find pane with ID %s", pmId)
 	}
-	t.Cleanup(func() { pm.TerminatePane(2 * time.Second) })
+	t.Cleanup(func() { _ = pm.TerminatePane(2 * time.Second) })
 
 	// 3. Spawn an interactive shell inside the pane.
 	shell, err := pm.SpawnShell(true, "bash", "-i")
@@ -266,3 +275,409 @@ func TestSendCommandToShellInPane(t *testing.T) {
 		}
 	}
 }
+
+func TestPaneForwardsShellExit(t *testing.T) {
+	pm := pane.NewPaneManager("test-exit-pane", "exit-tester")
+	t.Cleanup(func() { _ = pm.TerminatePane(2 * time.Second) })
+
+	// A background server that dies should be reported without polling.
+	server, err := pm.SpawnShell(false, "bash", "-c", "exit 7")
+	if err != nil {
+		t.Fatalf("Failed to spawn shell: %v", err)
+	}
+
+	select {
+	case status := <-pm.ExitChan:
+		if status.ShellID != server.ID {
+			t.Errorf("Expected exit for shell %s, got %s", server.ID, status.ShellID)
+		}
+		if status.Code != 7 {
+			t.Errorf("Expected exit code 7, got %d", status.Code)
+		}
+	case <-time.After(3 * time.Second):
+		t.Fatal("Timed out waiting for the pane to forward the exit")
+	}
+}
+
+func TestPaneRecordsInteractiveShell(t *testing.T) {
+	pm := pane.NewPaneManager("test-record-pane", "recorder")
+	t.Cleanup(func() { _ = pm.TerminatePane(2 * time.Second) })
+
+	sh, err := pm.SpawnShell(true, "bash", "--norc", "-i")
+	if err != nil {
+		t.Fatalf("Failed to spawn shell: %v", err)
+	}
+
+	// 1. Record a command and its output.
+	var buf bytes.Buffer
+	stop, err := pm.Record(&buf)
+	if err != nil {
+		t.Fatalf("Failed to start recording: %v", err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if _, err := sh.Run(ctx, "echo recorded-$((6 * 7))"); err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	if err := stop(); err != nil {
+		t.Fatalf("Failed to stop recording: %v", err)
+	}
+
+	// 2. The recording holds the typed command as input and its result as output.
+	cast, err := asciicast.Decode(&buf)
+	if err != nil {
+		t.Fatalf("Failed to decode recording: %v", err)
+	}
+	if cast.Header.Width != 80 || cast.Header.Height != 24 {
+		t.Errorf("Expected the default 80x24 size in the header, got %dx%d", cast.Header.Width, cast.Header.Height)
+	}
+	var input, output strings.Builder
+	for _, e := range cast.Events {
+		switch e.Type {
+		case asciicast.EventInput:
+			input.WriteString(e.Data)
+		case asciicast.EventOutput:
+			output.WriteString(e.Data)
+		}
+	}
+	if !strings.Contains(input.String(), "echo recorded-$((6 * 7))") {
+		t.Errorf("Expected the command in the input events, got %q", input.String())
+	}
+	if !strings.Contains(output.String(), "recorded-42") {
+		t.Errorf("Expected the command's output in the output events, got %q", output.String())
+	}
+}
+
+func TestPaneRecordsBackgroundShells(t *testing.T) {
+	pm := pane.NewPaneManager("test-record-bg-pane", "workers")
+	t.Cleanup(func() { _ = pm.TerminatePane(2 * time.Second) })
+
+	// 1. A pane without an interactive shell records its shells' output.
+	var buf bytes.Buffer
+	stop, err := pm.Record(&buf)
+	if err != nil {
+		t.Fatalf("Failed to start recording: %v", err)
+	}
+	worker, err := pm.SpawnShell(false, "sh", "-c", "echo worker-output; echo worker-error >&2")
+	if err != nil {
+		t.Fatalf("Failed to spawn shell: %v", err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if _, err := worker.Wait(ctx); err != nil {
+		t.Fatalf("Wait failed: %v", err)
+	}
+	time.Sleep(100 * time.Millisecond) // Let the last output reach the recording.
+	if err := stop(); err != nil {
+		t.Fatalf("Failed to stop recording: %v", err)
+	}
+
+	// 2. Both streams are in the output events, under the pane's name.
+	cast, err := asciicast.Decode(&buf)
+	if err != nil {
+		t.Fatalf("Failed to decode recording: %v", err)
+	}
+	if cast.Header.Title != "workers" || cast.Header.Width != 80 || cast.Header.Height != 24 {
+		t.Errorf("Unexpected header %+v", cast.Header)
+	}
+	var output strings.Builder
+	for _, e := range cast.Events {
+		if e.Type == asciicast.EventOutput {
+			output.WriteString(e.Data)
+		}
+	}
+	for _, want := range []string{"worker-output\n", "worker-error\n"} {
+		if !strings.Contains(output.String(), want) {
+			t.Errorf("Expected %q in the output events, got %q", want, output.String())
+		}
+	}
+}
+
+func TestPaneInspectAggregatesShells(t *testing.T) {
+	pm := pane.NewPaneManager("test-inspect-pane", "inspector")
+	t.Cleanup(func() { _ = pm.TerminatePane(2 * time.Second) })
+
+	// A busy worker and an idle one.
+	busy, err := pm.SpawnShell(false, "sh", "-c", "while :; do :; done")
+	if err != nil {
+		t.Fatalf("Failed to spawn shell: %v", err)
+	}
+	if _, err := pm.SpawnShell(false, "sleep", "30"); err != nil {
+		t.Fatalf("Failed to spawn shell: %v", err)
+	}
+	time.Sleep(300 * time.Millisecond)
+
+	inspection, err := pm.Inspect()
+	if err != nil {
+		t.Fatalf("Inspect failed: %v", err)
+	}
+	if len(inspection.Shells) != 2 || inspection.Processes != 2 {
+		t.Fatalf("Expected 2 shells with 2 processes, got %d shells, %d processes", len(inspection.Shells), inspection.Processes)
+	}
+	if inspection.Shells[0].ShellID != busy.ID {
+		t.Errorf("Expected shells ordered by start time, got %s first", inspection.Shells[0].ShellID)
+	}
+	if busyCPU := inspection.Shells[0].Process.CPUTime(); busyCPU == 0 || inspection.CPUTime < busyCPU {
+		t.Errorf("Expected the busy shell's CPU time in the pane total, got %v of %v", busyCPU, inspection.CPUTime)
+	}
+	if inspection.RSS <= 0 {
+		t.Errorf("Expected the pane's memory to be totalled, got %d", inspection.RSS)
+	}
+}
+
+func TestPaneStreamsOutputAcrossRestarts(t *testing.T) {
+	pm := pane.NewPaneManager("test-restart-pane", "supervisor")
+	t.Cleanup(func() { _ = pm.TerminatePane(2 * time.Second) })
+
+	worker, err := pm.SpawnShellWithOptions(shell.SpawnOptions{
+		Command: []string{"sh", "-c", "echo tick; exit 1"},
+		Restart: &shell.RestartOptions{Policy: shell.RestartOnFailure, InitialBackoff: 10 * time.Millisecond, MaxRestarts: 2},
+	})
+	if err != nil {
+		t.Fatalf("Failed to spawn shell: %v", err)
+	}
+
+	// Every run of the worker prints on the same pane stream under the same shell ID.
+	ticks := 0
+	timeout := time.After(5 * time.Second)
+	for ticks < 3 {
+		select {
+		case out := <-pm.OutputChan:
+			if out.ShellID != worker.ID {
+				t.Errorf("Expected output from shell %s, got %s", worker.ID, out.ShellID)
+			}
+			ticks += strings.Count(string(out.Data), "tick")
+		case <-timeout:
+			t.Fatalf("Expected 3 ticks across restarts, got %d", ticks)
+		}
+	}
+	if worker.Restarts() != 2 {
+		t.Errorf("Expected 2 restarts, got %d", worker.Restarts())
+	}
+}
+
+func TestPaneSubscribersAreIndependent(t *testing.T) {
+	pm := pane.NewPaneManager("test-subscribe-pane", "fanout")
+	t.Cleanup(func() { _ = pm.TerminatePane(2 * time.Second) })
+
+	// 1. One subscriber never reads; another reads everything. Nobody reads OutputChan.
+	_, stalled := pm.Subscribe(fanout.Options{Buffer: 1, Policy: fanout.DropNewest})
+	live, liveSub := pm.Subscribe(fanout.Options{Buffer: 1000, Policy: fanout.Block})
+
+	sh, err := pm.SpawnShell(false, "bash", "-c", "for i in $(seq 1 300); do echo line-$i; done")
+	if err != nil {
+		t.Fatalf("Failed to spawn shell: %v", err)
+	}
+
+	// 2. The shell is not held up by the stalled subscribers and runs to completion.
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if _, err := sh.Wait(ctx); err != nil {
+		t.Fatalf("Shell stalled behind a slow subscriber: %v", err)
+	}
+
+	// 3. The live subscriber sees every line, tagged with the pane ID.
+	var received strings.Builder
+	for !strings.Contains(received.String(), "line-300\n") {
+		select {
+		case out := <-live:
+			if out.PaneID != pm.ID {
+				t.Errorf("Expected pane ID %s, got %s", pm.ID, out.PaneID)
+			}
+			received.Write(out.Data)
+		case <-ctx.Done():
+			t.Fatalf("Live subscriber missed output; got %d bytes", received.Len())
+		}
+	}
+	if !strings.HasPrefix(received.String(), "line-1\n") {
+		t.Errorf("Expected the live subscriber to start at line-1")
+	}
+	if stalled.Dropped() == 0 {
+		t.Error("Expected the stalled subscriber to count dropped chunks")
+	}
+
+	// 4. Cancelling closes only that subscription.
+	liveSub.Cancel()
+	if _, ok := <-live; ok {
+		t.Error("Expected the cancelled subscription to be closed")
+	}
+}
+
+func TestPaneHistoryMergesShells(t *testing.T) {
+	pm := pane.NewPaneManager("test-history-pane", "history")
+	t.Cleanup(func() { _ = pm.TerminatePane(2 * time.Second) })
+
+	// 1. Send commands to two shells, alternating between them.
+	first, err := pm.SpawnShell(false, "bash")
+	if err != nil {
+		t.Fatalf("Failed to spawn shell: %v", err)
+	}
+	second, err := pm.SpawnShell(false, "bash")
+	if err != nil {
+		t.Fatalf("Failed to spawn shell: %v", err)
+	}
+	for i, s := range []*shell.ShellSession{first, second, first} {
+		if err := pm.SendCommand(s.ID, fmt.Sprintf("echo %d", i)); err != nil {
+			t.Fatalf("Failed to send command: %v", err)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	// 2. The pane's history interleaves both shells in the order sent.
+	history := pm.History()
+	if len(history) != 3 {
+		t.Fatalf("Expected 3 history entries, got %d", len(history))
+	}
+	for i, want := range []string{first.ID, second.ID, first.ID} {
+		if history[i].ShellID != want || history[i].Command != fmt.Sprintf("echo %d", i) {
+			t.Errorf("Entry %d: got %+v", i, history[i])
+		}
+	}
+
+	// 3. The export carries every entry.
+	var buf bytes.Buffer
+	if err := pm.ExportHistory(&buf); err != nil {
+		t.Fatalf("ExportHistory failed: %v", err)
+	}
+	if strings.Count(buf.String(), `"command"`) != 3 {
+		t.Errorf("Expected 3 exported commands, got %s", buf.String())
+	}
+}
+
+func TestPaneLogsOutputToDisk(t *testing.T) {
+	dir := t.TempDir()
+	pm := pane.NewPaneManager("test-logs-pane", "logs")
+	if err := pm.EnableLogs(pane.LogOptions{Dir: dir, Rotation: logfile.Options{MaxBytes: 64}, NDJSON: true}); err != nil {
+		t.Fatalf("EnableLogs failed: %v", err)
+	}
+
+	// 1. Two shells print enough to rotate their logs.
+	first, err := pm.SpawnShell(false, "sh", "-c", "for i in 1 2 3 4 5 6; do echo first-line-$i; sleep 0.01; done")
+	if err != nil {
+		t.Fatalf("Failed to spawn shell: %v", err)
+	}
+	second, err := pm.SpawnShell(false, "sh", "-c", "echo oops >&2")
+	if err != nil {
+		t.Fatalf("Failed to spawn shell: %v", err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	for _, s := range []*shell.ShellSession{first, second} {
+		if _, err := s.Wait(ctx); err != nil {
+			t.Fatalf("Wait failed: %v", err)
+		}
+	}
+	time.Sleep(100 * time.Millisecond) // Let the last output reach the logs.
+
+	// 2. Closing the pane flushes and closes every log.
+	logDir := pm.LogDir()
+	if logDir != filepath.Join(dir, pm.ID) {
+		t.Errorf("Unexpected log directory %s", logDir)
+	}
+	if err := pm.TerminatePane(2 * time.Second); err != nil {
+		t.Fatalf("TerminatePane failed: %v", err)
+	}
+
+	firstLog := filepath.Join(logDir, first.ID+".log")
+	backups, _ := filepath.Glob(firstLog + ".*")
+	if len(backups) == 0 {
+		t.Errorf("Expected the first shell's log to rotate")
+	}
+	var all strings.Builder
+	for _, path := range append(backups, firstLog) {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatalf("Failed to read log: %v", err)
+		}
+		all.Write(data)
+	}
+	for i := 1; i <= 6; i++ {
+		if !strings.Contains(all.String(), fmt.Sprintf("first-line-%d\n", i)) {
+			t.Errorf("Expected line %d in the first shell's logs, got %q", i, all.String())
+		}
+	}
+	if data, _ := os.ReadFile(filepath.Join(logDir, second.ID+".log")); string(data) != "oops\n" {
+		t.Errorf("Unexpected second shell log %q", data)
+	}
+
+	// 3. The NDJSON log records which shell printed what, on which stream.
+	// It rotates like the plain-text logs.
+	ndjsonLog := filepath.Join(logDir, pane.NDJSONLogName)
+	ndjsonFiles, _ := filepath.Glob(ndjsonLog + ".*")
+	var ndjson []byte
+	for _, path := range append(ndjsonFiles, ndjsonLog) {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatalf("Failed to read NDJSON log: %v", err)
+		}
+		ndjson = append(ndjson, data...)
+	}
+	var sawStderr bool
+	for _, line := range strings.Split(strings.TrimSpace(string(ndjson)), "\n") {
+		var record pane.LogRecord
+		if err := json.Unmarshal([]byte(line), &record); err != nil {
+			t.Fatalf("Invalid NDJSON line %q: %v", line, err)
+		}
+		if record.ShellID == second.ID && record.IsStderr && record.Data == "oops\n" && !record.Timestamp.IsZero() {
+			sawStderr = true
+		}
+	}
+	if !sawStderr {
+		t.Errorf("Expected the second shell's stderr in the NDJSON log, got %s", ndjson)
+	}
+}
+
+func TestPaneLogsCloseWhenShellsExit(t *testing.T) {
+	dir := t.TempDir()
+	pm := pane.NewPaneManager("test-logs-exit-pane", "logs")
+	t.Cleanup(func() { _ = pm.TerminatePane(time.Second) })
+	if err := pm.EnableLogs(pane.LogOptions{Dir: dir}); err != nil {
+		t.Fatalf("EnableLogs failed: %v", err)
+	}
+
+	// 1. Several short-lived shells each write a log and exit.
+	var ids []string
+	for i := range 5 {
+		s, err := pm.SpawnShell(false, "sh", "-c", fmt.Sprintf("echo run-%d", i))
+		if err != nil {
+			t.Fatalf("Failed to spawn shell: %v", err)
+		}
+		ids = append(ids, s.ID)
+	}
+
+	// 2. Every run reaches its log.
+	logged := func() bool {
+		for i, id := range ids {
+			data, _ := os.ReadFile(filepath.Join(dir, pm.ID, id+".log"))
+			if string(data) != fmt.Sprintf("run-%d\n", i) {
+				return false
+			}
+		}
+		return true
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for !logged() && time.Now().Before(deadline) {
+		time.Sleep(20 * time.Millisecond)
+	}
+	if !logged() {
+		t.Fatalf("Expected every shell's output in its log")
+	}
+
+	// 3. Once their exits are seen, none of their logs is held open.
+	openLogs := func() int {
+		n := 0
+		entries, _ := os.ReadDir("/proc/self/fd")
+		for _, e := range entries {
+			if target, err := os.Readlink(filepath.Join("/proc/self/fd", e.Name())); err == nil && strings.HasPrefix(target, dir) {
+				n++
+			}
+		}
+		return n
+	}
+	for openLogs() > 0 && time.Now().Before(deadline) {
+		time.Sleep(20 * time.Millisecond)
+	}
+	if n := openLogs(); n != 0 {
+		t.Errorf("Expected every exited shell's log to be closed, %d still open", n)
+	}
+}
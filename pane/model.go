@@ -4,18 +4,40 @@ import (
 	"sync"
 	"time"
 
+	"github.com/owen-6936/termplex/fanout"
 	"github.com/owen-6936/termplex/shell"
 )
 
 // PaneOutput represents a piece of output from a shell within a pane,
 // providing context about its origin.
 type PaneOutput struct {
+	PaneID    string
 	ShellID   string
 	Timestamp time.Time
 	Data      []byte
 	IsStderr  bool
 }
 
+// ShellInspection is the process tree of one shell in a pane.
+type ShellInspection struct {
+	ShellID     string
+	Interactive bool
+	Process     *shell.ProcessInfo // The shell process, with everything it started as children.
+}
+
+// PaneInspection aggregates the processes of every running shell in a pane.
+// CPU times are cumulative; comparing two samples by SampledAt gives usage.
+type PaneInspection struct {
+	PaneID    string
+	Name      string
+	SampledAt time.Time
+	Shells    []ShellInspection
+	CPUTime   time.Duration // Total CPU time of every process in the pane.
+	RSS       int64         // Total resident memory in bytes.
+	OpenFiles int           // Total open file descriptors that could be counted.
+	Processes int
+}
+
 // PaneManager represents a multitasking workspace within a window.
 // It can host one interactive shell and multiple non-interactive shells.
 type PaneManager struct {
@@ -24,9 +46,15 @@ type PaneManager struct {
 	CreatedAt        time.Time
 	Shells           *shell.ShellManager // Each pane now has its own dedicated shell manager.
 	InteractiveShell *shell.ShellSession
-	Tags             map[string]string // Optional metadata (e.g. task, env, owner)
-	tagsMu           sync.Mutex        // Mutex to protect the Tags map.
-	tagsCond         *sync.Cond        // Condition variable to signal tag changes.
-	OutputChan       chan PaneOutput   // A multiplexed stream of output from all shells in this pane.
-	closeChan        chan struct{}     // Signal to close the output channel and stop forwarding handlers.
+	Tags             map[string]string     // Optional metadata (e.g. task, env, owner)
+	tagsMu           sync.Mutex            // Mutex to protect the Tags map.
+	tagsCond         *sync.Cond            // Condition variable to signal tag changes.
+	OutputChan       <-chan PaneOutput     // Legacy single-consumer stream of all output; drops the oldest chunk when full.
+	ExitChan         chan shell.ExitStatus // Exit notifications for every shell in this pane; the oldest is dropped when full.
+	closeChan        chan struct{}         // Signal to close the output channel and stop forwarding handlers.
+	probesMu         sync.Mutex            // Protects outputProbes.
+	outputProbes     []*outputProbe        // Output probes that have not passed yet.
+	logsMu           sync.Mutex            // Protects logs.
+	logs             *outputLog            // Persists output to disk; nil when logging is off.
+	output           fanout.Hub[PaneOutput]
 }
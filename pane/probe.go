@@ -0,0 +1,169 @@
+package pane
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"os/exec"
+	"regexp"
+	"strconv"
+	"time"
+)
+
+const (
+	// DefaultProbeTag and DefaultProbeValue are the tag a probe sets when it
+	// passes, unless it names its own.
+	DefaultProbeTag   = "status"
+	DefaultProbeValue = "ready"
+
+	defaultProbeInterval = 250 * time.Millisecond
+	defaultProbeTimeout  = time.Second
+	// probeTailSize is how much recent output per shell an output probe keeps,
+	// so a match split across reads is still found.
+	probeTailSize = 4096
+)
+
+// Probe is a readiness check on a pane. Exactly one of Output, Command, Port
+// and File must be set. When the check passes the pane sets Tag to Value, which
+// wakes anyone blocked in WaitForTag; the probe then stops.
+type Probe struct {
+	Output  *regexp.Regexp // Passes when output from any shell in the pane matches.
+	Command []string       // Passes when the command exits with status 0.
+	Port    int            // Passes when a TCP port on localhost accepts connections.
+	File    string         // Passes when the file exists.
+
+	Tag   string // Defaults to DefaultProbeTag.
+	Value string // Defaults to DefaultProbeValue.
+	// Interval is the delay between attempts of a polling probe. Defaults to 250ms.
+	Interval time.Duration
+	// Timeout bounds a single command run or connection attempt. Defaults to one second.
+	Timeout time.Duration
+}
+
+// withDefaults validates the probe and fills in its defaults.
+func (p Probe) withDefaults() (Probe, error) {
+	kinds := 0
+	for _, set := range []bool{p.Output != nil, len(p.Command) > 0, p.Port != 0, p.File != ""} {
+		if set {
+			kinds++
+		}
+	}
+	if kinds != 1 {
+		return p, errors.New("probe must set exactly one of Output, Command, Port and File")
+	}
+	if p.Port < 0 || p.Port > 65535 {
+		return p, fmt.Errorf("probe port %d out of range", p.Port)
+	}
+	if p.Tag == "" {
+		p.Tag = DefaultProbeTag
+	}
+	if p.Value == "" {
+		p.Value = DefaultProbeValue
+	}
+	if p.Interval <= 0 {
+		p.Interval = defaultProbeInterval
+	}
+	if p.Timeout <= 0 {
+		p.Timeout = defaultProbeTimeout
+	}
+	return p, nil
+}
+
+// check runs a single attempt of a polling probe.
+func (p Probe) check() bool {
+	switch {
+	case len(p.Command) > 0:
+		ctx, cancel := context.WithTimeout(context.Background(), p.Timeout)
+		defer cancel()
+		cmd := exec.CommandContext(ctx, p.Command[0], p.Command[1:]...)
+		cmd.WaitDelay = p.Timeout
+		return cmd.Run() == nil
+	case p.Port != 0:
+		conn, err := net.DialTimeout("tcp", net.JoinHostPort("localhost", strconv.Itoa(p.Port)), p.Timeout)
+		if err != nil {
+			return false
+		}
+		_ = conn.Close()
+		return true
+	case p.File != "":
+		_, err := os.Stat(p.File)
+		return err == nil
+	}
+	return false
+}
+
+// outputProbe is an output probe waiting for its pattern.
+type outputProbe struct {
+	Probe
+	tails map[string][]byte // Recent output of each shell.
+}
+
+// AddProbe starts a readiness probe on the pane. Output probes only see
+// output produced after they are added, so add them before spawning the shell
+// they watch. Polling probes run until they pass or the pane terminates.
+func (pm *PaneManager) AddProbe(p Probe) error {
+	p, err := p.withDefaults()
+	if err != nil {
+		return err
+	}
+
+	if p.Output != nil {
+		pm.probesMu.Lock()
+		pm.outputProbes = append(pm.outputProbes, &outputProbe{Probe: p, tails: make(map[string][]byte)})
+		pm.probesMu.Unlock()
+		return nil
+	}
+
+	go pm.poll(p)
+	return nil
+}
+
+// poll retries a polling probe until it passes or the pane terminates.
+func (pm *PaneManager) poll(p Probe) {
+	ticker := time.NewTicker(p.Interval)
+	defer ticker.Stop()
+	for {
+		if p.check() {
+			pm.probePassed(p)
+			return
+		}
+		select {
+		case <-ticker.C:
+		case <-pm.closeChan:
+			return
+		}
+	}
+}
+
+// matchOutputProbes feeds a chunk of shell output to the pane's output probes.
+func (pm *PaneManager) matchOutputProbes(shellID string, data []byte) {
+	pm.probesMu.Lock()
+	var passed []Probe
+	pending := pm.outputProbes[:0]
+	for _, op := range pm.outputProbes {
+		tail := append(op.tails[shellID], data...)
+		if op.Output.Match(tail) {
+			passed = append(passed, op.Probe)
+			continue
+		}
+		if len(tail) > probeTailSize {
+			tail = tail[len(tail)-probeTailSize:]
+		}
+		op.tails[shellID] = tail
+		pending = append(pending, op)
+	}
+	pm.outputProbes = pending
+	pm.probesMu.Unlock()
+
+	for _, p := range passed {
+		pm.probePassed(p)
+	}
+}
+
+// probePassed sets the probe's tag.
+func (pm *PaneManager) probePassed(p Probe) {
+	pm.log().Info("probe passed", "tag", p.Tag, "value", p.Value)
+	pm.AddTag(p.Tag, p.Value)
+}
@@ -0,0 +1,94 @@
+package pane_test
+
+import (
+	"net"
+	"os"
+	"path/filepath"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/owen-6936/termplex/pane"
+)
+
+func TestOutputProbeTagsPane(t *testing.T) {
+	pm := pane.NewPaneManager("test-output-probe", "probe")
+	t.Cleanup(func() { _ = pm.TerminatePane(2 * time.Second) })
+
+	// Drain the output stream, as a real consumer would.
+	go func() {
+		for range pm.OutputChan {
+		}
+	}()
+
+	// 1. An output probe with the default tag.
+	if err := pm.AddProbe(pane.Probe{Output: regexp.MustCompile(`listening on :\d+`)}); err != nil {
+		t.Fatalf("Failed to add probe: %v", err)
+	}
+	if _, err := pm.SpawnShell(false, "bash", "-c", "echo booting; sleep 0.2; printf 'listening'; sleep 0.1; echo ' on :8080'; sleep 5"); err != nil {
+		t.Fatalf("Failed to spawn shell: %v", err)
+	}
+
+	// 2. The match is found even though it arrives in two reads.
+	if err := pm.WaitForTag(pane.DefaultProbeTag, pane.DefaultProbeValue, 3*time.Second); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestPollingProbesTagPane(t *testing.T) {
+	pm := pane.NewPaneManager("test-polling-probe", "probe")
+	t.Cleanup(func() { _ = pm.TerminatePane(2 * time.Second) })
+
+	listener, err := net.Listen("tcp", "localhost:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	defer listener.Close()
+	port := listener.Addr().(*net.TCPAddr).Port
+	marker := filepath.Join(t.TempDir(), "ready")
+
+	probes := []pane.Probe{
+		{Command: []string{"true"}, Tag: "command", Value: "ok"},
+		{Port: port, Tag: "port", Value: "open"},
+		{File: marker, Tag: "file", Value: "present", Interval: 20 * time.Millisecond},
+	}
+	for _, probe := range probes {
+		if err := pm.AddProbe(probe); err != nil {
+			t.Fatalf("Failed to add probe: %v", err)
+		}
+	}
+
+	// 1. The command and port probes pass straight away.
+	if err := pm.WaitForTag("command", "ok", 2*time.Second); err != nil {
+		t.Fatal(err)
+	}
+	if err := pm.WaitForTag("port", "open", 2*time.Second); err != nil {
+		t.Fatal(err)
+	}
+
+	// 2. The file probe keeps polling until the file appears.
+	if err := pm.WaitForTag("file", "present", 100*time.Millisecond); err == nil {
+		t.Fatalf("Expected file probe to wait for %s", marker)
+	}
+	if err := os.WriteFile(marker, nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := pm.WaitForTag("file", "present", 2*time.Second); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestProbeValidation(t *testing.T) {
+	pm := pane.NewPaneManager("test-probe-validation", "probe")
+	t.Cleanup(func() { _ = pm.TerminatePane(2 * time.Second) })
+
+	if err := pm.AddProbe(pane.Probe{}); err == nil {
+		t.Error("Expected an error for a probe with no check")
+	}
+	if err := pm.AddProbe(pane.Probe{File: "/tmp/x", Port: 80}); err == nil {
+		t.Error("Expected an error for a probe with two checks")
+	}
+	if err := pm.AddProbe(pane.Probe{Port: 70000}); err == nil {
+		t.Error("Expected an error for an out-of-range port")
+	}
+}
@@ -0,0 +1,134 @@
+package screen
+
+import (
+	"strings"
+	"sync"
+)
+
+// ColorKind distinguishes the ways a cell color can be specified.
+type ColorKind uint8
+
+const (
+	ColorDefault ColorKind = iota // The terminal's default foreground or background.
+	ColorIndexed                  // One of the 256 palette colors, in Index.
+	ColorRGB                      // A 24-bit color, in R, G and B.
+)
+
+// Color is a foreground or background color of a cell.
+type Color struct {
+	Kind    ColorKind
+	Index   uint8
+	R, G, B uint8
+}
+
+// Attr is a bit set of text rendition attributes.
+type Attr uint16
+
+const (
+	AttrBold Attr = 1 << iota
+	AttrDim
+	AttrItalic
+	AttrUnderline
+	AttrBlink
+	AttrReverse
+	AttrHidden
+	AttrStrikethrough
+)
+
+// Cell is a single character position on the screen.
+type Cell struct {
+	Rune      rune   // The character, or 0 for an empty cell or the right half of a wide character.
+	Combining []rune // Zero-width combining marks drawn over Rune.
+	Width     int    // Columns occupied: 1, 2 for a wide character, or 0 for the right half of one.
+	FG, BG    Color
+	Attrs     Attr
+}
+
+// String returns the cell's text, including combining marks. Empty cells
+// render as a space and the right half of a wide character as nothing.
+func (c Cell) String() string {
+	switch {
+	case c.Width == 0:
+		return ""
+	case c.Rune == 0:
+		return " "
+	case len(c.Combining) == 0:
+		return string(c.Rune)
+	}
+	return string(c.Rune) + string(c.Combining)
+}
+
+// Cursor is the position and visibility of the text cursor.
+type Cursor struct {
+	Row, Col int
+	Visible  bool
+}
+
+// Snapshot is an immutable copy of what the terminal is displaying.
+type Snapshot struct {
+	Rows, Cols    int
+	Cells         [][]Cell // Cells[row][col], Rows by Cols.
+	Cursor        Cursor
+	AltScreen     bool   // Whether a full-screen program has switched to the alternate screen.
+	AppCursorKeys bool   // Whether the program asked for application cursor key sequences.
+	Title         string // The window title set via OSC 0 or 2.
+}
+
+// Lines returns the visible text of each row, with trailing blanks trimmed.
+func (s Snapshot) Lines() []string {
+	lines := make([]string, len(s.Cells))
+	for i, row := range s.Cells {
+		var b strings.Builder
+		for _, c := range row {
+			b.WriteString(c.String())
+		}
+		lines[i] = strings.TrimRight(b.String(), " ")
+	}
+	return lines
+}
+
+// Text returns the visible screen as plain text, one line per row, with
+// trailing blank lines removed, much like `tmux capture-pane -p`.
+func (s Snapshot) Text() string {
+	lines := s.Lines()
+	for len(lines) > 0 && lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return strings.Join(lines, "\n")
+}
+
+// Screen is a VT100/xterm terminal state machine. It consumes the raw byte
+// stream a program writes to its terminal and maintains the resulting cell
+// grid, cursor, colors, scroll region and alternate screen.
+type Screen struct {
+	mu     sync.Mutex
+	rows   int
+	cols   int
+	grid   [][]Cell // The active grid: either primary or alternate.
+	other  [][]Cell // The inactive grid.
+	alt    bool     // Whether grid is the alternate screen.
+	cursor Cursor
+	pen    Cell   // Colors and attributes applied to newly written cells.
+	saved  saved  // State stored by DECSC / CSI s.
+	altSav saved  // Cursor saved when entering the alternate screen with mode 1049.
+	top    int    // First row of the scroll region.
+	bottom int    // Last row of the scroll region.
+	tabs   []bool // Tab stops, by column.
+	title  string
+
+	wrapNext  bool // A character was written in the last column; the next one wraps first.
+	autoWrap  bool // DECAWM
+	origin    bool // DECOM: cursor addressing is relative to the scroll region.
+	insert    bool // IRM
+	appCursor bool // DECCKM
+
+	parser parser
+}
+
+// saved is the cursor state stored by DECSC.
+type saved struct {
+	row, col int
+	pen      Cell
+	origin   bool
+	wrapNext bool
+}
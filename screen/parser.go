@@ -0,0 +1,365 @@
+package screen
+
+import (
+	"strconv"
+	"unicode/utf8"
+)
+
+// parserState is a state of the escape sequence parser.
+type parserState uint8
+
+const (
+	stateGround    parserState = iota
+	stateEscape                // After ESC.
+	stateCharset               // After ESC ( ) * or +, expecting a charset designator.
+	stateHash                  // After ESC #.
+	stateCSI                   // Collecting CSI parameters.
+	stateOSC                   // Collecting an operating system command string.
+	stateString                // Skipping a DCS, APC, PM or SOS string.
+	stateStringEsc             // Saw ESC inside an OSC or skipped string; expecting '\'.
+)
+
+// parser holds the in-progress escape sequence and any partial UTF-8 rune,
+// so sequences split across writes are handled correctly.
+type parser struct {
+	state    parserState
+	private  byte   // CSI private marker such as '?' or '>'.
+	params   []byte // Raw CSI parameter bytes.
+	inter    []byte // CSI intermediate bytes.
+	osc      []byte // Collected OSC payload.
+	oscState parserState
+	utf8     []byte // Bytes of an incomplete UTF-8 sequence.
+}
+
+// maxSequence bounds how much of a malformed sequence is buffered.
+const maxSequence = 4096
+
+// feed runs the state machine over p.
+func (s *Screen) feed(p []byte) {
+	ps := &s.parser
+	for len(p) > 0 {
+		b := p[0]
+
+		if ps.state == stateGround && (b >= 0x80 || len(ps.utf8) > 0) {
+			p = s.feedUTF8(p)
+			continue
+		}
+		p = p[1:]
+
+		switch ps.state {
+		case stateGround:
+			if b < 0x20 || b == 0x7f {
+				s.control(b)
+			} else {
+				s.print(rune(b))
+			}
+
+		case stateEscape:
+			s.escape(b)
+
+		case stateCharset:
+			// The designated charset is ignored; text is always UTF-8.
+			ps.state = stateGround
+
+		case stateHash:
+			if b == '8' {
+				s.alignmentTest()
+			}
+			ps.state = stateGround
+
+		case stateCSI:
+			switch {
+			case b == 0x1b:
+				ps.state = stateEscape
+			case b < 0x20:
+				s.control(b) // C0 controls execute in the middle of a CSI sequence.
+			case b >= '<' && b <= '?' && len(ps.params) == 0 && ps.private == 0:
+				ps.private = b
+			case (b >= '0' && b <= '9') || b == ';' || b == ':':
+				if len(ps.params) < maxSequence {
+					ps.params = append(ps.params, b)
+				}
+			case b >= 0x20 && b <= 0x2f:
+				ps.inter = append(ps.inter, b)
+			case b >= 0x40 && b <= 0x7e:
+				s.csi(b)
+				ps.state = stateGround
+			default:
+				ps.state = stateGround // Malformed; abandon the sequence.
+			}
+
+		case stateOSC:
+			switch b {
+			case 0x07:
+				s.oscDispatch()
+				ps.state = stateGround
+			case 0x1b:
+				ps.oscState = stateOSC
+				ps.state = stateStringEsc
+			default:
+				if len(ps.osc) < maxSequence {
+					ps.osc = append(ps.osc, b)
+				}
+			}
+
+		case stateString:
+			switch b {
+			case 0x07:
+				ps.state = stateGround
+			case 0x1b:
+				ps.oscState = stateString
+				ps.state = stateStringEsc
+			}
+
+		case stateStringEsc:
+			if b == '\\' {
+				if ps.oscState == stateOSC {
+					s.oscDispatch()
+				}
+				ps.state = stateGround
+			} else {
+				// Not a string terminator: treat ESC as the start of a new sequence.
+				ps.state = stateEscape
+				s.escape(b)
+			}
+		}
+	}
+}
+
+// feedUTF8 decodes one rune from p, completing any partial sequence left by
+// the previous write and buffering an incomplete one at the end of p. It
+// returns the unconsumed input.
+func (s *Screen) feedUTF8(p []byte) []byte {
+	ps := &s.parser
+	if len(ps.utf8) == 0 {
+		if !utf8.FullRune(p) {
+			ps.utf8 = append(ps.utf8, p...) // Wait for the rest of the sequence.
+			return nil
+		}
+		r, size := utf8.DecodeRune(p)
+		s.print(r)
+		return p[size:]
+	}
+
+	ps.utf8 = append(ps.utf8, p[0])
+	if !utf8.FullRune(ps.utf8) {
+		return p[1:]
+	}
+	r, size := utf8.DecodeRune(ps.utf8)
+	valid := size == len(ps.utf8)
+	ps.utf8 = ps.utf8[:0]
+	s.print(r)
+	if !valid {
+		// The new byte did not continue the sequence; process it on its own.
+		return p
+	}
+	return p[1:]
+}
+
+// control executes a C0 control character.
+func (s *Screen) control(b byte) {
+	switch b {
+	case 0x08: // BS
+		s.wrapNext = false
+		if s.cursor.Col > 0 {
+			s.cursor.Col--
+		}
+	case 0x09: // HT
+		s.tab(1)
+	case 0x0a, 0x0b, 0x0c: // LF, VT, FF
+		s.lineFeed()
+	case 0x0d: // CR
+		s.wrapNext = false
+		s.cursor.Col = 0
+	case 0x1b: // ESC
+		s.parser.state = stateEscape
+	}
+}
+
+// escape handles the byte following ESC.
+func (s *Screen) escape(b byte) {
+	ps := &s.parser
+	ps.state = stateGround
+	switch b {
+	case '[':
+		ps.state = stateCSI
+		ps.private = 0
+		ps.params = ps.params[:0]
+		ps.inter = ps.inter[:0]
+	case ']':
+		ps.state = stateOSC
+		ps.osc = ps.osc[:0]
+	case 'P', '_', '^', 'X':
+		ps.state = stateString
+	case '(', ')', '*', '+':
+		ps.state = stateCharset
+	case '#':
+		ps.state = stateHash
+	case '7':
+		s.saveCursor()
+	case '8':
+		s.restoreCursor()
+	case 'D': // IND
+		s.lineFeed()
+	case 'E': // NEL
+		s.cursor.Col = 0
+		s.lineFeed()
+	case 'M': // RI
+		s.reverseIndex()
+	case 'H': // HTS
+		s.tabs[s.cursor.Col] = true
+	case 'c': // RIS
+		s.reset()
+	}
+}
+
+// csi dispatches a complete control sequence.
+func (s *Screen) csi(final byte) {
+	ps := &s.parser
+	params := parseParams(ps.params)
+	if len(ps.inter) > 0 {
+		return // No supported sequence uses intermediates.
+	}
+
+	if ps.private == '?' {
+		switch final {
+		case 'h':
+			s.setPrivateModes(params, true)
+		case 'l':
+			s.setPrivateModes(params, false)
+		}
+		return
+	}
+	if ps.private != 0 {
+		return
+	}
+
+	n := param(params, 0, 1)
+	switch final {
+	case '@':
+		s.insertBlanks(n)
+	case 'A':
+		s.moveCursor(-n, 0)
+	case 'B', 'e':
+		s.moveCursor(n, 0)
+	case 'C', 'a':
+		s.moveCursor(0, n)
+	case 'D':
+		s.moveCursor(0, -n)
+	case 'E':
+		s.moveCursor(n, 0)
+		s.cursor.Col = 0
+	case 'F':
+		s.moveCursor(-n, 0)
+		s.cursor.Col = 0
+	case 'G', '`':
+		s.setCursor(s.cursor.Row, n-1)
+	case 'H', 'f':
+		row := param(params, 0, 1) - 1
+		if s.origin {
+			row += s.top
+		}
+		s.setCursor(row, param(params, 1, 1)-1)
+	case 'I':
+		s.tab(n)
+	case 'J':
+		s.eraseDisplay(param(params, 0, 0))
+	case 'K':
+		s.eraseLine(param(params, 0, 0))
+	case 'L':
+		s.insertLines(n)
+	case 'M':
+		s.deleteLines(n)
+	case 'P':
+		s.deleteChars(n)
+	case 'S':
+		s.scrollUp(s.top, s.bottom, n)
+	case 'T':
+		s.scrollDown(s.top, s.bottom, n)
+	case 'X':
+		s.eraseChars(n)
+	case 'Z':
+		s.backTab(n)
+	case 'd':
+		row := n - 1
+		if s.origin {
+			row += s.top
+		}
+		s.setCursor(row, s.cursor.Col)
+	case 'g':
+		switch param(params, 0, 0) {
+		case 0:
+			s.tabs[s.cursor.Col] = false
+		case 3:
+			clear(s.tabs)
+		}
+	case 'h', 'l':
+		for _, p := range params {
+			if p == 4 {
+				s.insert = final == 'h'
+			}
+		}
+	case 'm':
+		s.sgr(params)
+	case 'r':
+		s.setScrollRegion(param(params, 0, 1)-1, param(params, 1, s.rows)-1)
+	case 's':
+		s.saveCursor()
+	case 'u':
+		s.restoreCursor()
+	}
+}
+
+// oscDispatch applies a complete operating system command.
+func (s *Screen) oscDispatch() {
+	cmd, text, ok := cutByte(s.parser.osc, ';')
+	if !ok {
+		return
+	}
+	switch string(cmd) {
+	case "0", "2":
+		s.title = string(text)
+	}
+}
+
+// parseParams splits raw CSI parameters. Missing values are returned as -1 so
+// callers can apply per-sequence defaults. Colon sub-parameters are flattened.
+func parseParams(raw []byte) []int {
+	if len(raw) == 0 {
+		return nil
+	}
+	var params []int
+	start := 0
+	for i := 0; i <= len(raw); i++ {
+		if i < len(raw) && raw[i] != ';' && raw[i] != ':' {
+			continue
+		}
+		v := -1
+		if i > start {
+			if n, err := strconv.Atoi(string(raw[start:i])); err == nil {
+				v = n
+			}
+		}
+		params = append(params, v)
+		start = i + 1
+	}
+	return params
+}
+
+// param returns the i-th parameter, or def if it is missing or zero.
+func param(params []int, i, def int) int {
+	if i >= len(params) || params[i] <= 0 {
+		return def
+	}
+	return params[i]
+}
+
+// cutByte splits b around the first sep.
+func cutByte(b []byte, sep byte) (before, after []byte, found bool) {
+	for i, c := range b {
+		if c == sep {
+			return b[:i], b[i+1:], true
+		}
+	}
+	return b, nil, false
+}
@@ -0,0 +1,609 @@
+package screen
+
+// New creates a screen of the given size with the cursor at the top left.
+func New(rows, cols int) *Screen {
+	s := &Screen{}
+	s.rows, s.cols = max(rows, 1), max(cols, 1)
+	s.reset()
+	return s
+}
+
+// Write feeds terminal output into the state machine. It never fails.
+// Escape sequences and UTF-8 characters may be split across writes.
+func (s *Screen) Write(p []byte) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.feed(p)
+	return len(p), nil
+}
+
+// Size returns the screen dimensions.
+func (s *Screen) Size() (rows, cols int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.rows, s.cols
+}
+
+// AppCursorKeys reports whether the program has enabled application cursor
+// key mode (DECCKM), in which arrow keys are sent as ESC O sequences.
+func (s *Screen) AppCursorKeys() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.appCursor
+}
+
+// Resize changes the screen dimensions. Content is kept anchored at the top
+// left, the scroll region is reset and the cursor is clamped to the new size.
+func (s *Screen) Resize(rows, cols int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	rows, cols = max(rows, 1), max(cols, 1)
+	if rows == s.rows && cols == s.cols {
+		return
+	}
+
+	// If the cursor would fall off the bottom, scroll the content up so the
+	// cursor's line stays visible, like xterm does.
+	if shift := s.cursor.Row - (rows - 1); shift > 0 && !s.alt {
+		s.grid = s.grid[shift:]
+		s.cursor.Row -= shift
+	}
+	s.grid = resizeGrid(s.grid, rows, cols)
+	s.other = resizeGrid(s.other, rows, cols)
+
+	tabs := make([]bool, cols)
+	copy(tabs, s.tabs)
+	for c := len(s.tabs); c < cols; c++ {
+		tabs[c] = c%8 == 0
+	}
+	s.tabs = tabs
+
+	s.rows, s.cols = rows, cols
+	s.top, s.bottom = 0, rows-1
+	s.cursor.Row = min(s.cursor.Row, rows-1)
+	s.cursor.Col = min(s.cursor.Col, cols-1)
+	s.wrapNext = false
+}
+
+// Snapshot returns a copy of the current screen contents and state.
+func (s *Screen) Snapshot() Snapshot {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	cells := make([][]Cell, s.rows)
+	for r, row := range s.grid {
+		cells[r] = make([]Cell, len(row))
+		for c, cell := range row {
+			cell.Combining = append([]rune(nil), cell.Combining...)
+			cells[r][c] = cell
+		}
+	}
+	return Snapshot{
+		Rows:          s.rows,
+		Cols:          s.cols,
+		Cells:         cells,
+		Cursor:        s.cursor,
+		AltScreen:     s.alt,
+		AppCursorKeys: s.appCursor,
+		Title:         s.title,
+	}
+}
+
+// reset returns the terminal to its power-on state (RIS).
+func (s *Screen) reset() {
+	s.grid = newGrid(s.rows, s.cols)
+	s.other = newGrid(s.rows, s.cols)
+	s.alt = false
+	s.cursor = Cursor{Visible: true}
+	s.pen = blankCell()
+	s.saved = saved{pen: blankCell()}
+	s.altSav = s.saved
+	s.top, s.bottom = 0, s.rows-1
+	s.tabs = make([]bool, s.cols)
+	for c := range s.tabs {
+		s.tabs[c] = c%8 == 0
+	}
+	s.title = ""
+	s.wrapNext = false
+	s.autoWrap = true
+	s.origin = false
+	s.insert = false
+	s.appCursor = false
+	s.parser = parser{}
+}
+
+// print writes a character at the cursor and advances it.
+func (s *Screen) print(r rune) {
+	width := runeWidth(r)
+	if width == 0 {
+		s.combine(r)
+		return
+	}
+
+	if s.wrapNext && s.autoWrap {
+		s.cursor.Col = 0
+		s.lineFeed()
+	}
+	s.wrapNext = false
+
+	// A wide character that doesn't fit on this line wraps as a whole.
+	if width == 2 && s.cursor.Col == s.cols-1 {
+		if !s.autoWrap {
+			return
+		}
+		s.clearCells(s.cursor.Row, s.cursor.Col, s.cursor.Col+1)
+		s.cursor.Col = 0
+		s.lineFeed()
+	}
+	if width > s.cols {
+		return
+	}
+
+	row, col := s.cursor.Row, s.cursor.Col
+	if s.insert {
+		s.shiftRight(row, col, width)
+	}
+	s.clearCells(row, col, col+width)
+	cell := s.pen
+	cell.Rune, cell.Width, cell.Combining = r, width, nil
+	s.grid[row][col] = cell
+	if width == 2 {
+		cont := s.pen
+		cont.Rune, cont.Width, cont.Combining = 0, 0, nil
+		s.grid[row][col+1] = cont
+	}
+
+	if col+width >= s.cols {
+		s.cursor.Col = s.cols - 1
+		s.wrapNext = true
+	} else {
+		s.cursor.Col = col + width
+	}
+}
+
+// combine attaches a zero-width mark to the character before the cursor.
+func (s *Screen) combine(r rune) {
+	row, col := s.cursor.Row, s.cursor.Col
+	if !s.wrapNext {
+		col--
+	}
+	if col >= 0 && s.grid[row][col].Width == 0 && col > 0 {
+		col-- // Attach to the left half of a wide character.
+	}
+	if col < 0 || s.grid[row][col].Rune == 0 {
+		return
+	}
+	s.grid[row][col].Combining = append(s.grid[row][col].Combining, r)
+}
+
+// clearCells blanks cells [from, to) on a row, also blanking the other half of
+// any wide character that is cut in two.
+func (s *Screen) clearCells(row, from, to int) {
+	line := s.grid[row]
+	from, to = max(from, 0), min(to, s.cols)
+	if from >= to {
+		return
+	}
+	if line[from].Width == 0 && from > 0 {
+		line[from-1] = s.erased()
+	}
+	if to < s.cols && line[to].Width == 0 {
+		line[to] = s.erased()
+	}
+	for c := from; c < to; c++ {
+		line[c] = s.erased()
+	}
+}
+
+// erased returns a blank cell carrying the current background color, as
+// erase operations do on xterm.
+func (s *Screen) erased() Cell {
+	c := blankCell()
+	c.BG = s.pen.BG
+	return c
+}
+
+// shiftRight moves cells at and after col right by n, dropping those pushed off the line.
+func (s *Screen) shiftRight(row, col, n int) {
+	line := s.grid[row]
+	if line[col].Width == 0 && col > 0 {
+		// Inserting inside a wide character splits it; blank both halves.
+		line[col-1], line[col] = s.erased(), s.erased()
+	}
+	n = min(n, s.cols-col)
+	copy(line[col+n:], line[col:s.cols-n])
+	for c := col; c < col+n; c++ {
+		line[c] = s.erased()
+	}
+	s.fixWideAt(row, s.cols-1)
+}
+
+// fixWideAt blanks a wide character's left half left dangling in the last column.
+func (s *Screen) fixWideAt(row, col int) {
+	if col == s.cols-1 && s.grid[row][col].Width == 2 {
+		s.grid[row][col] = s.erased()
+	}
+}
+
+// lineFeed moves the cursor down, scrolling the region if it is at the bottom margin.
+func (s *Screen) lineFeed() {
+	s.wrapNext = false
+	switch {
+	case s.cursor.Row == s.bottom:
+		s.scrollUp(s.top, s.bottom, 1)
+	case s.cursor.Row < s.rows-1:
+		s.cursor.Row++
+	}
+}
+
+// reverseIndex moves the cursor up, scrolling the region down at the top margin.
+func (s *Screen) reverseIndex() {
+	s.wrapNext = false
+	switch {
+	case s.cursor.Row == s.top:
+		s.scrollDown(s.top, s.bottom, 1)
+	case s.cursor.Row > 0:
+		s.cursor.Row--
+	}
+}
+
+// scrollUp moves rows [top, bottom] up by n, filling the bottom with blank lines.
+func (s *Screen) scrollUp(top, bottom, n int) {
+	n = min(n, bottom-top+1)
+	region := s.grid[top : bottom+1]
+	copy(region, region[n:])
+	for r := len(region) - n; r < len(region); r++ {
+		region[r] = s.blankLine()
+	}
+}
+
+// scrollDown moves rows [top, bottom] down by n, filling the top with blank lines.
+func (s *Screen) scrollDown(top, bottom, n int) {
+	n = min(n, bottom-top+1)
+	region := s.grid[top : bottom+1]
+	copy(region[n:], region)
+	for r := 0; r < n; r++ {
+		region[r] = s.blankLine()
+	}
+}
+
+// blankLine returns a new row of erased cells.
+func (s *Screen) blankLine() []Cell {
+	line := make([]Cell, s.cols)
+	for c := range line {
+		line[c] = s.erased()
+	}
+	return line
+}
+
+// moveCursor moves the cursor relative to its position, stopping at the
+// scroll margins when starting inside them.
+func (s *Screen) moveCursor(dRow, dCol int) {
+	s.wrapNext = false
+	top, bottom := 0, s.rows-1
+	if s.cursor.Row >= s.top && s.cursor.Row <= s.bottom {
+		top, bottom = s.top, s.bottom
+	}
+	s.cursor.Row = min(max(s.cursor.Row+dRow, top), bottom)
+	s.cursor.Col = min(max(s.cursor.Col+dCol, 0), s.cols-1)
+}
+
+// setCursor moves the cursor to an absolute position, clamped to the screen
+// (or to the scroll region in origin mode).
+func (s *Screen) setCursor(row, col int) {
+	s.wrapNext = false
+	top, bottom := 0, s.rows-1
+	if s.origin {
+		top, bottom = s.top, s.bottom
+	}
+	s.cursor.Row = min(max(row, top), bottom)
+	s.cursor.Col = min(max(col, 0), s.cols-1)
+}
+
+// tab advances the cursor to the n-th next tab stop.
+func (s *Screen) tab(n int) {
+	for ; n > 0 && s.cursor.Col < s.cols-1; n-- {
+		s.cursor.Col++
+		for s.cursor.Col < s.cols-1 && !s.tabs[s.cursor.Col] {
+			s.cursor.Col++
+		}
+	}
+}
+
+// backTab moves the cursor back to the n-th previous tab stop.
+func (s *Screen) backTab(n int) {
+	s.wrapNext = false
+	for ; n > 0 && s.cursor.Col > 0; n-- {
+		s.cursor.Col--
+		for s.cursor.Col > 0 && !s.tabs[s.cursor.Col] {
+			s.cursor.Col--
+		}
+	}
+}
+
+// eraseDisplay implements ED: 0 erases below, 1 above, 2 and 3 everything.
+func (s *Screen) eraseDisplay(mode int) {
+	switch mode {
+	case 0:
+		s.eraseLine(0)
+		for r := s.cursor.Row + 1; r < s.rows; r++ {
+			s.grid[r] = s.blankLine()
+		}
+	case 1:
+		s.eraseLine(1)
+		for r := 0; r < s.cursor.Row; r++ {
+			s.grid[r] = s.blankLine()
+		}
+	case 2, 3:
+		for r := range s.grid {
+			s.grid[r] = s.blankLine()
+		}
+	}
+}
+
+// eraseLine implements EL: 0 erases to the right, 1 to the left, 2 the whole line.
+func (s *Screen) eraseLine(mode int) {
+	s.wrapNext = false
+	row, col := s.cursor.Row, s.cursor.Col
+	switch mode {
+	case 0:
+		s.clearCells(row, col, s.cols)
+	case 1:
+		s.clearCells(row, 0, col+1)
+	case 2:
+		s.grid[row] = s.blankLine()
+	}
+}
+
+// eraseChars implements ECH, blanking n cells from the cursor.
+func (s *Screen) eraseChars(n int) {
+	s.wrapNext = false
+	s.clearCells(s.cursor.Row, s.cursor.Col, s.cursor.Col+n)
+}
+
+// insertBlanks implements ICH, shifting the rest of the line right.
+func (s *Screen) insertBlanks(n int) {
+	s.wrapNext = false
+	s.shiftRight(s.cursor.Row, s.cursor.Col, n)
+}
+
+// deleteChars implements DCH, shifting the rest of the line left.
+func (s *Screen) deleteChars(n int) {
+	s.wrapNext = false
+	row, col := s.cursor.Row, s.cursor.Col
+	line := s.grid[row]
+	n = min(n, s.cols-col)
+	if line[col].Width == 0 && col > 0 {
+		line[col-1] = s.erased()
+	}
+	if end := col + n; end < s.cols && line[end].Width == 0 {
+		line[end] = s.erased()
+	}
+	copy(line[col:], line[col+n:])
+	for c := s.cols - n; c < s.cols; c++ {
+		line[c] = s.erased()
+	}
+}
+
+// insertLines implements IL within the scroll region.
+func (s *Screen) insertLines(n int) {
+	if s.cursor.Row < s.top || s.cursor.Row > s.bottom {
+		return
+	}
+	s.scrollDown(s.cursor.Row, s.bottom, n)
+	s.cursor.Col = 0
+	s.wrapNext = false
+}
+
+// deleteLines implements DL within the scroll region.
+func (s *Screen) deleteLines(n int) {
+	if s.cursor.Row < s.top || s.cursor.Row > s.bottom {
+		return
+	}
+	s.scrollUp(s.cursor.Row, s.bottom, n)
+	s.cursor.Col = 0
+	s.wrapNext = false
+}
+
+// setScrollRegion implements DECSTBM and homes the cursor.
+func (s *Screen) setScrollRegion(top, bottom int) {
+	bottom = min(bottom, s.rows-1)
+	if top < 0 || top >= bottom {
+		return
+	}
+	s.top, s.bottom = top, bottom
+	if s.origin {
+		s.setCursor(top, 0)
+	} else {
+		s.setCursor(0, 0)
+	}
+}
+
+// saveCursor implements DECSC.
+func (s *Screen) saveCursor() {
+	s.saved = saved{row: s.cursor.Row, col: s.cursor.Col, pen: s.pen, origin: s.origin, wrapNext: s.wrapNext}
+}
+
+// restoreCursor implements DECRC.
+func (s *Screen) restoreCursor() {
+	s.cursor.Row = min(s.saved.row, s.rows-1)
+	s.cursor.Col = min(s.saved.col, s.cols-1)
+	s.pen = s.saved.pen
+	s.origin = s.saved.origin
+	s.wrapNext = s.saved.wrapNext
+}
+
+// alignmentTest implements DECALN, filling the screen with 'E'.
+func (s *Screen) alignmentTest() {
+	for r := range s.grid {
+		for c := range s.grid[r] {
+			s.grid[r][c] = Cell{Rune: 'E', Width: 1}
+		}
+	}
+	s.top, s.bottom = 0, s.rows-1
+	s.setCursor(0, 0)
+}
+
+// setPrivateModes applies DEC private mode changes (CSI ? Pm h / l).
+func (s *Screen) setPrivateModes(params []int, on bool) {
+	for _, mode := range params {
+		switch mode {
+		case 1:
+			s.appCursor = on
+		case 6:
+			s.origin = on
+			s.setCursor(s.top, 0)
+		case 7:
+			s.autoWrap = on
+		case 25:
+			s.cursor.Visible = on
+		case 47, 1047:
+			s.switchScreen(on, false)
+		case 1048:
+			if on {
+				s.saveCursor()
+			} else {
+				s.restoreCursor()
+			}
+		case 1049:
+			s.switchScreen(on, true)
+		}
+	}
+}
+
+// switchScreen swaps between the primary and alternate screens. With
+// saveCursor (mode 1049) the cursor is saved on entry and restored on exit,
+// and the alternate screen is cleared on entry.
+func (s *Screen) switchScreen(toAlt, saveCursor bool) {
+	if toAlt == s.alt {
+		return
+	}
+	if toAlt && saveCursor {
+		s.altSav = saved{row: s.cursor.Row, col: s.cursor.Col, pen: s.pen, origin: s.origin, wrapNext: s.wrapNext}
+	}
+	s.grid, s.other = s.other, s.grid
+	s.alt = toAlt
+	if toAlt && saveCursor {
+		for r := range s.grid {
+			s.grid[r] = s.blankLine()
+		}
+	}
+	if !toAlt && saveCursor {
+		s.cursor.Row = min(s.altSav.row, s.rows-1)
+		s.cursor.Col = min(s.altSav.col, s.cols-1)
+		s.pen = s.altSav.pen
+		s.origin = s.altSav.origin
+		s.wrapNext = s.altSav.wrapNext
+	}
+}
+
+// sgr applies Select Graphic Rendition parameters to the pen.
+func (s *Screen) sgr(params []int) {
+	if len(params) == 0 {
+		params = []int{0}
+	}
+	for i := 0; i < len(params); i++ {
+		switch p := params[i]; {
+		case p <= 0:
+			s.pen = blankCell()
+		case p == 1:
+			s.pen.Attrs |= AttrBold
+		case p == 2:
+			s.pen.Attrs |= AttrDim
+		case p == 3:
+			s.pen.Attrs |= AttrItalic
+		case p == 4:
+			s.pen.Attrs |= AttrUnderline
+		case p == 5 || p == 6:
+			s.pen.Attrs |= AttrBlink
+		case p == 7:
+			s.pen.Attrs |= AttrReverse
+		case p == 8:
+			s.pen.Attrs |= AttrHidden
+		case p == 9:
+			s.pen.Attrs |= AttrStrikethrough
+		case p == 21 || p == 22:
+			s.pen.Attrs &^= AttrBold | AttrDim
+		case p == 23:
+			s.pen.Attrs &^= AttrItalic
+		case p == 24:
+			s.pen.Attrs &^= AttrUnderline
+		case p == 25:
+			s.pen.Attrs &^= AttrBlink
+		case p == 27:
+			s.pen.Attrs &^= AttrReverse
+		case p == 28:
+			s.pen.Attrs &^= AttrHidden
+		case p == 29:
+			s.pen.Attrs &^= AttrStrikethrough
+		case p >= 30 && p <= 37:
+			s.pen.FG = Color{Kind: ColorIndexed, Index: uint8(p - 30)}
+		case p == 38:
+			s.pen.FG, i = extendedColor(params, i)
+		case p == 39:
+			s.pen.FG = Color{}
+		case p >= 40 && p <= 47:
+			s.pen.BG = Color{Kind: ColorIndexed, Index: uint8(p - 40)}
+		case p == 48:
+			s.pen.BG, i = extendedColor(params, i)
+		case p == 49:
+			s.pen.BG = Color{}
+		case p >= 90 && p <= 97:
+			s.pen.FG = Color{Kind: ColorIndexed, Index: uint8(p - 90 + 8)}
+		case p >= 100 && p <= 107:
+			s.pen.BG = Color{Kind: ColorIndexed, Index: uint8(p - 100 + 8)}
+		}
+	}
+}
+
+// extendedColor parses a 38/48 color starting at params[i], returning the
+// color and the index of the last parameter consumed.
+func extendedColor(params []int, i int) (Color, int) {
+	if i+1 >= len(params) {
+		return Color{}, i
+	}
+	switch params[i+1] {
+	case 5:
+		if i+2 < len(params) {
+			return Color{Kind: ColorIndexed, Index: uint8(max(params[i+2], 0))}, i + 2
+		}
+	case 2:
+		if i+4 < len(params) {
+			return Color{
+				Kind: ColorRGB,
+				R:    uint8(max(params[i+2], 0)),
+				G:    uint8(max(params[i+3], 0)),
+				B:    uint8(max(params[i+4], 0)),
+			}, i + 4
+		}
+	}
+	return Color{}, len(params) - 1
+}
+
+// blankCell returns an empty, single-width cell with default rendition.
+func blankCell() Cell {
+	return Cell{Width: 1}
+}
+
+// newGrid allocates a rows-by-cols grid of blank cells.
+func newGrid(rows, cols int) [][]Cell {
+	grid := make([][]Cell, rows)
+	for r := range grid {
+		grid[r] = make([]Cell, cols)
+		for c := range grid[r] {
+			grid[r][c] = blankCell()
+		}
+	}
+	return grid
+}
+
+// resizeGrid returns grid cropped or padded to rows by cols.
+func resizeGrid(grid [][]Cell, rows, cols int) [][]Cell {
+	out := newGrid(rows, cols)
+	for r := 0; r < rows && r < len(grid); r++ {
+		copy(out[r], grid[r])
+		if cols < len(grid[r]) && out[r][cols-1].Width == 2 {
+			out[r][cols-1] = blankCell() // The right half was cropped away.
+		}
+	}
+	return out
+}
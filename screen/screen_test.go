@@ -0,0 +1,107 @@
+package screen_test
+
+import (
+	"testing"
+
+	"github.com/owen-6936/termplex/assert"
+	"github.com/owen-6936/termplex/screen"
+)
+
+func write(s *screen.Screen, text string) {
+	_, _ = s.Write([]byte(text))
+}
+
+func TestScreenTextCursorAndErase(t *testing.T) {
+	s := screen.New(5, 20)
+
+	// 1. Plain output, a carriage return overwrite and an erase to end of line.
+	write(s, "$ make build\r\nbuilding... 10%")
+	write(s, "\r\x1b[Kbuilding... done\r\n$ ")
+
+	snap := s.Snapshot()
+	assert.True(t, snap.Text() == "$ make build\nbuilding... done\n$", "Unexpected screen text %q", snap.Text())
+	assert.True(t, snap.Cursor.Row == 2 && snap.Cursor.Col == 2, "Unexpected cursor %+v", snap.Cursor)
+
+	// 2. Absolute positioning and clearing the screen.
+	write(s, "\x1b[2J\x1b[3;5Hhere")
+	lines := s.Snapshot().Lines()
+	assert.True(t, lines[0] == "" && lines[2] == "    here", "Unexpected lines after CUP: %q", lines)
+}
+
+func TestScreenAutoWrapAndScroll(t *testing.T) {
+	s := screen.New(3, 5)
+	write(s, "abcdefgh\r\nline2\r\nline3")
+
+	// "abcde" wrapped to "fgh", and the first row scrolled off the top.
+	assert.True(t, s.Snapshot().Text() == "fgh\nline2\nline3", "Unexpected text %q", s.Snapshot().Text())
+}
+
+func TestScreenColorsAndAttributes(t *testing.T) {
+	s := screen.New(2, 20)
+	write(s, "\x1b[1;31mE\x1b[0m \x1b[38;2;10;20;30;48;5;236mX\x1b[m")
+
+	row := s.Snapshot().Cells[0]
+	assert.True(t, row[0].Attrs&screen.AttrBold != 0, "Expected bold on the first cell")
+	assert.True(t, row[0].FG == screen.Color{Kind: screen.ColorIndexed, Index: 1}, "Expected red foreground, got %+v", row[0].FG)
+	assert.True(t, row[1].Attrs == 0 && row[1].FG.Kind == screen.ColorDefault, "Expected SGR 0 to reset the pen")
+	assert.True(t, row[2].FG == screen.Color{Kind: screen.ColorRGB, R: 10, G: 20, B: 30}, "Unexpected truecolor %+v", row[2].FG)
+	assert.True(t, row[2].BG == screen.Color{Kind: screen.ColorIndexed, Index: 236}, "Unexpected 256-color background %+v", row[2].BG)
+}
+
+func TestScreenAlternateScreenRestoresPrimary(t *testing.T) {
+	s := screen.New(4, 20)
+	write(s, "shell prompt $ ")
+
+	// A full-screen program like vim or less enters the alternate screen.
+	write(s, "\x1b[?1049h\x1b[H\x1b[2Jfull screen ui")
+	snap := s.Snapshot()
+	assert.True(t, snap.AltScreen, "Expected the alternate screen to be active")
+	assert.True(t, snap.Text() == "full screen ui", "Unexpected alternate screen %q", snap.Text())
+
+	// On exit, the shell's screen and cursor come back untouched.
+	write(s, "\x1b[?1049l")
+	snap = s.Snapshot()
+	assert.True(t, !snap.AltScreen, "Expected the primary screen to be active")
+	assert.True(t, snap.Text() == "shell prompt $", "Unexpected primary screen %q", snap.Text())
+	assert.True(t, snap.Cursor.Col == 15, "Expected the cursor to be restored, got %+v", snap.Cursor)
+}
+
+func TestScreenScrollRegion(t *testing.T) {
+	s := screen.New(5, 10)
+	write(s, "header\r\n1\r\n2\r\n3\r\nfooter")
+
+	// Restrict scrolling to rows 2-4 and feed a line at the bottom margin.
+	write(s, "\x1b[2;4r\x1b[4;1H\nnew")
+	lines := s.Snapshot().Lines()
+	assert.True(t, lines[0] == "header" && lines[4] == "footer", "Margins outside the region moved: %q", lines)
+	assert.True(t, lines[1] == "2" && lines[2] == "3" && lines[3] == "new", "Unexpected region contents: %q", lines)
+}
+
+func TestScreenWideCharactersAndSplitUTF8(t *testing.T) {
+	s := screen.New(2, 6)
+
+	// "日本" is two wide characters; feed the UTF-8 bytes one at a time.
+	for _, b := range []byte("日本!") {
+		_, _ = s.Write([]byte{b})
+	}
+	snap := s.Snapshot()
+	row := snap.Cells[0]
+	assert.True(t, row[0].Rune == '日' && row[0].Width == 2 && row[1].Width == 0, "Unexpected wide cell layout %+v", row[:2])
+	assert.True(t, snap.Lines()[0] == "日本!", "Unexpected text %q", snap.Lines()[0])
+	assert.True(t, snap.Cursor.Col == 5, "Expected the cursor after 5 columns, got %d", snap.Cursor.Col)
+
+	// A wide character that doesn't fit in the last column wraps as a whole.
+	write(s, "語")
+	assert.True(t, s.Snapshot().Text() == "日本!\n語", "Unexpected wrapped text %q", s.Snapshot().Text())
+}
+
+func TestScreenResizeAndTitle(t *testing.T) {
+	s := screen.New(3, 10)
+	write(s, "\x1b]0;my title\x07one\r\ntwo\r\nthree")
+
+	s.Resize(2, 4)
+	snap := s.Snapshot()
+	assert.True(t, snap.Rows == 2 && snap.Cols == 4, "Unexpected size %dx%d", snap.Rows, snap.Cols)
+	assert.True(t, snap.Text() == "two\nthre", "Expected the cursor line to stay visible, got %q", snap.Text())
+	assert.True(t, snap.Title == "my title", "Unexpected title %q", snap.Title)
+}
@@ -3,10 +3,16 @@ package session
 import (
 	"errors"
 	"fmt"
+	"log/slog"
+	"regexp"
 	"time"
 
 	"github.com/google/uuid"
+	"github.com/owen-6936/termplex/cgroup"
+	"github.com/owen-6936/termplex/fanout"
 	"github.com/owen-6936/termplex/manifest"
+	"github.com/owen-6936/termplex/pane"
+	"github.com/owen-6936/termplex/shell"
 	"github.com/owen-6936/termplex/window"
 )
 
@@ -15,6 +21,10 @@ type SessionManager struct {
 	Sessions             map[string]*Session
 	Windows              map[string]*window.WindowManager
 	MaxWindowsPerSession int
+	logger               *slog.Logger     // Structured logger; discards by default.
+	pool                 *shell.ShellPool // Pre-started shells for every pane; nil always spawns.
+	logs                 *pane.LogOptions // Output logs for every pane; nil logs nothing.
+	output               fanout.Hub[pane.PaneOutput]
 }
 
 // NewSessionManager initializes a new SessionManager with a window limit.
@@ -23,6 +33,7 @@ func NewSessionManager(maxWindows int) *SessionManager {
 		Sessions:             make(map[string]*Session),
 		Windows:              make(map[string]*window.WindowManager),
 		MaxWindowsPerSession: maxWindows,
+		logger:               slog.New(slog.DiscardHandler),
 	}
 }
 
@@ -41,10 +52,25 @@ func (sm *SessionManager) CreateSession(name string, tags map[string]string) (st
 		WindowRefs: make(map[string]bool), // A map is not a slice, so it remains.
 	}
 
-	fmt.Printf("🧠 Session created: %s (%s)\n", id, name)
+	sm.logger.Info("session created", "session_id", id, "name", name)
 	return id, nil
 }
 
+// forwardWindowOutput relays a window's output to the manager's subscribers
+// until the window terminates.
+func (sm *SessionManager) forwardWindowOutput(windowOutput <-chan pane.PaneOutput) {
+	for output := range windowOutput {
+		sm.output.Publish(output)
+	}
+}
+
+// Subscribe returns a new, independent stream of output from every pane in
+// every session, including windows added later. The channel stays open until
+// the subscription is cancelled.
+func (sm *SessionManager) Subscribe(opts fanout.Options) (<-chan pane.PaneOutput, *fanout.Subscription) {
+	return sm.output.Subscribe(opts)
+}
+
 // AddWindow registers a new window in a session.
 func (sm *SessionManager) AddWindow(sessionID string, name string, tags map[string]string) (string, error) {
 	session, exists := sm.Sessions[sessionID]
@@ -61,11 +87,71 @@ func (sm *SessionManager) AddWindow(sessionID string, name string, tags map[stri
 		return "", errors.New("window ID collision")
 	}
 
-	sm.Windows[windowID] = window.NewWindowManager(name, tags)
+	wm := window.NewWindowManager(name, tags)
+	wm.ID = windowID // Keep the window's own ID in step with the session's reference to it.
+	if session.Scrollback != nil {
+		wm.SetScrollbackLimits(*session.Scrollback)
+	}
+	if session.Limits != nil {
+		wm.SetResourceLimits(*session.Limits)
+	}
+	if session.Cgroup != nil {
+		wm.SetCgroup(session.Cgroup)
+	}
+	if sm.pool != nil {
+		wm.SetShellPool(sm.pool)
+	}
+	if sm.logs != nil {
+		_ = wm.SetLogOptions(*sm.logs) // The window has no panes yet.
+	}
+	wm.SetLogger(sm.logger.With("session_id", sessionID))
+	windowOutput, _ := wm.Subscribe(fanout.Options{Policy: fanout.Block})
+	go sm.forwardWindowOutput(windowOutput)
+	sm.Windows[windowID] = wm
 	session.WindowRefs[windowID] = true
 	return windowID, nil
 }
 
+// SetLogger sets the structured logger for every session, window, pane and
+// shell the manager owns, including those created later. Records carry
+// session_id, window_id, pane_id and shell_id attributes as they apply. A nil
+// logger discards them, which is the default.
+func (sm *SessionManager) SetLogger(logger *slog.Logger) {
+	if logger == nil {
+		logger = slog.New(slog.DiscardHandler)
+	}
+	sm.logger = logger
+	for sessionID, session := range sm.Sessions {
+		for windowID := range session.WindowRefs {
+			sm.Windows[windowID].SetLogger(logger.With("session_id", sessionID))
+		}
+	}
+}
+
+// SetShellPool makes every pane in every session, including those created
+// later and from manifests, take its shells from pool where it can. Pane
+// startup then skips the shells' own startup, such as their rc files.
+func (sm *SessionManager) SetShellPool(pool *shell.ShellPool) {
+	sm.pool = pool
+	for _, wm := range sm.Windows {
+		wm.SetShellPool(pool)
+	}
+}
+
+// SetLogOptions persists the output of every pane in every session to disk,
+// including panes created later. A manifest pane's "logs" block overrides
+// these options for that pane.
+func (sm *SessionManager) SetLogOptions(opts pane.LogOptions) error {
+	sm.logs = &opts
+	var errs []error
+	for _, wm := range sm.Windows {
+		if err := wm.SetLogOptions(opts); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
 // HasSession checks if a session exists.
 func (sm *SessionManager) HasSession(id string) bool {
 	_, exists := sm.Sessions[id]
@@ -78,21 +164,67 @@ func (sm *SessionManager) GetSession(id string) (*Session, bool) {
 	return session, exists
 }
 
-// TerminateSession removes a session and its windows.
+// SetScrollbackLimits bounds the output retained by every shell in a session,
+// including shells in windows and panes created later.
+func (sm *SessionManager) SetScrollbackLimits(sessionID string, limits shell.ScrollbackLimits) error {
+	session, exists := sm.Sessions[sessionID]
+	if !exists {
+		return errors.New("session not found")
+	}
+	session.Scrollback = &limits
+	for windowID := range session.WindowRefs {
+		sm.Windows[windowID].SetScrollbackLimits(limits)
+	}
+	return nil
+}
+
+// SetResourceLimits sets the setrlimit limits of every shell spawned in a
+// session from now on, including in windows and panes created later.
+func (sm *SessionManager) SetResourceLimits(sessionID string, limits shell.ResourceLimits) error {
+	session, exists := sm.Sessions[sessionID]
+	if !exists {
+		return errors.New("session not found")
+	}
+	session.Limits = &limits
+	for windowID := range session.WindowRefs {
+		sm.Windows[windowID].SetResourceLimits(limits)
+	}
+	return nil
+}
+
+// SetCgroup runs every shell spawned in a session from now on in group, so
+// the whole session shares its memory and CPU limits.
+func (sm *SessionManager) SetCgroup(sessionID string, group *cgroup.Group) error {
+	session, exists := sm.Sessions[sessionID]
+	if !exists {
+		return errors.New("session not found")
+	}
+	session.Cgroup = group
+	for windowID := range session.WindowRefs {
+		sm.Windows[windowID].SetCgroup(group)
+	}
+	return nil
+}
+
+// TerminateSession removes a session and its windows. Every shell's process
+// tree is stopped; processes that survive are reported in the returned error.
 func (sm *SessionManager) TerminateSession(id string) error {
 	session, exists := sm.Sessions[id]
 	if !exists {
 		return errors.New("session not found")
 	}
 
+	var errs []error
 	for windowID := range session.WindowRefs {
-		sm.Windows[windowID].TerminateWindow()
+		if err := sm.Windows[windowID].TerminateWindow(); err != nil {
+			errs = append(errs, err)
+		}
 		delete(sm.Windows, windowID)
 	}
 
 	delete(sm.Sessions, id)
-	fmt.Printf("🧹 Session terminated: %s\n", id)
-	return nil
+	sm.logger.Info("session terminated", "session_id", id)
+	return errors.Join(errs...)
 }
 
 // CreateSessionFromManifest reads a manifest file, parses it, and builds the entire
@@ -108,6 +240,9 @@ func (sm *SessionManager) CreateSessionFromManifest(filePath string) (string, er
 	if err != nil {
 		return "", err
 	}
+	if m.Scrollback != nil {
+		_ = sm.SetScrollbackLimits(sessionID, scrollbackLimits(m.Scrollback))
+	}
 
 	// 2. Iterate over windows defined in the manifest.
 	for _, winManifest := range m.Windows {
@@ -125,18 +260,165 @@ func (sm *SessionManager) CreateSessionFromManifest(filePath string) (string, er
 			}
 			pane, _ := wm.GetPane(paneID)
 
+			if paneManifest.Scrollback != nil {
+				pane.SetScrollbackLimits(scrollbackLimits(paneManifest.Scrollback))
+			}
+			if paneManifest.Logs != nil {
+				if err := sm.applyPaneLogs(pane, paneManifest.Logs); err != nil {
+					return "", fmt.Errorf("pane %q: %w", paneManifest.PaneName, err)
+				}
+			}
+
+			// Size the pane before spawning so the startup shell's PTY
+			// starts with the declared dimensions.
+			if paneManifest.Size != nil {
+				if err := pane.Resize(paneManifest.Size.Rows, paneManifest.Size.Cols); err != nil {
+					return "", err
+				}
+			}
+
+			// Probes go in before the shell starts, so output probes see
+			// everything it prints.
+			for _, probeManifest := range paneManifest.Probes {
+				probe, err := paneProbe(probeManifest)
+				if err != nil {
+					return "", fmt.Errorf("pane %q: %w", paneManifest.PaneName, err)
+				}
+				if err := pane.AddProbe(probe); err != nil {
+					return "", fmt.Errorf("pane %q: %w", paneManifest.PaneName, err)
+				}
+			}
+
 			// 4. Spawn the startup shell for the pane.
-			shell, err := pane.SpawnShell(paneManifest.StartupShell.Interactive, paneManifest.StartupShell.Command...)
+			spec := paneManifest.StartupShell
+			restart, err := restartOptions(spec.Restart)
+			if err != nil {
+				return "", fmt.Errorf("pane %q: %w", paneManifest.PaneName, err)
+			}
+			var dialect shell.Dialect
+			if spec.Dialect != "" {
+				if dialect, err = shell.LookupDialect(spec.Dialect); err != nil {
+					return "", fmt.Errorf("pane %q: %w", paneManifest.PaneName, err)
+				}
+			}
+			startupShell, err := pane.SpawnShellWithOptions(shell.SpawnOptions{
+				Interactive: spec.Interactive,
+				Command:     spec.Command,
+				Dir:         spec.Cwd,
+				Env:         spec.Env,
+				Restart:     restart,
+				Dialect:     dialect,
+			})
 			if err != nil {
 				return "", err
 			}
 
 			// 5. Send any startup commands to the newly created shell.
 			for _, cmd := range paneManifest.StartupCommands {
-				_ = shell.SendCommand(cmd)
+				_ = startupShell.SendCommandAs("manifest", cmd)
 			}
 		}
 	}
 
 	return sessionID, nil
 }
+
+// scrollbackLimits converts a manifest scrollback block into shell limits.
+func scrollbackLimits(m *manifest.ScrollbackManifest) shell.ScrollbackLimits {
+	return shell.ScrollbackLimits{MaxLines: m.MaxLines, MaxBytes: m.MaxBytes}
+}
+
+// applyPaneLogs applies a manifest pane's logs block, layered over the
+// manager's log options.
+func (sm *SessionManager) applyPaneLogs(p *pane.PaneManager, m *manifest.LogsManifest) error {
+	if m.Disabled {
+		return p.DisableLogs()
+	}
+	var opts pane.LogOptions
+	if sm.logs != nil {
+		opts = *sm.logs
+	}
+	if m.Dir != "" {
+		opts.Dir = m.Dir
+	}
+	if m.MaxBytes != 0 {
+		opts.Rotation.MaxBytes = m.MaxBytes
+	}
+	if m.MaxBackups != 0 {
+		opts.Rotation.MaxBackups = m.MaxBackups
+	}
+	if m.NDJSON != nil {
+		opts.NDJSON = *m.NDJSON
+	}
+	for _, d := range []struct {
+		name  string
+		value string
+		dst   *time.Duration
+	}{
+		{"interval", m.Interval, &opts.Rotation.Interval},
+		{"maxAge", m.MaxAge, &opts.Rotation.MaxAge},
+	} {
+		if d.value == "" {
+			continue
+		}
+		var err error
+		if *d.dst, err = time.ParseDuration(d.value); err != nil {
+			return fmt.Errorf("invalid logs %s %q: %w", d.name, d.value, err)
+		}
+	}
+	return p.EnableLogs(opts)
+}
+
+// restartOptions converts a manifest restart block into shell restart
+// options. A nil block means the shell is not supervised.
+func restartOptions(m *manifest.RestartManifest) (*shell.RestartOptions, error) {
+	if m == nil {
+		return nil, nil
+	}
+	policy, err := shell.ParseRestartPolicy(m.Policy)
+	if err != nil {
+		return nil, err
+	}
+	opts := &shell.RestartOptions{Policy: policy, MaxRestarts: m.MaxRestarts}
+	for _, d := range []struct {
+		name  string
+		value string
+		dst   *time.Duration
+	}{
+		{"initialBackoff", m.InitialBackoff, &opts.InitialBackoff},
+		{"maxBackoff", m.MaxBackoff, &opts.MaxBackoff},
+		{"window", m.Window, &opts.Window},
+	} {
+		if d.value == "" {
+			continue
+		}
+		if *d.dst, err = time.ParseDuration(d.value); err != nil {
+			return nil, fmt.Errorf("invalid restart %s %q: %w", d.name, d.value, err)
+		}
+	}
+	return opts, nil
+}
+
+// paneProbe converts a manifest probe into a pane probe.
+func paneProbe(m manifest.ProbeManifest) (pane.Probe, error) {
+	probe := pane.Probe{Command: m.Command, Port: m.Port, File: m.File, Tag: m.Tag, Value: m.Value}
+	if m.Output != "" {
+		re, err := regexp.Compile(m.Output)
+		if err != nil {
+			return pane.Probe{}, fmt.Errorf("invalid probe output pattern: %w", err)
+		}
+		probe.Output = re
+	}
+	var err error
+	if m.Interval != "" {
+		if probe.Interval, err = time.ParseDuration(m.Interval); err != nil {
+			return pane.Probe{}, fmt.Errorf("invalid probe interval %q: %w", m.Interval, err)
+		}
+	}
+	if m.Timeout != "" {
+		if probe.Timeout, err = time.ParseDuration(m.Timeout); err != nil {
+			return pane.Probe{}, fmt.Errorf("invalid probe timeout %q: %w", m.Timeout, err)
+		}
+	}
+	return probe, nil
+}
@@ -0,0 +1,125 @@
+package session_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/owen-6936/termplex/assert"
+	"github.com/owen-6936/termplex/fanout"
+	"github.com/owen-6936/termplex/pane"
+	"github.com/owen-6936/termplex/session"
+)
+
+// syncBuffer is a bytes.Buffer safe for concurrent writes from shell goroutines.
+type syncBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *syncBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
+
+func TestLoggerCarriesHierarchyAttributes(t *testing.T) {
+	var logs syncBuffer
+	sm := session.NewSessionManager(1)
+	sm.SetLogger(slog.New(slog.NewJSONHandler(&logs, nil)))
+
+	// 1. Build a session down to a running shell.
+	sessionID, err := sm.CreateSession("logging", nil)
+	assert.NoError(t, err)
+	windowID, err := sm.AddWindow(sessionID, "main", nil)
+	assert.NoError(t, err)
+	paneID, err := sm.Windows[windowID].AddPane("worker")
+	assert.NoError(t, err)
+	pm, _ := sm.Windows[windowID].GetPane(paneID)
+	sh, err := pm.SpawnShell(false, "true")
+	assert.NoError(t, err)
+	assert.NoError(t, sm.TerminateSession(sessionID))
+
+	// 2. The shell's records carry the IDs of every level above it.
+	var spawned map[string]any
+	for line := range bytes.Lines([]byte(logs.String())) {
+		var record map[string]any
+		assert.NoError(t, json.Unmarshal(line, &record))
+		if record["msg"] == "shell spawned" {
+			spawned = record
+		}
+	}
+	assert.True(t, spawned != nil, "No 'shell spawned' record in logs:\n%s", logs.String())
+	assert.True(t, spawned["session_id"] == sessionID, "Unexpected session_id in %v", spawned)
+	assert.True(t, spawned["window_id"] == windowID, "Unexpected window_id in %v", spawned)
+	assert.True(t, spawned["pane_id"] == paneID, "Unexpected pane_id in %v", spawned)
+	assert.True(t, spawned["shell_id"] == sh.ID, "Unexpected shell_id in %v", spawned)
+}
+
+func TestSessionManagerIsSilentByDefault(t *testing.T) {
+	sm := session.NewSessionManager(1)
+
+	// Nothing should reach a logger unless one is configured; a nil logger
+	// keeps the manager silent rather than panicking.
+	sm.SetLogger(nil)
+	sessionID, err := sm.CreateSession("quiet", nil)
+	assert.NoError(t, err)
+	assert.NoError(t, sm.TerminateSession(sessionID))
+}
+
+func TestAddWindowKeepsWindowIDInStep(t *testing.T) {
+	sm := session.NewSessionManager(1)
+	sessionID, err := sm.CreateSession("ids", nil)
+	assert.NoError(t, err)
+	t.Cleanup(func() { _ = sm.TerminateSession(sessionID) })
+
+	// The window's own ID must be the key the session stores it under.
+	windowID, err := sm.AddWindow(sessionID, "main", nil)
+	assert.NoError(t, err)
+	wm, ok := sm.Windows[windowID]
+	assert.True(t, ok, "Expected the window under the returned ID")
+	assert.True(t, wm.ID == windowID, "Expected window ID %s, got %s", windowID, wm.ID)
+}
+
+func TestSubscribeReceivesOutputFromEveryLevel(t *testing.T) {
+	sm := session.NewSessionManager(1)
+
+	// 1. Subscribe before anything exists; later windows and panes are included.
+	all, _ := sm.Subscribe(fanout.Options{Policy: fanout.DropOldest})
+	sessionID, err := sm.CreateSession("fanout", nil)
+	assert.NoError(t, err)
+	windowID, err := sm.AddWindow(sessionID, "main", nil)
+	assert.NoError(t, err)
+	wm := sm.Windows[windowID]
+	window, _ := wm.Subscribe(fanout.Options{})
+	paneID, err := wm.AddPane("worker")
+	assert.NoError(t, err)
+	pm, _ := wm.GetPane(paneID)
+	_, err = pm.SpawnShell(false, "echo", "fanned-out")
+	assert.NoError(t, err)
+
+	// 2. The same chunk reaches the window and session subscribers.
+	for _, ch := range []<-chan pane.PaneOutput{window, all} {
+		select {
+		case out := <-ch:
+			assert.Contains(t, string(out.Data), "fanned-out")
+			assert.True(t, out.PaneID == paneID, "Expected pane ID %s, got %s", paneID, out.PaneID)
+		case <-time.After(3 * time.Second):
+			t.Fatal("Timed out waiting for output")
+		}
+	}
+
+	// 3. Terminating the window ends its stream.
+	assert.NoError(t, sm.TerminateSession(sessionID))
+	for range window {
+	}
+}
@@ -1,10 +1,16 @@
 package session_test
 
 import (
+	"context"
+	"os"
+	"path/filepath"
 	"testing"
 	"time"
 
 	"github.com/owen-6936/termplex/assert"
+	"github.com/owen-6936/termplex/pane"
+	"github.com/owen-6936/termplex/session"
+	"github.com/owen-6936/termplex/shell"
 	"github.com/owen-6936/termplex/testenv"
 )
 
@@ -40,3 +46,95 @@ func TestCreateSessionFromManifest_Harness(t *testing.T) {
 		assert.True(t, pane.InteractiveShell != nil || len(pane.Shells.Shells) > 0, "Expected pane %s to have at least one shell", pane.ID)
 	}
 }
+
+func TestCreateSessionFromManifestUsesShellPool(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "pool.termplex.json")
+	content := []byte(`{
+		"sessionName": "Pooled",
+		"windows": [{
+			"windowName": "main",
+			"panes": [{
+				"startupShell": {"interactive": false, "command": ["sh"], "cwd": "` + dir + `", "env": {"ROLE": "worker"}}
+			}]
+		}]
+	}`)
+	assert.NoError(t, os.WriteFile(path, content, 0o644))
+
+	// 1. Warm a pool for exactly the shell the manifest asks for.
+	pool := shell.NewShellPool(shell.PoolOptions{Size: 1})
+	t.Cleanup(func() { _ = pool.Close() })
+	opts := shell.SpawnOptions{Command: []string{"sh"}, Dir: dir, Env: map[string]string{"ROLE": "worker"}}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	assert.NoError(t, pool.Warm(ctx, opts))
+
+	// 2. The pane's startup shell comes out of the pool.
+	sm := session.NewSessionManager(1)
+	sm.SetShellPool(pool)
+	before := time.Now()
+	sessionID, err := sm.CreateSessionFromManifest(path)
+	assert.NoError(t, err)
+	t.Cleanup(func() { _ = sm.TerminateSession(sessionID) })
+
+	for _, wm := range sm.Windows {
+		for _, p := range wm.Panes {
+			shells := p.Shells.List()
+			assert.True(t, len(shells) == 1, "Expected one shell, got %d", len(shells))
+			assert.True(t, shells[0].StartedAt.Before(before), "Expected the pooled shell")
+			result, err := shells[0].Run(ctx, "echo $ROLE")
+			assert.NoError(t, err)
+			assert.Contains(t, result.Output, "worker")
+		}
+	}
+}
+
+func TestCreateSessionFromManifestOverridesPaneLogs(t *testing.T) {
+	root, other := t.TempDir(), t.TempDir()
+	path := filepath.Join(t.TempDir(), "logs.termplex.json")
+	content := []byte(`{
+		"sessionName": "Logged",
+		"windows": [{
+			"windowName": "main",
+			"panes": [
+				{"paneName": "default", "startupShell": {"command": ["echo", "default"]}},
+				{"paneName": "quiet", "startupShell": {"command": ["echo", "quiet"]}, "logs": {"disabled": true}},
+				{"paneName": "custom", "startupShell": {"command": ["echo", "custom"]}, "logs": {"dir": "` + other + `", "ndjson": true, "maxAge": "24h"}}
+			]
+		}]
+	}`)
+	assert.NoError(t, os.WriteFile(path, content, 0o644))
+
+	// 1. The session manager's options apply unless a pane overrides them.
+	sm := session.NewSessionManager(1)
+	assert.NoError(t, sm.SetLogOptions(pane.LogOptions{Dir: root}))
+	sessionID, err := sm.CreateSessionFromManifest(path)
+	assert.NoError(t, err)
+
+	dirs := make(map[string]string)
+	for _, wm := range sm.Windows {
+		for _, p := range wm.Panes {
+			dirs[p.Name] = p.LogDir()
+		}
+	}
+	assert.True(t, filepath.Dir(dirs["default"]) == root, "Unexpected default log dir %q", dirs["default"])
+	assert.True(t, dirs["quiet"] == "", "Expected no logs for the quiet pane, got %q", dirs["quiet"])
+	assert.True(t, filepath.Dir(dirs["custom"]) == other, "Unexpected custom log dir %q", dirs["custom"])
+
+	// 2. Terminating the session flushes the logs.
+	time.Sleep(200 * time.Millisecond) // Let the startup shells print.
+	assert.NoError(t, sm.TerminateSession(sessionID))
+	logs, _ := filepath.Glob(filepath.Join(dirs["default"], "*.log"))
+	assert.True(t, len(logs) == 1, "Expected one shell log, got %v", logs)
+	data, err := os.ReadFile(logs[0])
+	assert.NoError(t, err)
+	assert.True(t, string(data) == "default\n", "Unexpected log %q", data)
+	_, err = os.Stat(filepath.Join(dirs["custom"], pane.NDJSONLogName))
+	assert.NoError(t, err)
+
+	// 3. A bad override fails the manifest.
+	bad := []byte(`{"sessionName": "Bad", "windows": [{"panes": [{"startupShell": {"command": ["true"]}, "logs": {"interval": "soon"}}]}]}`)
+	assert.NoError(t, os.WriteFile(path, bad, 0o644))
+	_, err = sm.CreateSessionFromManifest(path)
+	assert.True(t, err != nil, "Expected an invalid interval to be rejected")
+}
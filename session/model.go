@@ -1,13 +1,21 @@
 package session
 
-import "time"
+import (
+	"time"
+
+	"github.com/owen-6936/termplex/cgroup"
+	"github.com/owen-6936/termplex/shell"
+)
 
 // Session represents a top-level orchestration unit, like a workspace or project.
 // It owns windows, tracks creation metadata, and supports tagging for contributor clarity.
 type Session struct {
-	ID         string            // Unique session ID
-	Name       string            // Human-readable name (e.g. "LLM Session")
-	CreatedAt  time.Time         // Timestamp of session creation
-	Tags       map[string]string // Optional metadata (e.g. project, owner, purpose)
-	WindowRefs map[string]bool   // Map of window IDs owned by this session
+	ID         string                  // Unique session ID
+	Name       string                  // Human-readable name (e.g. "LLM Session")
+	CreatedAt  time.Time               // Timestamp of session creation
+	Tags       map[string]string       // Optional metadata (e.g. project, owner, purpose)
+	WindowRefs map[string]bool         // Map of window IDs owned by this session
+	Scrollback *shell.ScrollbackLimits // Output retention for every shell in the session; nil uses the defaults
+	Limits     *shell.ResourceLimits   // setrlimit limits for every shell in the session; nil sets none
+	Cgroup     *cgroup.Group           // cgroup shared by every shell in the session; nil leaves them in termplex's
 }
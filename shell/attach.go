@@ -0,0 +1,275 @@
+package shell
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+	"unsafe"
+)
+
+// DefaultDetachKeys detach from an attached shell, like tmux's prefix and d.
+var DefaultDetachKeys = []string{"C-b", "d"}
+
+// AttachOptions configures Attach.
+type AttachOptions struct {
+	// DetachKeys is the key sequence, in SendKeys notation, that detaches the
+	// terminal. It is never forwarded to the shell. Nil uses DefaultDetachKeys.
+	DetachKeys []string
+}
+
+// Attach connects a local terminal to a PTY shell, like tmux attach. If in is
+// a terminal it is put in raw mode and its size is applied to the shell,
+// following every SIGWINCH; the current screen is drawn first. Keystrokes
+// from in are forwarded to the shell and its output is written to out until
+// the detach keys are typed, in reaches EOF or ctx is done. The shell keeps
+// running after detaching. If the shell exits, Attach returns an error
+// wrapping os.ErrProcessDone.
+func (s *ShellSession) Attach(ctx context.Context, in *os.File, out io.Writer, opts AttachOptions) error {
+	if s.pty == nil {
+		return fmt.Errorf("session %s is not attached to a PTY", s.ID)
+	}
+	if opts.DetachKeys == nil {
+		opts.DetachKeys = DefaultDetachKeys
+	}
+	detach, err := EncodeKeys(false, opts.DetachKeys...)
+	if err != nil {
+		return fmt.Errorf("invalid detach keys %q: %w", strings.Join(opts.DetachKeys, " "), err)
+	}
+	if len(detach) == 0 {
+		return errors.New("detach keys must not be empty")
+	}
+
+	fd := int(in.Fd())
+	if saved, err := makeRaw(fd); err == nil {
+		defer restoreTerminal(fd, saved)
+
+		winch := make(chan os.Signal, 1)
+		signal.Notify(winch, syscall.SIGWINCH)
+		defer func() {
+			signal.Stop(winch)
+			close(winch)
+		}()
+		s.followSize(fd)
+		go func() {
+			for range winch {
+				s.followSize(fd)
+			}
+		}()
+	}
+
+	// Hold the output until the current screen has been drawn.
+	tap := &attachTap{out: out}
+	tap.mu.Lock()
+	remove := s.addRawTap(tap)
+	defer remove()
+	snap, _ := s.Screen()
+	_, err = out.Write(redraw(snap.Lines(), snap.Cursor.Row, snap.Cursor.Col))
+	tap.mu.Unlock()
+	if err != nil {
+		return fmt.Errorf("failed to draw session %s: %w", s.ID, err)
+	}
+	defer func() {
+		// Leave modes the shell's program set on the local terminal.
+		if snap, err := s.Screen(); err == nil && (snap.AltScreen || snap.AppCursorKeys) {
+			tap.write([]byte("\x1b[?1l\x1b[?1049l"))
+		}
+	}()
+
+	input, err := newInterruptibleReader(fd, in.Name())
+	if err != nil {
+		return fmt.Errorf("failed to read from %s: %w", in.Name(), err)
+	}
+	defer input.Close()
+
+	detached := make(chan error, 1)
+	go func() { detached <- s.forwardInput(input, detach) }()
+
+	select {
+	case err = <-detached:
+		return err
+	case <-ctx.Done():
+		err = ctx.Err()
+	case <-s.Done():
+		err = fmt.Errorf("session %s: %w", s.ID, os.ErrProcessDone)
+	}
+	if input.interrupt() {
+		<-detached // Nothing typed before detaching is forwarded afterwards.
+	}
+	return err
+}
+
+// forwardInput copies keystrokes to the shell until the detach sequence is
+// typed or r ends. Bytes that might start the sequence are held back until
+// it is clear they do not.
+func (s *ShellSession) forwardInput(r io.Reader, detach []byte) error {
+	buf := make([]byte, 4096)
+	matched := 0
+	for {
+		n, err := r.Read(buf)
+		var forward []byte
+		for _, b := range buf[:n] {
+			if b == detach[matched] {
+				matched++
+				if matched == len(detach) {
+					return s.writeAll(forward)
+				}
+				continue
+			}
+			forward = append(forward, detach[:matched]...)
+			matched = 0
+			if b == detach[0] {
+				matched = 1
+				continue
+			}
+			forward = append(forward, b)
+		}
+		if werr := s.writeAll(forward); werr != nil {
+			return werr
+		}
+		if errors.Is(err, io.EOF) || errors.Is(err, os.ErrDeadlineExceeded) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// writeAll writes p to the shell, unless it is empty.
+func (s *ShellSession) writeAll(p []byte) error {
+	if len(p) == 0 {
+		return nil
+	}
+	return s.writeInput(p)
+}
+
+// followSize resizes the shell to the terminal on fd.
+func (s *ShellSession) followSize(fd int) {
+	var ws struct{ Row, Col, X, Y uint16 }
+	if err := ioctl(fd, syscall.TIOCGWINSZ, unsafe.Pointer(&ws)); err != nil || ws.Row == 0 || ws.Col == 0 {
+		return
+	}
+	if err := s.Resize(ws.Row, ws.Col); err != nil {
+		s.log().Warn("failed to follow terminal size", "error", err)
+	}
+}
+
+// redraw returns the bytes that clear a terminal and draw lines on it, with
+// the cursor at row, col.
+func redraw(lines []string, row, col int) []byte {
+	var b bytes.Buffer
+	b.WriteString("\x1b[H\x1b[2J")
+	b.WriteString(strings.Join(lines, "\r\n"))
+	fmt.Fprintf(&b, "\x1b[%d;%dH", row+1, col+1)
+	return b.Bytes()
+}
+
+// attachTap writes an attached shell's output to the local terminal.
+type attachTap struct {
+	mu  sync.Mutex
+	out io.Writer
+}
+
+func (t *attachTap) Output(data []byte) error {
+	_, err := t.write(data)
+	return err
+}
+
+func (t *attachTap) write(data []byte) (int, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.out.Write(data)
+}
+
+func (t *attachTap) Input([]byte) error             { return nil }
+func (t *attachTap) Resize(rows, cols uint16) error { return nil }
+
+// interruptibleReader reads a descriptor through the runtime poller, so a
+// blocked read can be cut short when Attach returns. It reads a duplicate of
+// the descriptor; the non-blocking mode this needs is undone on Close.
+type interruptibleReader struct {
+	*os.File
+	fd          int
+	wasBlocking bool
+}
+
+func newInterruptibleReader(fd int, name string) (*interruptibleReader, error) {
+	dup, err := syscall.Dup(fd)
+	if err != nil {
+		return nil, err
+	}
+	flags, err := fcntl(fd, syscall.F_GETFL, 0)
+	if err != nil {
+		syscall.Close(dup)
+		return nil, err
+	}
+	if err := syscall.SetNonblock(dup, true); err != nil {
+		syscall.Close(dup)
+		return nil, err
+	}
+	return &interruptibleReader{File: os.NewFile(uintptr(dup), name), fd: fd, wasBlocking: flags&syscall.O_NONBLOCK == 0}, nil
+}
+
+// interrupt wakes a pending Read, which returns os.ErrDeadlineExceeded. It
+// reports false for descriptors the poller cannot watch, such as regular
+// files, whose reader then stops after its current Read.
+func (r *interruptibleReader) interrupt() bool {
+	return r.SetReadDeadline(time.Now()) == nil
+}
+
+func (r *interruptibleReader) Close() error {
+	err := r.File.Close()
+	if r.wasBlocking {
+		_ = syscall.SetNonblock(r.fd, false)
+	}
+	return err
+}
+
+// makeRaw puts the terminal on fd in raw mode, like cfmakeraw, and returns
+// its previous state. It fails if fd is not a terminal.
+func makeRaw(fd int) (*syscall.Termios, error) {
+	var saved syscall.Termios
+	if err := ioctl(fd, syscall.TCGETS, unsafe.Pointer(&saved)); err != nil {
+		return nil, err
+	}
+	raw := saved
+	raw.Iflag &^= syscall.IGNBRK | syscall.BRKINT | syscall.PARMRK | syscall.ISTRIP | syscall.INLCR | syscall.IGNCR | syscall.ICRNL | syscall.IXON
+	raw.Oflag &^= syscall.OPOST
+	raw.Lflag &^= syscall.ECHO | syscall.ECHONL | syscall.ICANON | syscall.ISIG | syscall.IEXTEN
+	raw.Cflag &^= syscall.CSIZE | syscall.PARENB
+	raw.Cflag |= syscall.CS8
+	raw.Cc[syscall.VMIN] = 1
+	raw.Cc[syscall.VTIME] = 0
+	if err := ioctl(fd, syscall.TCSETS, unsafe.Pointer(&raw)); err != nil {
+		return nil, err
+	}
+	return &saved, nil
+}
+
+// restoreTerminal puts back the state saved by makeRaw.
+func restoreTerminal(fd int, saved *syscall.Termios) {
+	_ = ioctl(fd, syscall.TCSETS, unsafe.Pointer(saved))
+}
+
+func ioctl(fd int, req uintptr, arg unsafe.Pointer) error {
+	if _, _, errno := syscall.Syscall(syscall.SYS_IOCTL, uintptr(fd), req, uintptr(arg)); errno != 0 {
+		return errno
+	}
+	return nil
+}
+
+func fcntl(fd, cmd, arg int) (int, error) {
+	r, _, errno := syscall.Syscall(syscall.SYS_FCNTL, uintptr(fd), uintptr(cmd), uintptr(arg))
+	if errno != 0 {
+		return 0, errno
+	}
+	return int(r), nil
+}
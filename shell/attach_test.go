@@ -0,0 +1,185 @@
+package shell_test
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"strings"
+	"sync"
+	"syscall"
+	"testing"
+	"time"
+	"unsafe"
+
+	"github.com/creack/pty"
+	"github.com/owen-6936/termplex/assert"
+	"github.com/owen-6936/termplex/shell"
+)
+
+// syncBuffer is a bytes.Buffer safe for concurrent use.
+type syncBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *syncBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
+
+// waitFor polls cond until it holds or the timeout passes.
+func waitFor(timeout time.Duration, cond func() bool) bool {
+	deadline := time.Now().Add(timeout)
+	for time.Now().Before(deadline) {
+		if cond() {
+			return true
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	return cond()
+}
+
+func lflag(t *testing.T, f *os.File) uint32 {
+	var termios syscall.Termios
+	_, _, errno := syscall.Syscall(syscall.SYS_IOCTL, f.Fd(), syscall.TCGETS, uintptr(unsafe.Pointer(&termios)))
+	if errno != 0 {
+		t.Fatalf("TCGETS failed: %v", errno)
+	}
+	return termios.Lflag
+}
+
+func TestAttachForwardsTerminalAndDetaches(t *testing.T) {
+	sm := shell.NewShellManager(nil)
+	t.Cleanup(func() { _ = sm.TerminateAllShells() })
+	session, err := sm.SpawnShell(true, "bash", "--norc", "-i")
+	assert.NoError(t, err)
+
+	// The local terminal is the slave side of a PTY the test types into.
+	keyboard, local, err := pty.Open()
+	assert.NoError(t, err)
+	t.Cleanup(func() { keyboard.Close(); local.Close() })
+	assert.NoError(t, pty.Setsize(keyboard, &pty.Winsize{Rows: 30, Cols: 100}))
+
+	out := &syncBuffer{}
+	attached := make(chan error, 1)
+	go func() { attached <- sm.Attach(context.Background(), session.ID, local, out) }()
+
+	// 1. The terminal goes raw and the shell takes its size.
+	assert.True(t, waitFor(2*time.Second, func() bool { return lflag(t, local)&syscall.ICANON == 0 }), "Expected raw mode")
+	assert.True(t, waitFor(2*time.Second, func() bool {
+		size, _ := session.Size()
+		return size == shell.WindowSize{Rows: 30, Cols: 100}
+	}), "Expected the shell to take the terminal's size")
+
+	// 2. Keystrokes reach the shell and its output comes back.
+	_, err = keyboard.Write([]byte("echo attached-$((40 + 2))\r"))
+	assert.NoError(t, err)
+	assert.True(t, waitFor(2*time.Second, func() bool { return strings.Contains(out.String(), "attached-42") }), "Expected output, got %q", out.String())
+
+	// 3. A SIGWINCH resizes the shell to the terminal again.
+	assert.NoError(t, pty.Setsize(keyboard, &pty.Winsize{Rows: 40, Cols: 120}))
+	assert.NoError(t, syscall.Kill(os.Getpid(), syscall.SIGWINCH))
+	assert.True(t, waitFor(2*time.Second, func() bool {
+		size, _ := session.Size()
+		return size == shell.WindowSize{Rows: 40, Cols: 120}
+	}), "Expected the shell to follow the resize")
+
+	// 4. C-b d detaches without reaching the shell, and the terminal is restored.
+	_, err = keyboard.Write([]byte("\x02d"))
+	assert.NoError(t, err)
+	select {
+	case err := <-attached:
+		assert.NoError(t, err)
+	case <-time.After(2 * time.Second):
+		t.Fatal("Expected Attach to return after the detach keys")
+	}
+	assert.True(t, lflag(t, local)&syscall.ICANON != 0, "Expected the terminal mode to be restored")
+
+	// 5. The shell keeps running.
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	result, err := session.Run(ctx, "echo still-running")
+	assert.NoError(t, err)
+	assert.Contains(t, result.Output, "still-running")
+	assert.True(t, !strings.Contains(session.Scrollback().String(), "\x02"), "Expected the detach keys to be swallowed")
+}
+
+func TestAttachCustomDetachKeys(t *testing.T) {
+	sm := shell.NewShellManager(nil)
+	t.Cleanup(func() { _ = sm.TerminateAllShells() })
+	session, err := sm.SpawnShell(true, "bash", "--norc", "-i")
+	assert.NoError(t, err)
+
+	// A pipe stands in for a terminal that is not a TTY.
+	r, w, err := os.Pipe()
+	assert.NoError(t, err)
+	t.Cleanup(func() { r.Close(); w.Close() })
+
+	out := &syncBuffer{}
+	attached := make(chan error, 1)
+	go func() {
+		attached <- sm.AttachWithOptions(context.Background(), session.ID, r, out, shell.AttachOptions{DetachKeys: []string{"C-a", "q"}})
+	}()
+
+	// 1. A partial match is forwarded once it turns out not to be the sequence.
+	_, err = w.Write([]byte("echo \x01x-$((1 + 1))\r"))
+	assert.NoError(t, err)
+	assert.True(t, waitFor(2*time.Second, func() bool { return strings.Contains(out.String(), "x-2") }), "Expected output, got %q", out.String())
+
+	// 2. The custom sequence detaches.
+	_, err = w.Write([]byte("\x01q"))
+	assert.NoError(t, err)
+	select {
+	case err := <-attached:
+		assert.NoError(t, err)
+	case <-time.After(2 * time.Second):
+		t.Fatal("Expected Attach to return after the detach keys")
+	}
+}
+
+func TestAttachBypassesFilters(t *testing.T) {
+	sm := shell.NewShellManager(nil)
+	t.Cleanup(func() { _ = sm.TerminateAllShells() })
+	session, err := sm.SpawnShellWithOptions(shell.SpawnOptions{
+		Interactive: true,
+		Command:     []string{"bash", "--norc", "--noprofile"},
+		Env:         map[string]string{"PS1": "$ "},
+		Filters:     []shell.Filter{shell.StripANSIFilter(), shell.TimestampFilter("[ts]")},
+	})
+	assert.NoError(t, err)
+
+	r, w, err := os.Pipe()
+	assert.NoError(t, err)
+	t.Cleanup(func() { r.Close(); w.Close() })
+
+	out := &syncBuffer{}
+	attached := make(chan error, 1)
+	go func() { attached <- sm.Attach(context.Background(), session.ID, r, out) }()
+
+	// The terminal gets the program's escape sequences and the prompt at
+	// once, with no timestamps, while the scrollback is filtered.
+	_, err = w.Write([]byte("printf '\\033[1mbold\\033[0m\\n'\r"))
+	assert.NoError(t, err)
+	assert.True(t, waitFor(2*time.Second, func() bool {
+		return strings.Contains(out.String(), "\x1b[1mbold\x1b[0m\r\n") && strings.HasSuffix(out.String(), "$ ")
+	}), "Expected raw output and the prompt, got %q", out.String())
+	assert.True(t, !strings.Contains(out.String(), "[ts]"), "Expected no timestamps on the terminal, got %q", out.String())
+	scrollback := session.Scrollback().String()
+	assert.True(t, strings.Contains(scrollback, "[ts]") && !strings.Contains(scrollback, "\x1b[1m"), "Expected filtered scrollback, got %q", scrollback)
+
+	_, err = w.Write([]byte("\x02d"))
+	assert.NoError(t, err)
+	select {
+	case err := <-attached:
+		assert.NoError(t, err)
+	case <-time.After(2 * time.Second):
+		t.Fatal("Expected Attach to return after the detach keys")
+	}
+}
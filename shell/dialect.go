@@ -0,0 +1,199 @@
+package shell
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// Dialect adapts Run to the syntax of a shell or REPL.
+type Dialect interface {
+	// Name identifies the dialect, as written in a manifest.
+	Name() string
+	// WrapCommand returns input that prints the begin marker on a line of
+	// its own, runs command, and then prints the end marker followed by ":"
+	// and the command's exit status. The command may span several lines or
+	// end in a comment or `&`. The markers must be assembled when the input
+	// runs, for example from two string halves, so a terminal echoing the
+	// typed input never prints them.
+	WrapCommand(command, begin, end string) string
+	// Prompt matches the dialect's default prompt at the end of the output.
+	Prompt() *regexp.Regexp
+}
+
+// The built-in dialects.
+var (
+	Bash   Dialect = posixDialect{name: "bash", prompt: regexp.MustCompile(`[$#] $`)}
+	Zsh    Dialect = posixDialect{name: "zsh", prompt: regexp.MustCompile(`[%#$] $`)}
+	Sh     Dialect = posixDialect{name: "sh", prompt: regexp.MustCompile(`[$#] $`)}
+	Fish   Dialect = fishDialect{}
+	Python Dialect = pythonDialect{}
+	Node   Dialect = nodeDialect{}
+)
+
+var dialects = map[string]Dialect{
+	"bash": Bash, "zsh": Zsh, "sh": Sh, "fish": Fish, "python": Python, "node": Node,
+}
+
+// LookupDialect returns the built-in dialect with the given name: "bash",
+// "zsh", "sh", "fish", "python" or "node".
+func LookupDialect(name string) (Dialect, error) {
+	if d, ok := dialects[name]; ok {
+		return d, nil
+	}
+	return nil, fmt.Errorf("unknown shell dialect %q", name)
+}
+
+// DetectDialect picks a dialect from the program a shell runs, such as
+// "/bin/zsh", "python3.12" or "nodejs". Unknown programs get Sh.
+func DetectDialect(command []string) Dialect {
+	if len(command) == 0 {
+		return Sh
+	}
+	name := strings.TrimRight(filepath.Base(command[0]), "0123456789.")
+	switch name {
+	case "bash", "zsh", "fish":
+		return dialects[name]
+	case "python", "ipython", "pypy":
+		return Python
+	case "node", "nodejs":
+		return Node
+	}
+	return Sh
+}
+
+// splitMarker cuts a marker in two, so it can be joined at run time.
+func splitMarker(marker string) (string, string) {
+	half := len(marker) / 2
+	return marker[:half], marker[half:]
+}
+
+// posixDialect covers bash, zsh and POSIX sh. Markers are printed with
+// printf rather than `echo -n`, which is not portable. The command goes in
+// a brace group on lines of its own, so a trailing comment or `&` cannot
+// swallow or break the end marker, and the shell parses the whole group
+// before running any of it.
+type posixDialect struct {
+	name   string
+	prompt *regexp.Regexp
+}
+
+func (d posixDialect) Name() string           { return d.name }
+func (d posixDialect) Prompt() *regexp.Regexp { return d.prompt }
+
+func (d posixDialect) WrapCommand(command, begin, end string) string {
+	b1, b2 := splitMarker(begin)
+	e1, e2 := splitMarker(end)
+	return fmt.Sprintf("printf '%%s%%s\\n' '%s' '%s'; {\n%s\n}; printf '%%s%%s:%%d\\n' '%s' '%s' $?", b1, b2, groupBody(command), e1, e2)
+}
+
+// groupBody prepares a command for a block on lines of its own. An empty
+// block is a syntax error, so a blank command becomes a no-op.
+func groupBody(command string) string {
+	command = strings.TrimSpace(command)
+	if command == "" {
+		return "true"
+	}
+	return command
+}
+
+// fishDialect reads the exit status from $status and groups the command in
+// a begin/end block.
+type fishDialect struct{}
+
+var fishPrompt = regexp.MustCompile(`[>#] $`)
+
+func (fishDialect) Name() string           { return "fish" }
+func (fishDialect) Prompt() *regexp.Regexp { return fishPrompt }
+
+func (fishDialect) WrapCommand(command, begin, end string) string {
+	b1, b2 := splitMarker(begin)
+	e1, e2 := splitMarker(end)
+	return fmt.Sprintf("printf '%%s%%s\\n' '%s' '%s'; begin\n%s\nend; printf '%%s%%s:%%d\\n' '%s' '%s' $status", b1, b2, groupBody(command), e1, e2)
+}
+
+// pythonDialect runs code in the REPL's globals. Expressions echo their value
+// as they would at the prompt; an uncaught exception prints its traceback and
+// reports status 1, and sys.exit reports its code.
+type pythonDialect struct{}
+
+var pythonPrompt = regexp.MustCompile(`(>>>|\.\.\.) $`)
+
+func (pythonDialect) Name() string           { return "python" }
+func (pythonDialect) Prompt() *regexp.Regexp { return pythonPrompt }
+
+const pythonRunner = `import sys as _tp_sys, traceback as _tp_tb
+try:
+    try:
+        _tp_code = compile(%[1]s, '<termplex>', 'single')
+    except SyntaxError:
+        _tp_code = compile(%[1]s, '<termplex>', 'exec')
+    exec(_tp_code, globals())
+    _tp_status = 0
+except SystemExit as _tp_e:
+    _tp_status = _tp_e.code if isinstance(_tp_e.code, int) else (0 if _tp_e.code is None else 1)
+except BaseException:
+    _tp_tb.print_exc()
+    _tp_status = 1
+_tp_sys.stderr.flush()
+`
+
+func (pythonDialect) WrapCommand(command, begin, end string) string {
+	b1, b2 := splitMarker(begin)
+	e1, e2 := splitMarker(end)
+	runner := fmt.Sprintf(pythonRunner, jsString(command))
+	return fmt.Sprintf("print(%s + %s, flush=True); exec(%s); print(%s + %s + ':' + str(_tp_status), flush=True)",
+		jsString(b1), jsString(b2), jsString(runner), jsString(e1), jsString(e2))
+}
+
+// nodeDialect evaluates code in the global scope and prints the value of
+// its last expression, like the REPL. A thrown error is printed and reports
+// status 1.
+type nodeDialect struct{}
+
+var nodePrompt = regexp.MustCompile(`> $`)
+
+func (nodeDialect) Name() string           { return "node" }
+func (nodeDialect) Prompt() *regexp.Regexp { return nodePrompt }
+
+func (nodeDialect) WrapCommand(command, begin, end string) string {
+	b1, b2 := splitMarker(begin)
+	e1, e2 := splitMarker(end)
+	return fmt.Sprintf("console.log(%s + %s); globalThis._tpStatus = 0; try { const _tpResult = (0, eval)(%s); if (_tpResult !== undefined) console.log(_tpResult) } catch (_tpErr) { console.error(_tpErr); globalThis._tpStatus = 1 } console.log(%s + %s + ':' + globalThis._tpStatus)",
+		jsString(b1), jsString(b2), jsString(command), jsString(e1), jsString(e2))
+}
+
+// jsString quotes s as a JSON string, which is also a valid Python and
+// JavaScript string literal.
+func jsString(s string) string {
+	b, _ := json.Marshal(s)
+	return string(b)
+}
+
+// Dialect returns the dialect Run uses for the session.
+func (s *ShellSession) Dialect() Dialect {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.dialect == nil {
+		return Sh
+	}
+	return s.dialect
+}
+
+// SetDialect overrides the dialect detected from the spawn command.
+func (s *ShellSession) SetDialect(d Dialect) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.dialect = d
+}
+
+// WaitForPrompt blocks until the session's output ends in its dialect's
+// prompt, consuming the output up to it like Expect. Shells on plain pipes
+// print their prompt to stderr, which Expect does not read, so this is
+// meant for PTY shells.
+func (s *ShellSession) WaitForPrompt(ctx context.Context) (*ExpectMatch, error) {
+	return s.Expect(ctx, s.Dialect().Prompt())
+}
@@ -0,0 +1,124 @@
+package shell_test
+
+import (
+	"context"
+	"os/exec"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/owen-6936/termplex/assert"
+	"github.com/owen-6936/termplex/shell"
+)
+
+func TestDetectDialect(t *testing.T) {
+	cases := map[string]string{
+		"/bin/bash":     "bash",
+		"zsh":           "zsh",
+		"/usr/bin/fish": "fish",
+		"dash":          "sh",
+		"python3.12":    "python",
+		"nodejs":        "node",
+		"sleep":         "sh",
+	}
+	for program, want := range cases {
+		got := shell.DetectDialect([]string{program, "-i"}).Name()
+		assert.True(t, got == want, "DetectDialect(%q) = %q, want %q", program, got, want)
+	}
+
+	_, err := shell.LookupDialect("powershell")
+	assert.True(t, err != nil, "Expected an error for an unknown dialect")
+	fish, err := shell.LookupDialect("fish")
+	assert.NoError(t, err)
+	assert.Contains(t, fish.WrapCommand("false", "begin:1", "end:1"), "$status")
+}
+
+func TestRunUsesDialect(t *testing.T) {
+	cases := []struct {
+		command  []string
+		ok, fail string
+		want     string
+	}{
+		{[]string{"dash", "-i"}, "echo hello", "false", "hello"},
+		{[]string{"python3", "-i", "-q"}, "print('hello')", "1/0", "hello"},
+		{[]string{"node", "-i"}, "console.log('hello')", "throw new Error('boom')", "hello"},
+	}
+	for _, tc := range cases {
+		if _, err := exec.LookPath(tc.command[0]); err != nil {
+			t.Logf("skipping %s: not installed", tc.command[0])
+			continue
+		}
+		t.Run(tc.command[0], func(t *testing.T) {
+			sm := shell.NewShellManager(nil)
+			t.Cleanup(func() { _ = sm.TerminateAllShells() })
+			session, err := sm.SpawnShell(false, tc.command...)
+			assert.NoError(t, err)
+
+			ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+			defer cancel()
+
+			// 1. A successful command returns its output and status 0.
+			result, err := session.Run(ctx, tc.ok)
+			assert.NoError(t, err)
+			assert.True(t, strings.TrimSpace(result.Output) == tc.want, "Unexpected output %q", result.Output)
+			assert.True(t, result.ExitCode == 0, "Expected status 0, got %d", result.ExitCode)
+
+			// 2. A failure is reported through the exit status.
+			result, err = session.Run(ctx, tc.fail)
+			assert.NoError(t, err)
+			assert.True(t, result.ExitCode != 0, "Expected a non-zero status for %q", tc.fail)
+		})
+	}
+}
+
+func TestPythonDialectKeepsStateAndEchoesValues(t *testing.T) {
+	if _, err := exec.LookPath("python3"); err != nil {
+		t.Skip("python3 is not installed")
+	}
+	sm := shell.NewShellManager(nil)
+	t.Cleanup(func() { _ = sm.TerminateAllShells() })
+
+	// An explicit dialect overrides detection.
+	session, err := sm.SpawnShellWithOptions(shell.SpawnOptions{Command: []string{"python3", "-i", "-q"}, Dialect: shell.Python})
+	assert.NoError(t, err)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	// 1. Multi-line code runs in the REPL's globals.
+	_, err = session.Run(ctx, "def double(x):\n    return 2 * x\nanswer = double(21)")
+	assert.NoError(t, err)
+
+	// 2. Expressions print their value as they would at the prompt.
+	result, err := session.Run(ctx, "answer")
+	assert.NoError(t, err)
+	assert.True(t, strings.TrimSpace(result.Output) == "42", "Unexpected output %q", result.Output)
+
+	// 3. sys.exit reports its code without ending the REPL.
+	result, err = session.Run(ctx, "import sys; sys.exit(3)")
+	assert.NoError(t, err)
+	assert.True(t, result.ExitCode == 3, "Expected status 3, got %d", result.ExitCode)
+}
+
+func TestWaitForPromptOnPTY(t *testing.T) {
+	sm := shell.NewShellManager(nil)
+	t.Cleanup(func() { _ = sm.TerminateAllShells() })
+
+	session, err := sm.SpawnShellWithOptions(shell.SpawnOptions{
+		Interactive: true,
+		Command:     []string{"bash", "--norc", "--noprofile"},
+		Env:         map[string]string{"PS1": "$ "},
+	})
+	assert.NoError(t, err)
+	assert.True(t, session.Dialect() == shell.Bash, "Expected bash to be detected, got %s", session.Dialect().Name())
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	_, err = session.WaitForPrompt(ctx)
+	assert.NoError(t, err)
+
+	// The prompt returns once a command has finished.
+	assert.NoError(t, session.SendCommand("sleep 0.2; echo done"))
+	match, err := session.WaitForPrompt(ctx)
+	assert.NoError(t, err)
+	assert.Contains(t, match.Before, "done")
+}
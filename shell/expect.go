@@ -0,0 +1,98 @@
+package shell
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+)
+
+// Expect blocks until the shell's output matches the pattern, the process
+// exits, or the context is done. Matching starts at the session's read cursor
+// and the cursor advances past the match, so later expectations never
+// re-match output that has already been consumed. If the shell printed more
+// than the scrollback retains since the cursor, matching resumes at the oldest
+// retained output and the match is marked Truncated.
+func (s *ShellSession) Expect(ctx context.Context, pattern *regexp.Regexp) (*ExpectMatch, error) {
+	return s.ExpectAny(ctx, pattern)
+}
+
+// ExpectAny is like Expect but waits for whichever pattern matches first in
+// the output. When several match, the earliest match wins, with ties going to
+// the pattern listed first. The returned Index identifies the winning pattern.
+func (s *ShellSession) ExpectAny(ctx context.Context, patterns ...*regexp.Regexp) (*ExpectMatch, error) {
+	if len(patterns) == 0 {
+		return nil, errors.New("ExpectAny requires at least one pattern")
+	}
+	s.expectMu.Lock()
+	defer s.expectMu.Unlock()
+	closed := s.outputDone()
+
+	for final := false; ; {
+		s.mu.Lock()
+		pending, start := s.primaryBufLocked().Since(s.cursor)
+		match := firstMatch(pending, patterns)
+		if match != nil {
+			match.Truncated = start > s.cursor
+			s.cursor = start + int64(match.end)
+		}
+		changed := s.outputChangedLocked()
+		s.mu.Unlock()
+
+		if match != nil {
+			return match.ExpectMatch, nil
+		}
+		if final {
+			return nil, fmt.Errorf("session %s closed its output before it matched: %w", s.ID, io.EOF)
+		}
+
+		select {
+		case <-changed:
+		case <-closed:
+			// The readers have drained everything; take one last look.
+			final = true
+		case <-ctx.Done():
+			return nil, fmt.Errorf("waiting for output in session %s: %w", s.ID, ctx.Err())
+		}
+	}
+}
+
+// pendingMatch pairs a match with its end offset in the searched output.
+type pendingMatch struct {
+	*ExpectMatch
+	end int
+}
+
+// firstMatch returns the earliest match of any pattern in output, or nil.
+func firstMatch(output []byte, patterns []*regexp.Regexp) *pendingMatch {
+	var best []int
+	bestIndex := -1
+	for i, re := range patterns {
+		loc := re.FindSubmatchIndex(output)
+		if loc != nil && (best == nil || loc[0] < best[0]) {
+			best, bestIndex = loc, i
+		}
+	}
+	if best == nil {
+		return nil
+	}
+
+	groups := make([]string, 0, len(best)/2-1)
+	for g := 2; g < len(best); g += 2 {
+		if best[g] < 0 {
+			groups = append(groups, "") // The group did not participate in the match.
+			continue
+		}
+		groups = append(groups, string(output[best[g]:best[g+1]]))
+	}
+	return &pendingMatch{
+		ExpectMatch: &ExpectMatch{
+			Index:  bestIndex,
+			Text:   string(output[best[0]:best[1]]),
+			Groups: groups,
+			Before: string(output[:best[0]]),
+		},
+		end: best[1],
+	}
+}
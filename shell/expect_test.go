@@ -0,0 +1,52 @@
+package shell_test
+
+import (
+	"context"
+	"errors"
+	"io"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/owen-6936/termplex/assert"
+)
+
+func TestExpectDrivesInteractivePrompts(t *testing.T) {
+	// A small installer-style program that asks two questions.
+	script := `printf 'Enter name: '; read n; echo "Hello, $n (id=42)"; printf 'Continue? [y/n] '; read a; echo "answer=$a"`
+	session := newTestShell(t, "bash", "-c", script)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	// 1. Wait for the first prompt, then answer it.
+	_, err := session.Expect(ctx, regexp.MustCompile(`Enter name: $`))
+	assert.NoError(t, err)
+	assert.NoError(t, session.SendCommand("termplex"))
+
+	// 2. Capture groups are returned with the match.
+	match, err := session.Expect(ctx, regexp.MustCompile(`Hello, (\w+) \(id=(\d+)\)`))
+	assert.NoError(t, err)
+	assert.True(t, len(match.Groups) == 2 && match.Groups[0] == "termplex" && match.Groups[1] == "42", "Unexpected groups: %q", match.Groups)
+
+	// 3. ExpectAny reports which pattern won.
+	match, err = session.ExpectAny(ctx, regexp.MustCompile(`Abort\?`), regexp.MustCompile(`Continue\? \[y/n\] `))
+	assert.NoError(t, err)
+	assert.True(t, match.Index == 1, "Expected the second pattern to match, got index %d", match.Index)
+	assert.NoError(t, session.SendCommand("y"))
+
+	// 4. Consumed output is never matched again: "Hello" is behind the cursor.
+	match, err = session.ExpectAny(ctx, regexp.MustCompile(`Hello`), regexp.MustCompile(`answer=(\w)`))
+	assert.NoError(t, err)
+	assert.True(t, match.Index == 1 && match.Groups[0] == "y", "Expected to match the answer, got %+v", match)
+}
+
+func TestExpectReportsEOFWhenOutputEnds(t *testing.T) {
+	session := newTestShell(t, "bash", "-c", "echo 'done'")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	_, err := session.Expect(ctx, regexp.MustCompile(`never printed`))
+	assert.True(t, errors.Is(err, io.EOF), "Expected io.EOF once output ended, got %v", err)
+}
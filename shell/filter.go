@@ -0,0 +1,203 @@
+package shell
+
+import (
+	"bytes"
+	"os"
+	"regexp"
+	"time"
+)
+
+// Redacted replaces secrets removed by the redaction filters.
+const Redacted = "[REDACTED]"
+
+// FilterLine is a line of output passing through a filter chain.
+type FilterLine struct {
+	Text     []byte // The line without its line ending.
+	IsStderr bool
+	// Continued is set when Text continues a partial line that was already
+	// delivered, such as input echoed after a prompt.
+	Continued bool
+}
+
+// Filter rewrites a line of output before it reaches the shell's buffers,
+// subscribers and echo writer. Returning false drops the line. Filters run
+// on the shell's reader goroutines and may be shared between shells, so they
+// must be safe for concurrent use.
+type Filter func(line *FilterLine) bool
+
+// StripANSIFilter removes ANSI escape sequences, such as colors.
+func StripANSIFilter() Filter {
+	return func(line *FilterLine) bool {
+		line.Text = StripANSI(line.Text)
+		return true
+	}
+}
+
+// RedactFilter replaces every match of the patterns with Redacted.
+func RedactFilter(patterns ...*regexp.Regexp) Filter {
+	return func(line *FilterLine) bool {
+		for _, re := range patterns {
+			line.Text = re.ReplaceAllLiteral(line.Text, []byte(Redacted))
+		}
+		return true
+	}
+}
+
+// RedactValuesFilter replaces every occurrence of the given secrets, such as
+// tokens or passwords, with Redacted. Empty values are ignored.
+func RedactValuesFilter(values ...string) Filter {
+	var patterns []*regexp.Regexp
+	for _, v := range values {
+		if v != "" {
+			patterns = append(patterns, regexp.MustCompile(regexp.QuoteMeta(v)))
+		}
+	}
+	return RedactFilter(patterns...)
+}
+
+// RedactEnvFilter redacts the current values of the named environment
+// variables, for example "GITHUB_TOKEN".
+func RedactEnvFilter(names ...string) Filter {
+	values := make([]string, len(names))
+	for i, name := range names {
+		values[i] = os.Getenv(name)
+	}
+	return RedactValuesFilter(values...)
+}
+
+// TimestampFilter prefixes each line with the time it was filtered, in the
+// given layout; an empty layout uses time.RFC3339. Continued lines keep the
+// prefix of the line they belong to.
+func TimestampFilter(layout string) Filter {
+	if layout == "" {
+		layout = time.RFC3339
+	}
+	return func(line *FilterLine) bool {
+		if !line.Continued {
+			line.Text = append([]byte(time.Now().Format(layout)+" "), line.Text...)
+		}
+		return true
+	}
+}
+
+// DropFilter drops every line that matches any of the patterns.
+func DropFilter(patterns ...*regexp.Regexp) Filter {
+	return func(line *FilterLine) bool {
+		for _, re := range patterns {
+			if re.Match(line.Text) {
+				return false
+			}
+		}
+		return true
+	}
+}
+
+// filterStream holds the unterminated tail of one output stream while it
+// waits for its newline.
+type filterStream struct {
+	pending []byte
+	midLine bool // A partial line of this stream has been delivered.
+	timer   *time.Timer
+}
+
+// SetFilters replaces the session's filter chain. Filters apply to output
+// read from now on; no filters passes output through untouched.
+func (s *ShellSession) SetFilters(filters ...Filter) {
+	s.filterMu.Lock()
+	defer s.filterMu.Unlock()
+	s.filters = filters
+}
+
+// filterOutput runs a chunk through the filter chain and delivers the
+// result. With filters set, output is delivered in whole lines; an
+// unterminated tail, such as a prompt, is held back until its newline
+// arrives or DefaultLineFlushTimeout passes.
+func (s *ShellSession) filterOutput(output []byte, isStderr bool) {
+	s.filterMu.Lock()
+	defer s.filterMu.Unlock()
+
+	st := s.streamState(isStderr)
+	if len(s.filters) == 0 && len(st.pending) == 0 {
+		s.deliver(output, isStderr)
+		return
+	}
+
+	data := append(st.pending, output...)
+	cut := bytes.LastIndexByte(data, '\n') + 1
+	st.pending = bytes.Clone(data[cut:])
+	if cut > 0 {
+		s.deliver(s.applyFilters(data[:cut], isStderr, st), isStderr)
+	}
+
+	switch {
+	case len(st.pending) >= maxLineLength:
+		s.flushPendingLocked(isStderr)
+	case len(st.pending) > 0:
+		if st.timer == nil {
+			st.timer = time.AfterFunc(DefaultLineFlushTimeout, func() {
+				s.filterMu.Lock()
+				defer s.filterMu.Unlock()
+				s.flushPendingLocked(isStderr)
+			})
+		} else {
+			st.timer.Reset(DefaultLineFlushTimeout)
+		}
+	case st.timer != nil:
+		st.timer.Stop()
+	}
+}
+
+// flushPendingLocked delivers the complete characters of a held-back partial
+// line. The caller must hold filterMu.
+func (s *ShellSession) flushPendingLocked(isStderr bool) {
+	st := s.streamState(isStderr)
+	n := completePrefix(st.pending)
+	if n == 0 {
+		return
+	}
+	line := FilterLine{Text: st.pending[:n], IsStderr: isStderr, Continued: st.midLine}
+	st.pending = bytes.Clone(st.pending[n:])
+	st.midLine = true
+	if s.runFilters(&line) {
+		s.deliver(line.Text, isStderr)
+	}
+}
+
+// applyFilters filters a block of complete lines and reassembles them with
+// their original line endings.
+func (s *ShellSession) applyFilters(block []byte, isStderr bool, st *filterStream) []byte {
+	var out []byte
+	for len(block) > 0 {
+		i := bytes.IndexByte(block, '\n')
+		text, ending := block[:i], block[i:i+1]
+		if bytes.HasSuffix(text, []byte{'\r'}) {
+			text, ending = text[:len(text)-1], block[i-1:i+1]
+		}
+		block = block[i+1:]
+
+		line := FilterLine{Text: text, IsStderr: isStderr, Continued: st.midLine}
+		st.midLine = false
+		if s.runFilters(&line) {
+			out = append(append(out, line.Text...), ending...)
+		}
+	}
+	return out
+}
+
+// runFilters passes a line through every filter in turn.
+func (s *ShellSession) runFilters(line *FilterLine) bool {
+	for _, f := range s.filters {
+		if !f(line) {
+			return false
+		}
+	}
+	return true
+}
+
+// streamState returns the filter state of the stdout or stderr stream.
+func (s *ShellSession) streamState(isStderr bool) *filterStream {
+	if isStderr {
+		return &s.filterStreams[1]
+	}
+	return &s.filterStreams[0]
+}
@@ -0,0 +1,96 @@
+package shell_test
+
+import (
+	"context"
+	"regexp"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/owen-6936/termplex/assert"
+	"github.com/owen-6936/termplex/fanout"
+	"github.com/owen-6936/termplex/screen"
+	"github.com/owen-6936/termplex/shell"
+)
+
+func TestFiltersApplyToBuffersAndSubscribers(t *testing.T) {
+	t.Setenv("TERMPLEX_TEST_TOKEN", "s3cr3t-value")
+	sm := shell.NewShellManager(nil)
+	t.Cleanup(func() { _ = sm.TerminateAllShells() })
+
+	// 1. Strip colors, redact the token and a password pattern, and drop noise.
+	sm.SetFilters(
+		shell.StripANSIFilter(),
+		shell.RedactEnvFilter("TERMPLEX_TEST_TOKEN"),
+		shell.RedactFilter(regexp.MustCompile(`password=\S+`)),
+		shell.DropFilter(regexp.MustCompile(`^DEBUG`)),
+	)
+	output, _ := sm.Subscribe(fanout.Options{Buffer: 1000})
+	var echoMu sync.Mutex
+	var echo strings.Builder
+	session, err := sm.SpawnShellWithOptions(shell.SpawnOptions{
+		Command: []string{"bash", "-c", `printf '\033[31mred\033[0m\n'; echo "token $TERMPLEX_TEST_TOKEN"; echo 'DEBUG noisy'; echo 'login password=hunter2 ok'`},
+		Echo: writerFunc(func(p []byte) (int, error) {
+			echoMu.Lock()
+			defer echoMu.Unlock()
+			return echo.Write(p)
+		}),
+	})
+	assert.NoError(t, err)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	_, err = session.Expect(ctx, regexp.MustCompile(`login .* ok\n`))
+	assert.NoError(t, err)
+
+	// 2. The buffer holds exactly the filtered lines.
+	want := "red\ntoken [REDACTED]\nlogin [REDACTED] ok\n"
+	got := session.Scrollback().String()
+	assert.True(t, got == want, "Unexpected scrollback %q", got)
+
+	// 3. Subscribers and the echo writer see the same filtered output.
+	var published strings.Builder
+	for published.Len() < len(want) {
+		select {
+		case out := <-output:
+			published.Write(out.Data)
+		case <-ctx.Done():
+			t.Fatalf("Timed out; subscriber got %q", published.String())
+		}
+	}
+	assert.True(t, published.String() == want, "Unexpected subscriber output %q", published.String())
+	echoMu.Lock()
+	defer echoMu.Unlock()
+	assert.True(t, echo.String() == want, "Unexpected echo %q", echo.String())
+}
+
+func TestFiltersHoldPromptsAndKeepScreenRaw(t *testing.T) {
+	sm := shell.NewShellManager(nil)
+	t.Cleanup(func() { _ = sm.TerminateAllShells() })
+
+	session, err := sm.SpawnShellWithOptions(shell.SpawnOptions{
+		Interactive: true,
+		Command:     []string{"bash", "--norc", "--noprofile"},
+		Env:         map[string]string{"PS1": `\[\033[1m\]ready> \[\033[0m\]`},
+		Filters:     []shell.Filter{shell.StripANSIFilter(), shell.TimestampFilter("15:04:05")},
+	})
+	assert.NoError(t, err)
+
+	// 1. The unterminated prompt is flushed after the timeout, stripped and timestamped.
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	_, err = session.Expect(ctx, regexp.MustCompile(`\d\d:\d\d:\d\d ready> `))
+	assert.NoError(t, err)
+
+	// 2. Echoed input continues the prompt's line without a second timestamp;
+	// the command's output gets its own.
+	assert.NoError(t, session.SendCommand("echo hi"))
+	_, err = session.Expect(ctx, regexp.MustCompile(`\Aecho hi\r\n\d\d:\d\d:\d\d \r?hi\r\n`))
+	assert.NoError(t, err)
+
+	// 3. The terminal emulator saw the raw escape sequences: the prompt is bold.
+	snap, err := session.Screen()
+	assert.NoError(t, err)
+	assert.True(t, snap.Cells[0][0].Attrs&screen.AttrBold != 0, "Expected the raw prompt to reach the screen")
+}
@@ -0,0 +1,123 @@
+package shell
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"sort"
+	"time"
+)
+
+// maxHistoryEntries caps a session's history; the oldest entries are
+// discarded first, and the gap shows in their sequence numbers.
+const maxHistoryEntries = 10000
+
+// DefaultSender attributes commands sent without a named sender.
+const DefaultSender = "api"
+
+// HistoryEntry records one command sent to a shell.
+type HistoryEntry struct {
+	ShellID     string     `json:"shellId"`
+	Seq         int        `json:"seq"`     // Position in the shell's history, starting at 1.
+	Command     string     `json:"command"` // The command as given, without Run's markers.
+	Sender      string     `json:"sender"`  // Who sent it, such as "manifest" or DefaultSender.
+	SentAt      time.Time  `json:"sentAt"`
+	CompletedAt *time.Time `json:"completedAt,omitempty"` // Set once Run sees the command finish.
+	ExitCode    *int       `json:"exitCode,omitempty"`    // Set once Run sees the command finish.
+}
+
+// Duration reports how long a completed command took, or zero.
+func (e HistoryEntry) Duration() time.Duration {
+	if e.CompletedAt == nil {
+		return 0
+	}
+	return e.CompletedAt.Sub(e.SentAt)
+}
+
+type senderKey struct{}
+
+// WithSender attributes the commands Run sends with ctx to sender.
+func WithSender(ctx context.Context, sender string) context.Context {
+	return context.WithValue(ctx, senderKey{}, sender)
+}
+
+// senderFrom returns the sender carried by ctx, or DefaultSender.
+func senderFrom(ctx context.Context) string {
+	if sender, ok := ctx.Value(senderKey{}).(string); ok && sender != "" {
+		return sender
+	}
+	return DefaultSender
+}
+
+// SendCommandAs writes a command to the shell's standard input and records
+// it in the history under sender.
+func (s *ShellSession) SendCommandAs(sender, command string) error {
+	if sender == "" {
+		sender = DefaultSender
+	}
+	s.recordCommand(sender, command)
+	return s.writeInput([]byte(command + "\n"))
+}
+
+// recordCommand appends a history entry and returns its sequence number.
+func (s *ShellSession) recordCommand(sender, command string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.historySeq++
+	s.history = append(s.history, HistoryEntry{
+		ShellID: s.ID,
+		Seq:     s.historySeq,
+		Command: command,
+		Sender:  sender,
+		SentAt:  time.Now(),
+	})
+	if len(s.history) > maxHistoryEntries {
+		s.history = append(s.history[:0:0], s.history[len(s.history)-maxHistoryEntries:]...)
+	}
+	return s.historySeq
+}
+
+// completeCommand records the completion of the entry with sequence number seq.
+func (s *ShellSession) completeCommand(seq, exitCode int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i := len(s.history) - 1; i >= 0; i-- {
+		if s.history[i].Seq == seq {
+			now := time.Now()
+			s.history[i].CompletedAt = &now
+			s.history[i].ExitCode = &exitCode
+			return
+		}
+	}
+}
+
+// History returns a copy of the commands sent to the shell, oldest first.
+// Commands sent with SendCommand have no completion time or exit code; Run
+// fills both in once the command finishes.
+func (s *ShellSession) History() []HistoryEntry {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	history := make([]HistoryEntry, len(s.history))
+	copy(history, s.history)
+	return history
+}
+
+// ExportHistory writes the shell's history to w as a JSON array.
+func (s *ShellSession) ExportHistory(w io.Writer) error {
+	return WriteHistory(w, s.History())
+}
+
+// WriteHistory writes history entries to w as an indented JSON array.
+func WriteHistory(w io.Writer, history []HistoryEntry) error {
+	if history == nil {
+		history = []HistoryEntry{}
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(history)
+}
+
+// SortHistory orders entries from several shells by the time they were sent.
+func SortHistory(history []HistoryEntry) {
+	sort.SliceStable(history, func(i, j int) bool { return history[i].SentAt.Before(history[j].SentAt) })
+}
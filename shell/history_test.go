@@ -0,0 +1,52 @@
+package shell_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/owen-6936/termplex/assert"
+	"github.com/owen-6936/termplex/shell"
+)
+
+func TestHistoryRecordsCommandsAndResults(t *testing.T) {
+	sm := shell.NewShellManager(nil)
+	t.Cleanup(func() { _ = sm.TerminateAllShells() })
+	session, err := sm.SpawnShell(false, "bash")
+	assert.NoError(t, err)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	// 1. Commands sent with Run record their exit code and completion time.
+	_, err = session.Run(shell.WithSender(ctx, "deploy-bot"), "exit_with() { return $1; }; exit_with 3")
+	assert.NoError(t, err)
+	_, err = session.Run(ctx, "echo ok")
+	assert.NoError(t, err)
+
+	// 2. Plain sends are recorded without a result.
+	assert.NoError(t, session.SendCommandAs("operator", "echo typed"))
+
+	history, err := sm.History(session.ID)
+	assert.NoError(t, err)
+	assert.True(t, len(history) == 3, "Expected 3 entries, got %d", len(history))
+
+	first := history[0]
+	assert.True(t, first.Seq == 1 && first.ShellID == session.ID, "Unexpected first entry %+v", first)
+	assert.True(t, first.Command == "exit_with() { return $1; }; exit_with 3", "Expected the unwrapped command, got %q", first.Command)
+	assert.True(t, first.Sender == "deploy-bot", "Expected sender deploy-bot, got %q", first.Sender)
+	assert.True(t, first.ExitCode != nil && *first.ExitCode == 3, "Expected exit code 3")
+	assert.True(t, first.CompletedAt != nil && first.Duration() >= 0, "Expected a completion time")
+	assert.True(t, history[1].Sender == shell.DefaultSender, "Expected the default sender, got %q", history[1].Sender)
+	assert.True(t, history[2].ExitCode == nil && history[2].CompletedAt == nil, "Expected no result for a plain send")
+
+	// 3. The export is a JSON array that round-trips.
+	var buf bytes.Buffer
+	assert.NoError(t, sm.ExportHistory(session.ID, &buf))
+	var exported []shell.HistoryEntry
+	assert.NoError(t, json.Unmarshal(buf.Bytes(), &exported))
+	assert.True(t, len(exported) == 3 && exported[2].Command == "echo typed", "Unexpected export %s", buf.String())
+	assert.Contains(t, buf.String(), `"exitCode": 3`)
+}
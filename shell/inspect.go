@@ -0,0 +1,176 @@
+package shell
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ProcessInfo is a point-in-time view of one process, read from /proc.
+type ProcessInfo struct {
+	PID        int
+	PPID       int
+	Command    string   // The short command name, as in ps.
+	Args       []string // The full command line; empty for kernel threads or when unreadable.
+	State      string   // The kernel's state letter: R running, S sleeping, D disk wait, T stopped, Z zombie.
+	Cwd        string   // Working directory; empty when not readable.
+	UserTime   time.Duration
+	SystemTime time.Duration
+	RSS        int64 // Resident memory in bytes.
+	OpenFiles  int   // Open file descriptors; -1 when not readable.
+	Threads    int
+	StartedAt  time.Time
+	Children   []ProcessInfo // Processes started by this one, ordered by PID.
+}
+
+// CPUTime returns the process's combined user and system CPU time.
+func (p ProcessInfo) CPUTime() time.Duration {
+	return p.UserTime + p.SystemTime
+}
+
+// Walk calls fn for the process and every descendant, parents first.
+func (p ProcessInfo) Walk(fn func(ProcessInfo)) {
+	fn(p)
+	for _, c := range p.Children {
+		c.Walk(fn)
+	}
+}
+
+// Totals sums CPU time, resident memory and open files over the process and
+// its descendants, and counts the processes.
+func (p ProcessInfo) Totals() (cpu time.Duration, rss int64, openFiles, processes int) {
+	p.Walk(func(q ProcessInfo) {
+		cpu += q.CPUTime()
+		rss += q.RSS
+		if q.OpenFiles > 0 {
+			openFiles += q.OpenFiles
+		}
+		processes++
+	})
+	return cpu, rss, openFiles, processes
+}
+
+// Inspect reads the shell's process tree from /proc: the shell itself and
+// every process it started, including background jobs that were reparented
+// but still belong to its process group or session.
+func (s *ShellSession) Inspect() (*ProcessInfo, error) {
+	proc, _ := s.process()
+	if proc == nil {
+		return nil, fmt.Errorf("session %s has no process", s.ID)
+	}
+	if _, exited := s.ExitStatus(); exited {
+		return nil, fmt.Errorf("failed to inspect session %s: %w", s.ID, os.ErrProcessDone)
+	}
+
+	pid := proc.Pid
+	root, err := readProcEntry(pid)
+	if err != nil {
+		return nil, fmt.Errorf("failed to inspect session %s: %w", s.ID, err)
+	}
+	tree, _, err := processTree(root, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to inspect session %s: %w", s.ID, err)
+	}
+
+	// Attach every process to its parent, or to the shell if the parent is
+	// not part of the tree (an orphan that was reparented).
+	inTree := map[int]bool{pid: true}
+	for _, p := range tree {
+		inTree[p.PID] = true
+	}
+	children := make(map[int][]procEntry)
+	for _, p := range tree {
+		parent := p.PPID
+		if !inTree[parent] {
+			parent = pid
+		}
+		children[parent] = append(children[parent], p)
+	}
+
+	var build func(e procEntry) ProcessInfo
+	build = func(e procEntry) ProcessInfo {
+		info := describeProcess(e)
+		for _, c := range children[e.PID] {
+			info.Children = append(info.Children, build(c))
+		}
+		sort.Slice(info.Children, func(i, j int) bool { return info.Children[i].PID < info.Children[j].PID })
+		return info
+	}
+	info := build(root)
+	return &info, nil
+}
+
+// clockTicks is the kernel's USER_HZ, the unit of CPU and start times in
+// /proc. It is 100 on every Linux architecture Go supports.
+const clockTicks = 100
+
+// describeProcess fills in a ProcessInfo from a stat entry and the rest of
+// /proc/<pid>. Fields the caller is not allowed to read are left empty.
+func describeProcess(e procEntry) ProcessInfo {
+	dir := "/proc/" + strconv.Itoa(e.PID)
+	info := ProcessInfo{
+		PID:        e.PID,
+		PPID:       e.PPID,
+		Command:    e.Command,
+		State:      string(e.State),
+		UserTime:   ticksToDuration(e.UTime),
+		SystemTime: ticksToDuration(e.STime),
+		RSS:        e.RSS * int64(os.Getpagesize()),
+		OpenFiles:  -1,
+		Threads:    e.Threads,
+	}
+	if boot, err := bootTime(); err == nil {
+		info.StartedAt = boot.Add(ticksToDuration(e.Start))
+	}
+	if cmdline, err := os.ReadFile(dir + "/cmdline"); err == nil && len(cmdline) > 0 {
+		info.Args = strings.Split(string(bytes.TrimRight(cmdline, "\x00")), "\x00")
+	}
+	if cwd, err := os.Readlink(dir + "/cwd"); err == nil {
+		info.Cwd = cwd
+	}
+	if fds, err := os.ReadDir(dir + "/fd"); err == nil {
+		info.OpenFiles = len(fds)
+	}
+	return info
+}
+
+// ticksToDuration converts clock ticks to a duration.
+func ticksToDuration(ticks uint64) time.Duration {
+	return time.Duration(ticks) * time.Second / clockTicks
+}
+
+var (
+	bootOnce sync.Once
+	boot     time.Time
+	bootErr  error
+)
+
+// bootTime reads the system boot time from /proc/stat once.
+func bootTime() (time.Time, error) {
+	bootOnce.Do(func() {
+		data, err := os.ReadFile("/proc/stat")
+		if err != nil {
+			bootErr = err
+			return
+		}
+		for line := range strings.Lines(string(data)) {
+			if rest, ok := strings.CutPrefix(line, "btime "); ok {
+				secs, err := strconv.ParseInt(strings.TrimSpace(rest), 10, 64)
+				if err != nil {
+					bootErr = err
+					return
+				}
+				boot = time.Unix(secs, 0)
+				return
+			}
+		}
+		bootErr = errors.New("no btime in /proc/stat")
+	})
+	return boot, bootErr
+}
@@ -0,0 +1,57 @@
+package shell_test
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/owen-6936/termplex/assert"
+	"github.com/owen-6936/termplex/shell"
+)
+
+func TestInspectReportsProcessTree(t *testing.T) {
+	sm := shell.NewShellManager(nil)
+	t.Cleanup(func() { _ = sm.TerminateAllShells() })
+
+	dir, err := filepath.EvalSymlinks(t.TempDir())
+	assert.NoError(t, err)
+	session, err := sm.SpawnShellWithOptions(shell.SpawnOptions{Command: []string{"bash"}, Dir: dir})
+	assert.NoError(t, err)
+
+	// 1. Start a busy background job and give it time to accumulate CPU.
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	result, err := session.Run(ctx, "sh -c 'while :; do :; done' & echo busy:$!")
+	assert.NoError(t, err)
+	busy := capturePID(t, result.Output, "busy")
+	time.Sleep(300 * time.Millisecond)
+
+	// 2. The root is the shell itself, with the job as its child.
+	info, err := session.Inspect()
+	assert.NoError(t, err)
+	assert.True(t, info.PID == session.Cmd.Process.Pid, "Expected the shell at the root, got PID %d", info.PID)
+	assert.True(t, info.Command == "bash" && info.Cwd == dir, "Unexpected root %s in %q", info.Command, info.Cwd)
+	assert.True(t, info.OpenFiles >= 3 && info.RSS > 0, "Expected open files and memory, got %d files, %d bytes", info.OpenFiles, info.RSS)
+	assert.True(t, time.Since(info.StartedAt) < time.Minute, "Unexpected start time %v", info.StartedAt)
+
+	var job *shell.ProcessInfo
+	info.Walk(func(p shell.ProcessInfo) {
+		if p.PID == busy {
+			job = &p
+		}
+	})
+	assert.True(t, job != nil, "Busy job %d not found in tree %+v", busy, info)
+	assert.True(t, len(job.Args) == 3 && job.Args[0] == "sh", "Unexpected command line %q", job.Args)
+	assert.True(t, job.CPUTime() > 0, "Expected the busy job to have used CPU")
+
+	cpu, _, _, processes := info.Totals()
+	assert.True(t, processes >= 2 && cpu >= job.CPUTime(), "Unexpected totals: %d processes, %v CPU", processes, cpu)
+
+	// 3. An exited shell can no longer be inspected.
+	assert.NoError(t, sm.TerminateShell(session.ID))
+	_, err = session.Inspect()
+	assert.True(t, errors.Is(err, os.ErrProcessDone), "Expected ErrProcessDone, got %v", err)
+}
@@ -0,0 +1,190 @@
+package shell
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Key modifiers, as encoded in xterm's "CSI 1 ; m" sequences (m = 1 + mods).
+const (
+	modShift = 1 << iota
+	modMeta
+	modCtrl
+)
+
+// specialKey describes how a named key is sent. Keys with a final byte are
+// cursor-style keys (ESC [ A, or ESC O A in application mode); keys with a
+// number are tilde keys (ESC [ 5 ~).
+type specialKey struct {
+	final  byte
+	number int
+	ss3    bool   // Sent as ESC O <final> even outside application mode, like F1 to F4.
+	plain  string // Bytes for keys that are not escape sequences, such as Tab.
+}
+
+// specialKeys maps tmux key names, lower-cased, to their encodings.
+var specialKeys = map[string]specialKey{
+	"up":       {final: 'A'},
+	"down":     {final: 'B'},
+	"right":    {final: 'C'},
+	"left":     {final: 'D'},
+	"home":     {final: 'H'},
+	"end":      {final: 'F'},
+	"ic":       {number: 2},
+	"insert":   {number: 2},
+	"dc":       {number: 3},
+	"delete":   {number: 3},
+	"ppage":    {number: 5},
+	"pageup":   {number: 5},
+	"pgup":     {number: 5},
+	"npage":    {number: 6},
+	"pagedown": {number: 6},
+	"pgdn":     {number: 6},
+	"f1":       {final: 'P', ss3: true},
+	"f2":       {final: 'Q', ss3: true},
+	"f3":       {final: 'R', ss3: true},
+	"f4":       {final: 'S', ss3: true},
+	"f5":       {number: 15},
+	"f6":       {number: 17},
+	"f7":       {number: 18},
+	"f8":       {number: 19},
+	"f9":       {number: 20},
+	"f10":      {number: 21},
+	"f11":      {number: 23},
+	"f12":      {number: 24},
+	"enter":    {plain: "\r"},
+	"tab":      {plain: "\t"},
+	"btab":     {plain: "\x1b[Z"},
+	"escape":   {plain: "\x1b"},
+	"space":    {plain: " "},
+	"bspace":   {plain: "\x7f"},
+}
+
+// EncodeKeys turns tmux-style key names into the bytes a terminal sends for
+// them. Each argument is either a key, optionally with C-, M- and S-
+// modifiers ("C-c", "M-x", "S-Up", "F5", "Enter"), or, if it is not a key
+// name, a literal string sent as is. appCursor selects application cursor
+// key mode (DECCKM), in which arrow, Home and End keys use ESC O sequences.
+func EncodeKeys(appCursor bool, keys ...string) ([]byte, error) {
+	var out []byte
+	for _, key := range keys {
+		b, ok, err := encodeKey(key, appCursor)
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			b = []byte(key)
+		}
+		out = append(out, b...)
+	}
+	return out, nil
+}
+
+// encodeKey encodes a single key name. It reports false if key is not a key
+// name and should be sent literally.
+func encodeKey(key string, appCursor bool) ([]byte, bool, error) {
+	mods := 0
+	name := key
+	for len(name) > 2 && name[1] == '-' {
+		switch name[0] {
+		case 'C', 'c':
+			mods |= modCtrl
+		case 'M', 'm':
+			mods |= modMeta
+		case 'S', 's':
+			mods |= modShift
+		default:
+			return nil, false, nil
+		}
+		name = name[2:]
+	}
+
+	if k, ok := specialKeys[strings.ToLower(name)]; ok {
+		return k.encode(mods, appCursor), true, nil
+	}
+	if len(name) != 1 {
+		return nil, false, nil
+	}
+	if mods == 0 {
+		return []byte(name), true, nil
+	}
+
+	c := name[0]
+	if mods&modShift != 0 && c >= 'a' && c <= 'z' {
+		c -= 'a' - 'A'
+	}
+	if mods&modCtrl != 0 {
+		if c == ' ' {
+			c = 0
+		} else {
+			ctrl, err := controlByte(c)
+			if err != nil {
+				return nil, false, fmt.Errorf("invalid key %q: %w", key, err)
+			}
+			c = ctrl
+		}
+	}
+	if mods&modMeta != 0 {
+		return []byte{0x1b, c}, true, nil
+	}
+	return []byte{c}, true, nil
+}
+
+// encode returns the bytes for a named key with the given modifiers.
+func (k specialKey) encode(mods int, appCursor bool) []byte {
+	if k.plain != "" {
+		b := []byte(k.plain)
+		switch {
+		case k.plain == "\t" && mods&modShift != 0:
+			b = []byte("\x1b[Z")
+		case k.plain == " " && mods&modCtrl != 0:
+			b = []byte{0}
+		}
+		if mods&modMeta != 0 {
+			b = append([]byte{0x1b}, b...)
+		}
+		return b
+	}
+
+	param := strconv.Itoa(1 + mods)
+	switch {
+	case k.number != 0 && mods != 0:
+		return []byte("\x1b[" + strconv.Itoa(k.number) + ";" + param + "~")
+	case k.number != 0:
+		return []byte("\x1b[" + strconv.Itoa(k.number) + "~")
+	case mods != 0:
+		return []byte("\x1b[1;" + param + string(k.final))
+	case k.ss3 || appCursor:
+		return []byte{0x1b, 'O', k.final}
+	default:
+		return []byte{0x1b, '[', k.final}
+	}
+}
+
+// SendKeys types keys into the shell, like tmux's send-keys: each argument
+// is a key name such as "C-c", "Up", "Tab", "Escape", "F5" or "M-x", or a
+// literal string. Arrow keys follow the application cursor mode of a PTY
+// shell's screen. No newline is added; send "Enter" to submit a line. On
+// pipe-based shells, C-c, C-z, C-\ and C-d behave as with SendControl.
+func (s *ShellSession) SendKeys(keys ...string) error {
+	appCursor := s.screen != nil && s.screen.AppCursorKeys()
+	for _, key := range keys {
+		b, ok, err := encodeKey(key, appCursor)
+		if err != nil {
+			return fmt.Errorf("session %s: %w", s.ID, err)
+		}
+		if !ok {
+			b = []byte(key)
+		}
+		if s.pty == nil && ok && len(b) == 1 && isSignalControl(b[0]) {
+			err = s.sendControlByte(b[0])
+		} else {
+			err = s.writeInput(b)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
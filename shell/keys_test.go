@@ -0,0 +1,79 @@
+package shell_test
+
+import (
+	"context"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/owen-6936/termplex/assert"
+	"github.com/owen-6936/termplex/shell"
+)
+
+func TestEncodeKeys(t *testing.T) {
+	cases := []struct {
+		appCursor bool
+		keys      []string
+		want      string
+	}{
+		{false, []string{"C-c"}, "\x03"},
+		{false, []string{"C-m"}, "\r"},
+		{false, []string{"ls", "Enter"}, "ls\r"},
+		{false, []string{"Up", "Left"}, "\x1b[A\x1b[D"},
+		{true, []string{"Up", "Home"}, "\x1bOA\x1bOH"},
+		{false, []string{"Tab", "BTab", "Escape", "BSpace"}, "\t\x1b[Z\x1b\x7f"},
+		{false, []string{"F1", "F5", "F12"}, "\x1bOP\x1b[15~\x1b[24~"},
+		{false, []string{"M-x", "C-M-a", "S-a"}, "\x1bx\x1b\x01A"},
+		{true, []string{"C-Up", "S-PageUp"}, "\x1b[1;5A\x1b[5;2~"},
+		{false, []string{"C-Space", "pagedown"}, "\x00\x1b[6~"},
+		{false, []string{"Upward", "C-"}, "UpwardC-"},
+	}
+	for _, tc := range cases {
+		got, err := shell.EncodeKeys(tc.appCursor, tc.keys...)
+		assert.NoError(t, err)
+		assert.True(t, string(got) == tc.want, "EncodeKeys(%v, %q) = %q, want %q", tc.appCursor, tc.keys, got, tc.want)
+	}
+
+	_, err := shell.EncodeKeys(false, "C-1")
+	assert.True(t, err != nil, "Expected an error for a key with no control character")
+}
+
+func TestSendKeysOnPTY(t *testing.T) {
+	sm := shell.NewShellManager(nil)
+	t.Cleanup(func() { _ = sm.TerminateAllShells() })
+
+	session, err := sm.SpawnShell(true, "bash", "--norc", "-i")
+	assert.NoError(t, err)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	// 1. Readline moves the cursor on Left, so X is inserted mid-word.
+	assert.NoError(t, sm.SendKeys(session.ID, "echo ab", "Left", "X", "Enter"))
+	_, err = session.Expect(ctx, regexp.MustCompile(`aXb\r\n`))
+	assert.NoError(t, err)
+
+	// 2. Once a program enables application cursor mode, Up is sent as ESC O A.
+	assert.NoError(t, session.SendKeys(`printf '\e[?1h'; cat -v`, "Enter"))
+	_, err = session.Expect(ctx, regexp.MustCompile(`cat -v\r\n`))
+	assert.NoError(t, err)
+	_, err = session.Expect(ctx, regexp.MustCompile(`\x1b\[\?1h`))
+	assert.NoError(t, err)
+	// Keys typed before cat blocks on the terminal may reach the shell instead.
+	assert.True(t, waitFor(5*time.Second, func() bool {
+		info, err := session.Inspect()
+		return err == nil && len(info.Children) == 1 && info.Children[0].Command == "cat" && info.Children[0].State == "S"
+	}), "Expected cat to be waiting for input")
+	assert.NoError(t, session.SendKeys("Up", "Enter"))
+	_, err = session.Expect(ctx, regexp.MustCompile(`\^\[OA\r\n\^\[OA\r\n`))
+	assert.NoError(t, err)
+
+	// 3. C-c stops cat through the terminal's line discipline. Waiting for
+	// the prompt keeps the next command from being read by a dying cat.
+	assert.NoError(t, session.SendKeys("C-c"))
+	_, err = session.WaitForPrompt(ctx)
+	assert.NoError(t, err)
+	result, err := session.Run(ctx, "echo back")
+	assert.NoError(t, err)
+	assert.Contains(t, result.Output, "back")
+}
@@ -0,0 +1,181 @@
+package shell
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"syscall"
+	"time"
+	"unsafe"
+
+	"github.com/owen-6936/termplex/cgroup"
+)
+
+// ResourceLimits are setrlimit limits applied to a shell before its program
+// starts, and inherited by everything it runs. Zero leaves a limit unchanged.
+// Each limit is set as both the soft and the hard limit, so the shell cannot
+// raise it again.
+type ResourceLimits struct {
+	CPUSeconds   uint64 // RLIMIT_CPU: CPU time per process, after which it is killed with SIGXCPU.
+	AddressSpace uint64 // RLIMIT_AS: bytes of virtual memory per process.
+	OpenFiles    uint64 // RLIMIT_NOFILE: open file descriptors per process.
+	// RLIMIT_NPROC: processes of the shell's user, counted across the whole
+	// system. The kernel does not enforce it for root.
+	Processes uint64
+}
+
+// rlimits pairs each non-zero limit with its resource.
+func (l ResourceLimits) rlimits() map[int]uint64 {
+	limits := make(map[int]uint64)
+	for resource, value := range map[int]uint64{
+		syscall.RLIMIT_CPU:    l.CPUSeconds,
+		syscall.RLIMIT_AS:     l.AddressSpace,
+		syscall.RLIMIT_NOFILE: l.OpenFiles,
+		rlimitNproc:           l.Processes,
+	} {
+		if value > 0 {
+			limits[resource] = value
+		}
+	}
+	return limits
+}
+
+// rlimitNproc is RLIMIT_NPROC, which the syscall package does not define.
+const rlimitNproc = 6
+
+// apply sets the limits on a running process.
+func (l ResourceLimits) apply(pid int) error {
+	for resource, value := range l.rlimits() {
+		limit := syscall.Rlimit{Cur: value, Max: value}
+		if _, _, errno := syscall.RawSyscall6(syscall.SYS_PRLIMIT64, uintptr(pid), uintptr(resource), uintptr(unsafe.Pointer(&limit)), 0, 0, 0); errno != 0 {
+			return fmt.Errorf("failed to set resource limit %d: %w", resource, errno)
+		}
+	}
+	return nil
+}
+
+// startConfined starts cmd with start, applying limits and moving it into
+// group before its program runs. Without either, start is called directly.
+func startConfined(cmd *exec.Cmd, limits *ResourceLimits, group *cgroup.Group, start func() error) error {
+	if limits == nil && group == nil {
+		return start()
+	}
+	gate, err := newExecGate(cmd)
+	if err != nil {
+		return err
+	}
+	startErr := start()
+	err = gate.open(cmd, func(pid int) error {
+		if limits != nil {
+			if err := limits.apply(pid); err != nil {
+				return err
+			}
+		}
+		if group != nil {
+			return group.Add(pid)
+		}
+		return nil
+	})
+	if startErr != nil {
+		return startErr
+	}
+	return err
+}
+
+// execGate holds a command in a small shell wrapper until it is opened, so the
+// process can be set up from outside before it execs its real program. The
+// program keeps the process ID, and with it the limits and cgroup. The wrapper
+// is bash where installed, whose exec -a keeps the program's own argv[0];
+// plain sh can only pass the resolved path as argv[0].
+type execGate struct {
+	r, w *os.File
+	path string
+	args []string
+}
+
+// newExecGate rewrites cmd to wait on the gate.
+func newExecGate(cmd *exec.Cmd) (*execGate, error) {
+	r, w, err := os.Pipe()
+	if err != nil {
+		return nil, fmt.Errorf("failed to create exec gate: %w", err)
+	}
+	g := &execGate{r: r, w: w, path: cmd.Path, args: cmd.Args}
+
+	fd := 3 + len(cmd.ExtraFiles)
+	cmd.ExtraFiles = append(cmd.ExtraFiles, r)
+	name, run := "sh", `exec "$@"`
+	cmd.Path = "/bin/sh"
+	if bash, err := exec.LookPath("bash"); err == nil {
+		name, run = "bash", `exec -a "$0" "$@"`
+		cmd.Path = bash
+	}
+	script := fmt.Sprintf(`read -r _ <&%d || exit 126; exec %d<&-; %s`, fd, fd, run)
+	cmd.Args = append([]string{name, "-c", script, g.args[0], g.path}, g.args[1:]...)
+	return g, nil
+}
+
+// open restores cmd's own command line and, if it started, runs setup on the
+// waiting process and lets it exec. A process that setup fails on is killed.
+func (g *execGate) open(cmd *exec.Cmd, setup func(pid int) error) error {
+	cmd.Path, cmd.Args = g.path, g.args
+	cmd.ExtraFiles = cmd.ExtraFiles[:len(cmd.ExtraFiles)-1]
+	_ = g.r.Close()
+	defer g.w.Close()
+	if cmd.Process == nil {
+		return nil
+	}
+
+	if err := setup(cmd.Process.Pid); err != nil {
+		_ = cmd.Process.Kill()
+		_ = cmd.Wait()
+		return err
+	}
+	if _, err := g.w.Write([]byte("\n")); err != nil {
+		return fmt.Errorf("failed to release process %d: %w", cmd.Process.Pid, err)
+	}
+	return nil
+}
+
+// ResourceLimits returns the limits the shell was started with, or nil.
+func (s *ShellSession) ResourceLimits() *ResourceLimits {
+	return s.limits
+}
+
+// Cgroup returns the cgroup the shell runs in, or nil.
+func (s *ShellSession) Cgroup() *cgroup.Group {
+	return s.cgroup
+}
+
+// CgroupUsage reads the memory, CPU and process usage of the shell's cgroup.
+func (s *ShellSession) CgroupUsage() (*cgroup.Usage, error) {
+	if s.cgroup == nil {
+		return nil, fmt.Errorf("session %s is not in a cgroup", s.ID)
+	}
+	return s.cgroup.Usage()
+}
+
+// cgroupReleaseTimeout bounds how long a shell's own cgroup may stay busy
+// after the shell exits before it is left behind.
+const cgroupReleaseTimeout = 5 * time.Second
+
+// releaseCgroup removes a cgroup created for the shell alone, once its
+// process is gone for good. Processes the shell left behind may take a
+// moment to die, so removal is retried for a while.
+func (s *ShellSession) releaseCgroup() {
+	if !s.ownCgroup {
+		return
+	}
+	deadline := time.Now().Add(cgroupReleaseTimeout)
+	for {
+		err := s.cgroup.Remove()
+		if err == nil {
+			return
+		}
+		if !errors.Is(err, syscall.EBUSY) || time.Now().After(deadline) {
+			s.log().Warn("failed to remove shell cgroup", "error", err)
+			return
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+}
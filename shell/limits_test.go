@@ -0,0 +1,144 @@
+package shell_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/owen-6936/termplex/assert"
+	"github.com/owen-6936/termplex/cgroup"
+	"github.com/owen-6936/termplex/shell"
+)
+
+func TestResourceLimitsApplyBeforeExec(t *testing.T) {
+	sm := shell.NewShellManager(nil)
+	t.Cleanup(func() { _ = sm.TerminateAllShells() })
+
+	// 1. The limits are in place from the program's first instruction.
+	session, err := sm.SpawnShellWithOptions(shell.SpawnOptions{
+		Command: []string{"bash", "-c", "ulimit -t; ulimit -v; ulimit -n"},
+		Limits:  &shell.ResourceLimits{CPUSeconds: 30, AddressSpace: 1 << 30, OpenFiles: 64},
+	})
+	assert.NoError(t, err)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	_, err = session.Wait(ctx)
+	assert.NoError(t, err)
+	assert.True(t, waitFor(time.Second, func() bool { return strings.Count(session.Scrollback().String(), "\n") >= 3 }), "Expected three limits")
+	fields := strings.Fields(session.Scrollback().String())
+	assert.True(t, strings.Join(fields, " ") == "30 1048576 64", "Unexpected limits %q", session.Scrollback().String())
+	assert.True(t, session.Cmd.Args[0] == "bash", "Expected the shell's own command line, got %v", session.Cmd.Args)
+
+	// 2. A CPU-bound command is killed once it uses its CPU time.
+	sm.SetResourceLimits(shell.ResourceLimits{CPUSeconds: 1})
+	spinner, err := sm.SpawnShell(false, "sh", "-c", "while :; do :; done")
+	assert.NoError(t, err)
+	ctx, cancel = context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	status, err := spinner.Wait(ctx)
+	assert.NoError(t, err)
+	assert.True(t, status.Signaled && (status.Signal == syscall.SIGXCPU || status.Signal == syscall.SIGKILL), "Expected the CPU limit to kill the shell, got %+v", status)
+
+	// 3. A restarted shell gets the same limits.
+	supervised, err := sm.SpawnShellWithOptions(shell.SpawnOptions{
+		Command: []string{"bash", "-c", "ulimit -n; sleep 0.1"},
+		Limits:  &shell.ResourceLimits{OpenFiles: 32},
+		Restart: &shell.RestartOptions{Policy: shell.RestartAlways, InitialBackoff: 10 * time.Millisecond},
+	})
+	assert.NoError(t, err)
+	assert.True(t, waitFor(5*time.Second, func() bool {
+		return supervised.Restarts() >= 1 && strings.Count(supervised.Scrollback().String(), "32\n") >= 2
+	}),
+		"Expected the limit after a restart, got %q", supervised.Scrollback().String())
+}
+
+func TestResourceLimitsKeepArgv0(t *testing.T) {
+	sm := shell.NewShellManager(nil)
+	t.Cleanup(func() { _ = sm.TerminateAllShells() })
+
+	session, err := sm.SpawnShellWithOptions(shell.SpawnOptions{
+		Command: []string{"bash", "-c", `tr '\0' ' ' </proc/$$/cmdline; echo`},
+		Limits:  &shell.ResourceLimits{OpenFiles: 64},
+	})
+	assert.NoError(t, err)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	_, err = session.Wait(ctx)
+	assert.NoError(t, err)
+	assert.True(t, waitFor(time.Second, func() bool { return strings.Contains(session.Scrollback().String(), "\n") }), "Expected the command line")
+	output := session.Scrollback().String()
+	assert.True(t, strings.HasPrefix(output, "bash -c "), "Expected argv[0] to stay bash, got %q", output)
+}
+
+func TestShellJoinsCgroup(t *testing.T) {
+	// A plain directory stands in for a cgroup, so the test needs no privileges.
+	dir := t.TempDir()
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "cgroup.procs"), nil, 0o644))
+	group, err := cgroup.Open(dir)
+	assert.NoError(t, err)
+
+	sm := shell.NewShellManager(nil)
+	t.Cleanup(func() { _ = sm.TerminateAllShells() })
+	sm.SetCgroup(group)
+
+	// 1. The shell's PID is written to the group before it runs.
+	session, err := sm.SpawnShell(false, "sleep", "5")
+	assert.NoError(t, err)
+	procs, err := os.ReadFile(filepath.Join(dir, "cgroup.procs"))
+	assert.NoError(t, err)
+	assert.True(t, string(procs) == strconv.Itoa(session.Cmd.Process.Pid), "Expected the shell's PID, got %q", procs)
+	assert.True(t, session.Cgroup() == group, "Expected the manager's cgroup")
+
+	// 2. CgroupLimits create a group of the shell's own below it.
+	own, err := sm.SpawnShellWithOptions(shell.SpawnOptions{Command: []string{"sleep", "5"}, CgroupLimits: &cgroup.Limits{MemoryMax: 64 << 20}})
+	assert.NoError(t, err)
+	assert.True(t, own.Cgroup().Path() == filepath.Join(dir, "shell-"+own.ID), "Unexpected cgroup %s", own.Cgroup().Path())
+	memoryMax, err := os.ReadFile(filepath.Join(own.Cgroup().Path(), "memory.max"))
+	assert.NoError(t, err)
+	assert.True(t, string(memoryMax) == "67108864", "Unexpected memory.max %q", memoryMax)
+
+	// 3. A group that cannot take the process fails the spawn.
+	assert.NoError(t, os.Remove(filepath.Join(dir, "cgroup.procs")))
+	assert.NoError(t, os.Mkdir(filepath.Join(dir, "cgroup.procs"), 0o755))
+	_, err = sm.SpawnShell(false, "sleep", "5")
+	assert.True(t, err != nil, "Expected the spawn to fail")
+}
+
+func TestShellCgroupUsage(t *testing.T) {
+	self, err := cgroup.Self()
+	if err != nil {
+		t.Skipf("cgroup v2 is not available: %v", err)
+	}
+	parent, err := self.NewChild("termplex-test-usage", cgroup.Limits{})
+	if err != nil {
+		t.Skipf("cgroup v2 is not writable: %v", err)
+	}
+	t.Cleanup(func() { _ = parent.Remove() })
+
+	sm := shell.NewShellManager(nil)
+	sm.SetCgroup(parent)
+
+	// 1. A shell in its own group reports the CPU it burns.
+	session, err := sm.SpawnShellWithOptions(shell.SpawnOptions{
+		Command:      []string{"sh", "-c", "i=0; while [ $i -lt 200000 ]; do i=$((i+1)); done; sleep 5"},
+		CgroupLimits: &cgroup.Limits{},
+	})
+	assert.NoError(t, err)
+	assert.True(t, waitFor(5*time.Second, func() bool {
+		usage, err := session.CgroupUsage()
+		return err == nil && usage.CPUUsage > 10*time.Millisecond
+	}), "Expected CPU usage to be recorded")
+
+	// 2. The group is removed once the shell is gone.
+	path := session.Cgroup().Path()
+	assert.NoError(t, sm.TerminateAllShells())
+	assert.True(t, waitFor(2*time.Second, func() bool {
+		_, err := os.Stat(path)
+		return os.IsNotExist(err)
+	}), "Expected the shell's cgroup to be removed")
+}
@@ -0,0 +1,187 @@
+package shell
+
+import (
+	"bytes"
+	"time"
+	"unicode/utf8"
+
+	"github.com/owen-6936/termplex/fanout"
+)
+
+const (
+	// DefaultLineFlushTimeout is how long an unterminated line, such as a
+	// prompt, is held back before it is delivered as a partial line.
+	DefaultLineFlushTimeout = 100 * time.Millisecond
+	// maxLineLength caps how much of a line is buffered before it is
+	// delivered in pieces.
+	maxLineLength = 64 * 1024
+)
+
+// Line is one line of output from a shell, without its line ending.
+type Line struct {
+	ShellID   string
+	Timestamp time.Time // When the first byte of the line arrived.
+	Text      string
+	IsStderr  bool
+	// Partial is set when the line was delivered before its newline arrived,
+	// because it sat unterminated for the flush timeout or grew too long.
+	// The rest of the line follows as a separate Line.
+	Partial bool
+}
+
+// LineOptions configures a line stream.
+type LineOptions struct {
+	fanout.Options
+	ShellID      string        // Only frame output from this shell; empty means every shell.
+	FlushTimeout time.Duration // Zero uses DefaultLineFlushTimeout.
+}
+
+// Lines returns a stream of whole lines from the manager's shells. Partial
+// lines are buffered until their newline arrives or the flush timeout
+// passes, and a multi-byte character is never split between two lines. The
+// channel is closed when the subscription is cancelled or the shells are
+// terminated.
+func (sm *ShellManager) Lines(opts LineOptions) (<-chan Line, *fanout.Subscription) {
+	if opts.FlushTimeout <= 0 {
+		opts.FlushTimeout = DefaultLineFlushTimeout
+	}
+	// The raw subscription blocks; the line subscriber's own policy applies
+	// to the lines instead.
+	raw, rawSub := sm.Subscribe(fanout.Options{Policy: fanout.Block})
+
+	hub := &fanout.Hub[Line]{}
+	lines, sub := hub.Subscribe(opts.Options)
+	go frameLines(raw, rawSub, hub, sub, opts)
+	return lines, sub
+}
+
+// frameLines turns raw output into lines until either subscription ends.
+func frameLines(raw <-chan PaneOutput, rawSub *fanout.Subscription, hub *fanout.Hub[Line], sub *fanout.Subscription, opts LineOptions) {
+	defer hub.Close()
+	defer rawSub.Cancel()
+
+	framer := lineFramer{pending: make(map[lineKey]*pendingLine)}
+	ticker := time.NewTicker(opts.FlushTimeout / 2)
+	defer ticker.Stop()
+
+	publish := func(lines []Line) {
+		for _, l := range lines {
+			hub.Publish(l)
+		}
+	}
+	for {
+		select {
+		case out, ok := <-raw:
+			if !ok {
+				publish(framer.flushAll())
+				return
+			}
+			if opts.ShellID == "" || out.ShellID == opts.ShellID {
+				publish(framer.write(out))
+			}
+		case now := <-ticker.C:
+			publish(framer.flushStale(now, opts.FlushTimeout))
+		case <-sub.Done():
+			return
+		}
+	}
+}
+
+// lineKey identifies one output stream of one shell.
+type lineKey struct {
+	shellID string
+	stderr  bool
+}
+
+// pendingLine is the unterminated tail of a stream.
+type pendingLine struct {
+	data    []byte
+	started time.Time
+}
+
+// lineFramer splits output chunks into lines, per shell and stream.
+type lineFramer struct {
+	pending map[lineKey]*pendingLine
+}
+
+// write adds a chunk and returns the lines it completes.
+func (f *lineFramer) write(out PaneOutput) []Line {
+	key := lineKey{shellID: out.ShellID, stderr: out.IsStderr}
+	p := f.pending[key]
+	if p == nil {
+		p = &pendingLine{}
+		f.pending[key] = p
+	}
+	if len(p.data) == 0 {
+		p.started = out.Timestamp
+	}
+	p.data = append(p.data, out.Data...)
+
+	var lines []Line
+	for {
+		i := bytes.IndexByte(p.data, '\n')
+		if i < 0 {
+			break
+		}
+		text := bytes.TrimSuffix(p.data[:i], []byte{'\r'})
+		lines = append(lines, Line{ShellID: key.shellID, Timestamp: p.started, Text: string(text), IsStderr: key.stderr})
+		p.data = p.data[i+1:]
+		p.started = out.Timestamp
+	}
+	if len(p.data) >= maxLineLength {
+		lines = append(lines, f.partial(key, p)...)
+	}
+	if len(p.data) == 0 {
+		delete(f.pending, key)
+	}
+	return lines
+}
+
+// flushStale delivers lines that have waited at least timeout for a newline.
+func (f *lineFramer) flushStale(now time.Time, timeout time.Duration) []Line {
+	var lines []Line
+	for key, p := range f.pending {
+		if now.Sub(p.started) >= timeout {
+			lines = append(lines, f.partial(key, p)...)
+		}
+	}
+	return lines
+}
+
+// flushAll delivers everything still buffered, once the stream has ended.
+func (f *lineFramer) flushAll() []Line {
+	var lines []Line
+	for key, p := range f.pending {
+		lines = append(lines, Line{ShellID: key.shellID, Timestamp: p.started, Text: string(p.data), IsStderr: key.stderr, Partial: true})
+	}
+	clear(f.pending)
+	return lines
+}
+
+// partial delivers the complete characters of a pending line and keeps an
+// incomplete trailing multi-byte sequence for the next chunk.
+func (f *lineFramer) partial(key lineKey, p *pendingLine) []Line {
+	n := completePrefix(p.data)
+	if n == 0 {
+		return nil
+	}
+	line := Line{ShellID: key.shellID, Timestamp: p.started, Text: string(p.data[:n]), IsStderr: key.stderr, Partial: true}
+	p.data = append([]byte(nil), p.data[n:]...)
+	if len(p.data) == 0 {
+		delete(f.pending, key)
+	}
+	return []Line{line}
+}
+
+// completePrefix returns the length of b without a trailing incomplete UTF-8
+// sequence. Invalid bytes are not held back.
+func completePrefix(b []byte) int {
+	start := len(b) - 1
+	for start >= 0 && len(b)-start < utf8.UTFMax && !utf8.RuneStart(b[start]) {
+		start--
+	}
+	if start < 0 || utf8.FullRune(b[start:]) {
+		return len(b)
+	}
+	return start
+}
@@ -0,0 +1,83 @@
+package shell_test
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/owen-6936/termplex/assert"
+	"github.com/owen-6936/termplex/shell"
+)
+
+// collectLines reads lines until one has the given text or the timeout passes.
+func collectLines(t *testing.T, lines <-chan shell.Line, until string) []shell.Line {
+	t.Helper()
+	var got []shell.Line
+	timeout := time.After(5 * time.Second)
+	for {
+		select {
+		case l, ok := <-lines:
+			if !ok {
+				t.Fatalf("Line stream closed before %q; got %+v", until, got)
+			}
+			got = append(got, l)
+			if l.Text == until {
+				return got
+			}
+		case <-timeout:
+			t.Fatalf("Timed out waiting for %q; got %+v", until, got)
+		}
+	}
+}
+
+func TestLinesReassembleSplitOutput(t *testing.T) {
+	sm := shell.NewShellManager(nil)
+	t.Cleanup(func() { _ = sm.TerminateAllShells() })
+	lines, _ := sm.Lines(shell.LineOptions{})
+
+	// 1. A 2100-byte line of three-byte characters cannot fit one 1024-byte read,
+	// and two short lines arrive in one read.
+	long := strings.Repeat("€", 700)
+	session, err := sm.SpawnShell(false, "bash", "-c", "printf 'first\\nsecond\\n"+long+"\\r\\n'; echo oops >&2; sleep 0.2; echo done")
+	assert.NoError(t, err)
+
+	got := collectLines(t, lines, "done")
+	var texts []string
+	var stderr []string
+	for _, l := range got {
+		assert.True(t, l.ShellID == session.ID, "Unexpected shell ID %s", l.ShellID)
+		assert.True(t, !l.Partial, "Unexpected partial line %+v", l)
+		if l.IsStderr {
+			stderr = append(stderr, l.Text)
+		} else {
+			texts = append(texts, l.Text)
+		}
+	}
+
+	// 2. Lines come out whole, without their line endings, per stream.
+	assert.True(t, strings.Join(texts, "|") == "first|second|"+long+"|done", "Unexpected stdout lines %q", texts)
+	assert.True(t, strings.Join(stderr, "|") == "oops", "Unexpected stderr lines %q", stderr)
+}
+
+func TestLinesFlushPromptsWithoutSplittingCharacters(t *testing.T) {
+	sm := shell.NewShellManager(nil)
+	t.Cleanup(func() { _ = sm.TerminateAllShells() })
+	lines, sub := sm.Lines(shell.LineOptions{FlushTimeout: 50 * time.Millisecond})
+
+	// 1. A prompt stalls halfway through the euro sign.
+	_, err := sm.SpawnShell(false, "bash", "-c", "printf 'price> \\xe2\\x82'; sleep 0.5; printf '\\xac\\n'; echo done")
+	assert.NoError(t, err)
+
+	got := collectLines(t, lines, "done")
+
+	// 2. The complete characters are flushed as a partial line; the split
+	// character waits and arrives whole with the rest of the line.
+	assert.True(t, len(got) == 3, "Expected 3 lines, got %+v", got)
+	assert.True(t, got[0].Partial && got[0].Text == "price> ", "Unexpected prompt %+v", got[0])
+	assert.True(t, !got[1].Partial && got[1].Text == "€", "Unexpected continuation %+v", got[1])
+
+	// 3. Cancelling closes the stream.
+	sub.Cancel()
+	for range lines {
+	}
+}
@@ -2,77 +2,163 @@ package shell
 
 import (
 	"bytes"
+	"context"
 	"errors"
 	"fmt"
 	"io"
+	"log/slog"
+	"os"
 	"os/exec"
+	"sort"
 	"sync"
+	"syscall"
 	"time"
 
 	"github.com/creack/pty"
 	"github.com/google/uuid"
+	"github.com/owen-6936/termplex/cgroup"
+	"github.com/owen-6936/termplex/fanout"
+	"github.com/owen-6936/termplex/screen"
 )
 
 // ShellManager controls shell lifecycle and enforces environment rules.
 type ShellManager struct {
 	mu         sync.Mutex
 	Shells     map[string]*ShellSession
-	OutputChan chan PaneOutput // A multiplexed stream of output from all managed shells.
+	OutputChan <-chan PaneOutput // Legacy single-consumer stream of all output; drops the oldest chunk when full.
+	ExitChan   chan ExitStatus   // Notifications for every managed shell whose process exits; the oldest is dropped when full.
 	closeChan  chan struct{}
+	size       WindowSize        // Initial PTY size for new interactive shells.
+	scrollback *ScrollbackLimits // Output retention for new shells; nil uses the defaults.
+	logger     *slog.Logger      // Structured logger; discards by default.
+	echo       io.Writer         // Console echo for new shells; nil disables it.
+	filters    []Filter          // Output filter chain for new shells.
+	limits     *ResourceLimits   // setrlimit limits for new shells; nil sets none.
+	cgroup     *cgroup.Group     // cgroup for new shells; nil leaves them in termplex's.
+	pool       *ShellPool        // Source of pre-started shells; nil always spawns.
+	output     fanout.Hub[PaneOutput]
 }
 
 // NewShellManager initializes a shell manager with known environments.
 func NewShellManager(supportedEnvs []string) *ShellManager {
-	return &ShellManager{
-		Shells:     make(map[string]*ShellSession),
-		OutputChan: make(chan PaneOutput, 100),
-		closeChan:  make(chan struct{}),
+	sm := &ShellManager{
+		Shells:    make(map[string]*ShellSession),
+		ExitChan:  make(chan ExitStatus, 100),
+		closeChan: make(chan struct{}),
+		logger:    discardLogger,
 	}
+	// Nobody may be reading the legacy channel; it must never stall a shell.
+	sm.OutputChan, _ = sm.output.Subscribe(fanout.Options{Policy: fanout.DropOldest})
+	return sm
+}
+
+// Subscribe returns a new, independent stream of output from every managed
+// shell. The channel is closed when the subscription is cancelled or the
+// shells are terminated.
+func (sm *ShellManager) Subscribe(opts fanout.Options) (<-chan PaneOutput, *fanout.Subscription) {
+	return sm.output.Subscribe(opts)
 }
 
 // SpawnShell creates a new shell session.
 func (sm *ShellManager) SpawnShell(interactive bool, command ...string) (*ShellSession, error) {
-	if len(command) == 0 {
+	return sm.SpawnShellWithOptions(SpawnOptions{Interactive: interactive, Command: command})
+}
+
+// SpawnShellWithOptions creates a new shell session with full control over its
+// working directory, environment and initial PTY size.
+func (sm *ShellManager) SpawnShellWithOptions(opts SpawnOptions) (*ShellSession, error) {
+	if len(opts.Command) == 0 {
 		return nil, errors.New("SpawnShell requires a command to execute")
 	}
+	if s, ok := sm.fromPool(opts); ok {
+		return s, nil
+	}
+	interactive, command := opts.Interactive, opts.Command
+	supervised := opts.Restart != nil && opts.Restart.Policy != RestartNever && opts.Restart.Policy != ""
+	if supervised && interactive {
+		return nil, errors.New("restart policies are only supported for non-interactive shells")
+	}
+	if opts.Stdin != nil && interactive {
+		return nil, errors.New("stdin can only be streamed to non-interactive shells")
+	}
 	cmd := exec.Command(command[0], command[1:]...)
+	cmd.Dir = opts.Dir
+	cmd.Env = opts.environ()
+
+	shellID := uuid.New().String()
+	limits := opts.Limits
+	if limits == nil {
+		limits = sm.ResourceLimits()
+	}
+	group, ownGroup, err := sm.shellCgroup(opts, shellID)
+	if err != nil {
+		return nil, err
+	}
 
 	// For interactive shells, we MUST use a PTY to make the shell behave correctly.
 	// For non-interactive, simple pipes are sufficient and more lightweight.
 	var ptmx io.ReadWriteCloser
 	var stderrPipe io.ReadCloser
+	var ptyFile *os.File
+	var size WindowSize
 
 	if interactive {
 		// The PTY acts as both stdin and stdout for the shell process.
-		var err error
-		ptmx, err = pty.Start(cmd)
+		// Apply the manager's size before the shell starts so its first
+		// render already sees the right dimensions.
+		size = opts.Size
+		if size.IsZero() {
+			size = sm.Size()
+		}
+		if size.IsZero() {
+			size = DefaultWindowSize
+		}
+
+		// pty.StartWithSize runs the shell in a new session (Setsid) with the
+		// PTY as its controlling terminal, so it leads its own process group.
+		err = startConfined(cmd, limits, group, func() error {
+			var err error
+			ptyFile, err = pty.StartWithSize(cmd, &pty.Winsize{Rows: size.Rows, Cols: size.Cols})
+			return err
+		})
 		if err != nil {
+			if ptyFile != nil {
+				_ = ptyFile.Close()
+			}
+			releaseGroup(group, ownGroup)
 			return nil, fmt.Errorf("failed to start pty: %w", err)
 		}
+		ptmx = ptyFile
 		stderrPipe = ptmx // In a PTY, stderr is merged with stdout.
 	} else {
-		// Use standard pipes for non-interactive shells.
-		stdin, err := cmd.StdinPipe()
+		err = startConfined(cmd, limits, group, func() error {
+			var err error
+			ptmx, stderrPipe, err = startWithPipes(cmd)
+			return err
+		})
 		if err != nil {
-			return nil, fmt.Errorf("failed to create stdin pipe: %w", err)
-		}
-		stdout, err := cmd.StdoutPipe()
-		if err != nil {
-			return nil, fmt.Errorf("failed to create stdout pipe: %w", err)
-		}
-		stderrPipe, err = cmd.StderrPipe()
-		if err != nil {
-			return nil, fmt.Errorf("failed to create stderr pipe: %w", err)
+			if ptmx != nil {
+				_ = ptmx.Close()
+				_ = stderrPipe.Close()
+			}
+			releaseGroup(group, ownGroup)
+			return nil, err
 		}
+	}
 
-		if err := cmd.Start(); err != nil {
-			return nil, fmt.Errorf("failed to start command %v: %w", command, err)
-		}
-		// For non-interactive shells, we use the raw pipes.
-		ptmx = &pipeReadWriteCloser{r: stdout, w: stdin}
+	echo := opts.Echo
+	if echo == nil {
+		echo = sm.Echo()
+	}
+	filters := opts.Filters
+	if filters == nil {
+		filters = sm.Filters()
+	}
+	dialect := opts.Dialect
+	if dialect == nil {
+		dialect = DetectDialect(command)
 	}
 
-	shellID := uuid.New().String()
 	newShell := &ShellSession{
 		ID:          shellID,
 		Cmd:         cmd,
@@ -81,44 +167,157 @@ func (sm *ShellManager) SpawnShell(interactive bool, command ...string) (*ShellS
 		Stderr:      stderrPipe,
 		StartedAt:   time.Now(),
 		Interactive: interactive,
+		pty:         ptyFile,
+		logger:      sm.log().With("shell_id", shellID),
+		echo:        echo,
+		filters:     filters,
+		dialect:     dialect,
+		limits:      limits,
+		cgroup:      group,
+		ownCgroup:   ownGroup,
+		owner:       sm,
+	}
+	if interactive {
+		// Track the PTY's screen so callers can see what a user would see.
+		newShell.screen = screen.New(int(size.Rows), int(size.Cols))
+	}
+	scrollback := opts.Scrollback
+	if scrollback == nil {
+		scrollback = sm.ScrollbackLimits()
+	}
+	if scrollback != nil {
+		newShell.SetScrollbackLimits(*scrollback)
+	}
+
+	newShell.processStartedAt = newShell.StartedAt
+	if supervised {
+		restart := opts.Restart.withDefaults()
+		newShell.restart = &restart
+		newShell.stopRestart = make(chan struct{})
 	}
 
 	sm.mu.Lock()
 	sm.Shells[shellID] = newShell
 	sm.mu.Unlock()
 
-	// Define handlers that forward output to the ShellManager's own channel.
-	stdoutHandler := func(output []byte) {
-		// Also call the shell's default handler to populate its internal buffer.
-		newShell.OutputHandler(output)
+	// The shell's default handlers fill its buffers; what survives its
+	// filters is then published to the ShellManager's subscribers.
+	newShell.publish = sm.publisher(newShell)
+	newShell.addSinks(opts)
 
-		select {
-		case sm.OutputChan <- PaneOutput{ShellID: newShell.ID, Timestamp: time.Now(), Data: bytes.Clone(output)}:
-		case <-sm.closeChan:
-		}
+	// The ShellManager is now responsible for starting the I/O readers.
+	// This happens immediately, preventing any race conditions.
+	newShell.StartReading(newShell.OutputHandler, newShell.ErrorOutputHandler)
+
+	// Start the reaper so the process exit is noticed without polling.
+	go watchExit(newShell)
+	if opts.Stdin != nil {
+		_ = newShell.StreamStdin(opts.Stdin)
+	}
+
+	newShell.log().Info("shell spawned", "command", command, "interactive", interactive)
+	return newShell, nil
+}
+
+// publisher returns the hook that passes a shell's filtered output on to the
+// manager's subscribers.
+func (sm *ShellManager) publisher(s *ShellSession) func(data []byte, isStderr bool) {
+	return func(data []byte, isStderr bool) {
+		sm.output.Publish(PaneOutput{ShellID: s.ID, Timestamp: time.Now(), Data: bytes.Clone(data), IsStderr: isStderr})
+	}
+}
+
+// startWithPipes starts a non-interactive command on plain pipes and returns
+// its combined stdin/stdout and its stderr.
+func startWithPipes(cmd *exec.Cmd) (io.ReadWriteCloser, io.ReadCloser, error) {
+	// The output pipes are created by hand rather than with StdoutPipe/StderrPipe,
+	// because Cmd.Wait closes those as soon as the process exits and the reaper
+	// would race the readers for any output still in flight.
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to create stdin pipe: %w", err)
+	}
+	stdout, stdoutW, err := os.Pipe()
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to create stdout pipe: %w", err)
+	}
+	stderr, stderrW, err := os.Pipe()
+	if err != nil {
+		_ = stdout.Close()
+		_ = stdoutW.Close()
+		return nil, nil, fmt.Errorf("failed to create stderr pipe: %w", err)
+	}
+	cmd.Stdout = stdoutW
+	cmd.Stderr = stderrW
+	// Run the shell in its own process group, so signals and termination
+	// reach every process it starts.
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+
+	startErr := cmd.Start()
+	// The child holds its own copies of the write ends; ours must be closed
+	// so the readers see EOF once the child is gone.
+	_ = stdoutW.Close()
+	_ = stderrW.Close()
+	if startErr != nil {
+		_ = stdout.Close()
+		_ = stderr.Close()
+		return nil, nil, fmt.Errorf("failed to start command %v: %w", cmd.Args, startErr)
 	}
+	return &pipeReadWriteCloser{r: stdout, w: stdin}, stderr, nil
+}
+
+// manager returns the manager the shell currently belongs to.
+func (s *ShellSession) manager() *ShellManager {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.owner
+}
 
-	stderrHandler := func(output []byte) {
-		// Also call the shell's default handler to populate its internal buffer.
-		newShell.ErrorOutputHandler(output)
+// watchExit waits for a shell's process to exit and publishes its status on
+// the owning manager's ExitChan. Supervised shells are then restarted
+// according to their policy.
+func watchExit(s *ShellSession) {
+	for {
+		<-s.Done()
+		status, _ := s.ExitStatus()
+		s.log().Info("shell exited", "code", status.Code, "signaled", status.Signaled)
+		// A pooled shell may have been handed to another manager meanwhile.
+		sm := s.manager()
+		sm.notifyExit(status)
 
+		if !sm.restartAfter(s, status) {
+			s.releaseCgroup()
+			return
+		}
+	}
+}
+
+// notifyExit publishes an exit status on ExitChan without waiting for a
+// reader, so an undrained channel never holds up supervision. When the
+// channel is full, the oldest notification is dropped.
+func (sm *ShellManager) notifyExit(status ExitStatus) {
+	for {
 		select {
-		case sm.OutputChan <- PaneOutput{ShellID: newShell.ID, Timestamp: time.Now(), Data: bytes.Clone(output), IsStderr: true}:
+		case sm.ExitChan <- status:
+			return
 		case <-sm.closeChan:
+			return
+		default:
+		}
+		select {
+		case dropped := <-sm.ExitChan:
+			sm.log().Warn("exit notification dropped, ExitChan is full", "dropped_shell_id", dropped.ShellID)
+		default:
 		}
 	}
-
-	// The ShellManager is now responsible for starting the I/O readers.
-	// This happens immediately, preventing any race conditions.
-	newShell.StartReading(stdoutHandler, stderrHandler)
-
-	fmt.Printf("🐚 Shell spawned: %s (%v)\n", shellID, command)
-	return newShell, nil
 }
 
-// TerminateAllShells iterates through all managed shells and terminates them.
-func (sm *ShellManager) TerminateAllShells() {
+// TerminateAllShells iterates through all managed shells and terminates them,
+// returning the processes any of them left behind. Output subscriptions are
+// closed once the shells are gone.
+func (sm *ShellManager) TerminateAllShells() error {
 	close(sm.closeChan)
+	defer sm.output.Close()
 
 	sm.mu.Lock()
 	shellIDs := make([]string, 0, len(sm.Shells))
@@ -128,13 +327,30 @@ func (sm *ShellManager) TerminateAllShells() {
 	sm.mu.Unlock()
 
 	if len(shellIDs) == 0 {
-		return
+		return nil
 	}
-	fmt.Printf("🧹 Terminating all %d active shells...\n", len(shellIDs))
+	sm.log().Info("terminating all shells", "count", len(shellIDs))
 
+	var errs []error
 	for _, id := range shellIDs {
-		_ = sm.TerminateShell(id)
+		if err := sm.TerminateShell(id); err != nil {
+			errs = append(errs, err)
+		}
 	}
+	return errors.Join(errs...)
+}
+
+// List returns the shells the manager currently owns, oldest first.
+func (sm *ShellManager) List() []*ShellSession {
+	sm.mu.Lock()
+	shells := make([]*ShellSession, 0, len(sm.Shells))
+	for _, s := range sm.Shells {
+		shells = append(shells, s)
+	}
+	sm.mu.Unlock()
+
+	sort.Slice(shells, func(i, j int) bool { return shells[i].StartedAt.Before(shells[j].StartedAt) })
+	return shells
 }
 
 // SendCommand simulates sending a command to a shell.
@@ -149,11 +365,425 @@ func (sm *ShellManager) SendCommand(shellID, command string) (string, error) {
 	if err != nil {
 		return "", fmt.Errorf("failed to send command: %w", err)
 	}
-	fmt.Printf("🔄 Command sent to shell %s: %s\n", shellID, command)
+	shell.log().Debug("command sent", "command", command)
 	return "Command acknowledged", nil
 }
 
-// TerminateShell removes a shell session.
+// History returns the commands sent to a specific shell, oldest first.
+func (sm *ShellManager) History(shellID string) ([]HistoryEntry, error) {
+	shell, err := sm.lookup(shellID)
+	if err != nil {
+		return nil, err
+	}
+	return shell.History(), nil
+}
+
+// ExportHistory writes a specific shell's history to w as JSON.
+func (sm *ShellManager) ExportHistory(shellID string, w io.Writer) error {
+	shell, err := sm.lookup(shellID)
+	if err != nil {
+		return err
+	}
+	return shell.ExportHistory(w)
+}
+
+// Signal delivers sig to the foreground of a specific shell.
+func (sm *ShellManager) Signal(shellID string, sig os.Signal) error {
+	shell, err := sm.lookup(shellID)
+	if err != nil {
+		return err
+	}
+	return shell.Signal(sig)
+}
+
+// Interrupt sends Ctrl-C's SIGINT to a specific shell.
+func (sm *ShellManager) Interrupt(shellID string) error {
+	return sm.Signal(shellID, syscall.SIGINT)
+}
+
+// Suspend sends Ctrl-Z's SIGTSTP to a specific shell.
+func (sm *ShellManager) Suspend(shellID string) error {
+	return sm.Signal(shellID, syscall.SIGTSTP)
+}
+
+// Resume continues a suspended shell with SIGCONT.
+func (sm *ShellManager) Resume(shellID string) error {
+	return sm.Signal(shellID, syscall.SIGCONT)
+}
+
+// SendControl sends the control character for key, such as 'c' for Ctrl-C,
+// to a specific shell.
+func (sm *ShellManager) SendControl(shellID string, key byte) error {
+	shell, err := sm.lookup(shellID)
+	if err != nil {
+		return err
+	}
+	return shell.SendControl(key)
+}
+
+// SendKeys types tmux-style keys, such as "C-c" or "Up", into a specific shell.
+func (sm *ShellManager) SendKeys(shellID string, keys ...string) error {
+	shell, err := sm.lookup(shellID)
+	if err != nil {
+		return err
+	}
+	return shell.SendKeys(keys...)
+}
+
+// StreamStdin copies r into a specific non-interactive shell's stdin and
+// closes it at EOF. See ShellSession.StreamStdin.
+func (sm *ShellManager) StreamStdin(shellID string, r io.Reader) error {
+	shell, err := sm.lookup(shellID)
+	if err != nil {
+		return err
+	}
+	return shell.StreamStdin(r)
+}
+
+// CloseStdin sends EOF to a specific non-interactive shell.
+func (sm *ShellManager) CloseStdin(shellID string) error {
+	shell, err := sm.lookup(shellID)
+	if err != nil {
+		return err
+	}
+	return shell.CloseStdin()
+}
+
+// AddSink tees a specific shell's output on the selected streams into w.
+func (sm *ShellManager) AddSink(shellID string, w io.Writer, streams Stream) (remove func(), err error) {
+	shell, err := sm.lookup(shellID)
+	if err != nil {
+		return nil, err
+	}
+	return shell.AddSink(w, streams), nil
+}
+
+// Attach connects the local terminal to a specific PTY shell until the
+// default detach keys, C-b d, are typed. See ShellSession.Attach.
+func (sm *ShellManager) Attach(ctx context.Context, shellID string, in *os.File, out io.Writer) error {
+	return sm.AttachWithOptions(ctx, shellID, in, out, AttachOptions{})
+}
+
+// AttachWithOptions is Attach with a configurable detach sequence.
+func (sm *ShellManager) AttachWithOptions(ctx context.Context, shellID string, in *os.File, out io.Writer, opts AttachOptions) error {
+	shell, err := sm.lookup(shellID)
+	if err != nil {
+		return err
+	}
+	return shell.Attach(ctx, in, out, opts)
+}
+
+// lookup returns the shell with the given ID.
+func (sm *ShellManager) lookup(shellID string) (*ShellSession, error) {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+	shell, exists := sm.Shells[shellID]
+	if !exists {
+		return nil, errors.New("shell not found")
+	}
+	return shell, nil
+}
+
+// SetLogger sets the structured logger for the manager and every shell it
+// owns. A nil logger discards all records.
+func (sm *ShellManager) SetLogger(logger *slog.Logger) {
+	if logger == nil {
+		logger = discardLogger
+	}
+	sm.mu.Lock()
+	sm.logger = logger
+	shells := make([]*ShellSession, 0, len(sm.Shells))
+	for _, s := range sm.Shells {
+		shells = append(shells, s)
+	}
+	sm.mu.Unlock()
+
+	for _, s := range shells {
+		s.SetLogger(logger.With("shell_id", s.ID))
+	}
+}
+
+// Logger returns the manager's structured logger.
+func (sm *ShellManager) Logger() *slog.Logger {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+	return sm.logger
+}
+
+// log is shorthand for Logger.
+func (sm *ShellManager) log() *slog.Logger {
+	return sm.Logger()
+}
+
+// SetEcho copies everything the manager's shells print to w, such as
+// os.Stdout, for existing and future shells. A nil writer turns the echo off,
+// which is the default.
+func (sm *ShellManager) SetEcho(w io.Writer) {
+	sm.mu.Lock()
+	sm.echo = w
+	shells := make([]*ShellSession, 0, len(sm.Shells))
+	for _, s := range sm.Shells {
+		shells = append(shells, s)
+	}
+	sm.mu.Unlock()
+
+	for _, s := range shells {
+		s.SetEcho(w)
+	}
+}
+
+// Echo returns the console echo applied to new shells, or nil if it is off.
+func (sm *ShellManager) Echo() io.Writer {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+	return sm.echo
+}
+
+// SetFilters sets the output filter chain for existing and future shells.
+// Filtered output is what the shells' buffers, subscribers and echo see;
+// calling it with no filters turns filtering off.
+func (sm *ShellManager) SetFilters(filters ...Filter) {
+	sm.mu.Lock()
+	sm.filters = filters
+	shells := make([]*ShellSession, 0, len(sm.Shells))
+	for _, s := range sm.Shells {
+		shells = append(shells, s)
+	}
+	sm.mu.Unlock()
+
+	for _, s := range shells {
+		s.SetFilters(filters...)
+	}
+}
+
+// Filters returns the output filter chain applied to new shells.
+func (sm *ShellManager) Filters() []Filter {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+	return sm.filters
+}
+
+// Resize sets the PTY size used for new interactive shells and pushes it to
+// every interactive shell the manager currently owns. Pipe-based shells have
+// no terminal and are skipped.
+func (sm *ShellManager) Resize(rows, cols uint16) error {
+	sm.mu.Lock()
+	sm.size = WindowSize{Rows: rows, Cols: cols}
+	shells := make([]*ShellSession, 0, len(sm.Shells))
+	for _, s := range sm.Shells {
+		if s.pty != nil {
+			shells = append(shells, s)
+		}
+	}
+	sm.mu.Unlock()
+
+	var errs []error
+	for _, s := range shells {
+		if err := s.Resize(rows, cols); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Size returns the PTY size applied to new interactive shells.
+func (sm *ShellManager) Size() WindowSize {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+	return sm.size
+}
+
+// SetScrollbackLimits sets the output retention for every shell the manager
+// owns, trimming existing scrollback right away, and for shells spawned later.
+func (sm *ShellManager) SetScrollbackLimits(limits ScrollbackLimits) {
+	sm.mu.Lock()
+	sm.scrollback = &limits
+	shells := make([]*ShellSession, 0, len(sm.Shells))
+	for _, s := range sm.Shells {
+		shells = append(shells, s)
+	}
+	sm.mu.Unlock()
+
+	for _, s := range shells {
+		s.SetScrollbackLimits(limits)
+	}
+}
+
+// ScrollbackLimits returns the output retention applied to new shells, or nil
+// if none was set and DefaultScrollbackLimits applies.
+func (sm *ShellManager) ScrollbackLimits() *ScrollbackLimits {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+	if sm.scrollback == nil {
+		return nil
+	}
+	limits := *sm.scrollback
+	return &limits
+}
+
+// SetResourceLimits sets the setrlimit limits applied to shells spawned
+// from now on. Running shells keep the limits they started with.
+func (sm *ShellManager) SetResourceLimits(limits ResourceLimits) {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+	sm.limits = &limits
+}
+
+// ResourceLimits returns the limits applied to new shells, or nil.
+func (sm *ShellManager) ResourceLimits() *ResourceLimits {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+	if sm.limits == nil {
+		return nil
+	}
+	limits := *sm.limits
+	return &limits
+}
+
+// SetCgroup runs shells spawned from now on in group, or below it for shells
+// spawned with SpawnOptions.CgroupLimits. Running shells stay where they are.
+func (sm *ShellManager) SetCgroup(group *cgroup.Group) {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+	sm.cgroup = group
+}
+
+// Cgroup returns the cgroup new shells run in, or nil.
+func (sm *ShellManager) Cgroup() *cgroup.Group {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+	return sm.cgroup
+}
+
+// SetPool makes the manager take new shells from pool where it can, instead
+// of spawning them. Shells are only taken while the manager sets no
+// resource limits or cgroup. A nil pool turns this off.
+func (sm *ShellManager) SetPool(pool *ShellPool) {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+	sm.pool = pool
+}
+
+// Pool returns the pool new shells are taken from, or nil.
+func (sm *ShellManager) Pool() *ShellPool {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+	return sm.pool
+}
+
+// fromPool takes a shell for opts from the manager's pool, if it has one
+// ready, and makes it the manager's own.
+func (sm *ShellManager) fromPool(opts SpawnOptions) (*ShellSession, bool) {
+	sm.mu.Lock()
+	pool, confined := sm.pool, sm.limits != nil || sm.cgroup != nil
+	sm.mu.Unlock()
+	if pool == nil || confined {
+		return nil, false
+	}
+	s, ok := pool.Get(opts)
+	if !ok {
+		return nil, false
+	}
+	if err := sm.adopt(s, opts); err != nil {
+		s.log().Warn("failed to adopt pooled shell", "error", err)
+		_ = s.manager().TerminateShell(s.ID)
+		return nil, false
+	}
+	return s, true
+}
+
+// adopt moves a shell from the manager that spawned it to sm, and applies
+// the settings sm would have spawned it with.
+func (sm *ShellManager) adopt(s *ShellSession, opts SpawnOptions) error {
+	if exited(s) {
+		return fmt.Errorf("pooled shell %s has exited", s.ID)
+	}
+	prev := s.manager()
+	prev.mu.Lock()
+	delete(prev.Shells, s.ID)
+	prev.mu.Unlock()
+
+	sm.mu.Lock()
+	sm.Shells[s.ID] = s
+	sm.mu.Unlock()
+	s.mu.Lock()
+	s.owner = sm
+	s.publish = sm.publisher(s)
+	s.mu.Unlock()
+	s.SetLogger(sm.log().With("shell_id", s.ID))
+
+	echo := opts.Echo
+	if echo == nil {
+		echo = sm.Echo()
+	}
+	s.SetEcho(echo)
+	filters := opts.Filters
+	if filters == nil {
+		filters = sm.Filters()
+	}
+	s.SetFilters(filters...)
+	if opts.Dialect != nil {
+		s.SetDialect(opts.Dialect)
+	}
+	scrollback := opts.Scrollback
+	if scrollback == nil {
+		scrollback = sm.ScrollbackLimits()
+	}
+	if scrollback != nil {
+		s.SetScrollbackLimits(*scrollback)
+	}
+	if s.pty != nil {
+		size := opts.Size
+		if size.IsZero() {
+			size = sm.Size()
+		}
+		if current, err := s.Size(); !size.IsZero() && err == nil && current != size {
+			if err := s.Resize(size.Rows, size.Cols); err != nil {
+				return err
+			}
+		}
+	}
+
+	s.addSinks(opts)
+	if opts.Stdin != nil {
+		if err := s.StreamStdin(opts.Stdin); err != nil {
+			return err
+		}
+	}
+
+	s.log().Info("shell taken from pool", "command", opts.Command, "interactive", opts.Interactive)
+	return nil
+}
+
+// shellCgroup returns the cgroup a new shell should run in, creating one of
+// its own if the options ask for it.
+func (sm *ShellManager) shellCgroup(opts SpawnOptions, shellID string) (*cgroup.Group, bool, error) {
+	group := opts.Cgroup
+	if group == nil {
+		group = sm.Cgroup()
+	}
+	if opts.CgroupLimits == nil {
+		return group, false, nil
+	}
+	if group == nil {
+		return nil, false, errors.New("SpawnOptions.CgroupLimits requires a parent cgroup")
+	}
+	child, err := group.NewChild("shell-"+shellID, *opts.CgroupLimits)
+	if err != nil {
+		return nil, false, err
+	}
+	return child, true, nil
+}
+
+// releaseGroup removes a shell's own cgroup after it failed to start.
+func releaseGroup(group *cgroup.Group, own bool) {
+	if own {
+		_ = group.Remove()
+	}
+}
+
+// TerminateShell removes a shell session, stopping its whole process tree.
+// It returns a *SurvivorsError if any of the shell's processes outlive it.
 func (sm *ShellManager) TerminateShell(shellID string) error {
 	sm.mu.Lock()
 	shell, exists := sm.Shells[shellID]
@@ -162,14 +792,16 @@ func (sm *ShellManager) TerminateShell(shellID string) error {
 		return errors.New("shell not found")
 	}
 
-	// Close the underlying process with a 2-second grace period.
-	_ = shell.Close(2 * time.Second)
+	// Close the underlying process with a 2-second grace period. An exit
+	// status error is expected here; failures to kill the process or sweep
+	// its tree, and processes left behind, are reported.
+	closeErr := withoutExitStatus(shell.Close(2 * time.Second))
 
 	sm.mu.Lock()
 	delete(sm.Shells, shellID)
 	sm.mu.Unlock()
-	fmt.Printf("🧹 Shell terminated: %s\n", shellID)
-	return nil
+	shell.log().Info("shell terminated")
+	return closeErr
 }
 
 // pipeReadWriteCloser is a helper to adapt separate Read and Write closers
@@ -181,8 +813,32 @@ type pipeReadWriteCloser struct {
 
 func (prwc *pipeReadWriteCloser) Read(p []byte) (n int, err error)  { return prwc.r.Read(p) }
 func (prwc *pipeReadWriteCloser) Write(p []byte) (n int, err error) { return prwc.w.Write(p) }
+
+// CloseWrite closes only the stdin half, so the process reads EOF while its
+// output is still read.
+func (prwc *pipeReadWriteCloser) CloseWrite() error { return prwc.w.Close() }
+
 func (prwc *pipeReadWriteCloser) Close() error {
 	_ = prwc.r.Close()
 	_ = prwc.w.Close()
 	return nil
 }
+
+// withoutExitStatus drops the *exec.ExitError parts of err, which only
+// report how a terminated process exited, and keeps everything else.
+func withoutExitStatus(err error) error {
+	var errs []error
+	if joined, ok := err.(interface{ Unwrap() []error }); ok {
+		errs = joined.Unwrap()
+	} else if err != nil {
+		errs = []error{err}
+	}
+	var kept []error
+	for _, e := range errs {
+		var exitErr *exec.ExitError
+		if !errors.As(e, &exitErr) {
+			kept = append(kept, e)
+		}
+	}
+	return errors.Join(kept...)
+}
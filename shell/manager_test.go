@@ -0,0 +1,215 @@
+package shell_test
+
+import (
+	"context"
+	"regexp"
+	"strings"
+	"sync"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/owen-6936/termplex/assert"
+	"github.com/owen-6936/termplex/shell"
+)
+
+func TestSpawnShellAppliesAndPropagatesSize(t *testing.T) {
+	// 1. Configure a size before any shell exists.
+	sm := shell.NewShellManager(nil)
+	t.Cleanup(func() { _ = sm.TerminateAllShells() })
+	assert.NoError(t, sm.Resize(40, 120))
+
+	// 2. The interactive shell's PTY should start at that size.
+	session, err := sm.SpawnShell(true, "bash", "--norc", "-i")
+	assert.NoError(t, err)
+	size, err := session.Size()
+	assert.NoError(t, err)
+	assert.True(t, size.Rows == 40 && size.Cols == 120, "Expected initial size 40x120, got %dx%d", size.Rows, size.Cols)
+
+	// 3. Resizing the manager pushes the new size to existing shells.
+	assert.NoError(t, sm.Resize(24, 80))
+	size, err = session.Size()
+	assert.NoError(t, err)
+	assert.True(t, size.Rows == 24 && size.Cols == 80, "Expected resized 24x80, got %dx%d", size.Rows, size.Cols)
+
+	// 4. The shell itself sees the new size.
+	time.Sleep(200 * time.Millisecond)
+	assert.NoError(t, session.SendCommand("stty size"))
+	time.Sleep(300 * time.Millisecond)
+	assert.Contains(t, session.StderrBuf.String(), "24 80")
+}
+
+func TestResizeRejectsPipeShells(t *testing.T) {
+	sm := shell.NewShellManager(nil)
+	t.Cleanup(func() { _ = sm.TerminateAllShells() })
+
+	session, err := sm.SpawnShell(false, "bash", "-c", "sleep 1")
+	assert.NoError(t, err)
+
+	// Pipe-based shells have no terminal to resize.
+	assert.True(t, session.Resize(24, 80) != nil, "Expected an error resizing a pipe-based shell")
+	// But manager-wide resizes simply skip them.
+	assert.NoError(t, sm.Resize(24, 80))
+}
+
+func TestShellExitIsTrackedWithoutClose(t *testing.T) {
+	sm := shell.NewShellManager(nil)
+	t.Cleanup(func() { _ = sm.TerminateAllShells() })
+
+	// 1. Spawn a background service that crashes on its own.
+	session, err := sm.SpawnShell(false, "bash", "-c", "echo 'starting'; exit 3")
+	assert.NoError(t, err)
+
+	// 2. Wait for it through the session API.
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	status, err := session.Wait(ctx)
+	assert.NoError(t, err)
+	assert.True(t, status.Code == 3, "Expected exit code 3, got %d", status.Code)
+	assert.True(t, !status.Signaled, "Expected a normal exit, not a signal")
+	assert.True(t, !status.ExitedAt.IsZero(), "Expected ExitedAt to be recorded")
+
+	// 3. The manager should have published the same exit.
+	select {
+	case notified := <-sm.ExitChan:
+		assert.True(t, notified.ShellID == session.ID, "Expected exit for %s, got %s", session.ID, notified.ShellID)
+		assert.True(t, notified.Code == 3, "Expected notified exit code 3, got %d", notified.Code)
+	case <-time.After(2 * time.Second):
+		t.Fatal("Timed out waiting for exit notification")
+	}
+
+	// 4. Output written just before the exit must not be lost. The readers
+	// may still be draining it, so wait for them.
+	_, err = session.Expect(ctx, regexp.MustCompile(`starting`))
+	assert.NoError(t, err)
+}
+
+func TestShellExitRecordsSignal(t *testing.T) {
+	sm := shell.NewShellManager(nil)
+	t.Cleanup(func() { _ = sm.TerminateAllShells() })
+
+	session, err := sm.SpawnShell(false, "bash", "-c", "sleep 5")
+	assert.NoError(t, err)
+	assert.NoError(t, session.Cmd.Process.Signal(syscall.SIGKILL))
+
+	select {
+	case <-session.Done():
+	case <-time.After(2 * time.Second):
+		t.Fatal("Timed out waiting for Done after SIGKILL")
+	}
+	status, exited := session.ExitStatus()
+	assert.True(t, exited, "Expected the session to report an exit")
+	assert.True(t, status.Signaled && status.Signal == syscall.SIGKILL, "Expected SIGKILL, got %+v", status)
+	assert.True(t, status.Code == -1, "Expected exit code -1 for a signaled process, got %d", status.Code)
+}
+
+func TestSpawnShellWithOptionsSetsDirAndEnv(t *testing.T) {
+	sm := shell.NewShellManager(nil)
+	t.Cleanup(func() { _ = sm.TerminateAllShells() })
+
+	dir := t.TempDir()
+	session, err := sm.SpawnShellWithOptions(shell.SpawnOptions{
+		Command: []string{"bash", "-c", `echo "pwd=$(pwd) app=$APP_MODE term=$TERM"`},
+		Dir:     dir,
+		Env:     map[string]string{"APP_MODE": "staging"},
+		Term:    "xterm-256color",
+	})
+	assert.NoError(t, err)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	_, err = session.Wait(ctx)
+	assert.NoError(t, err)
+	time.Sleep(100 * time.Millisecond) // Let the reader drain the pipe.
+
+	output := session.OutputBuf.String()
+	assert.Contains(t, output, "pwd="+dir)
+	assert.Contains(t, output, "app=staging")
+	assert.Contains(t, output, "term=xterm-256color")
+}
+
+func TestRunOnInteractivePTYShell(t *testing.T) {
+	sm := shell.NewShellManager(nil)
+	t.Cleanup(func() { _ = sm.TerminateAllShells() })
+
+	session, err := sm.SpawnShell(true, "bash", "--norc", "-i")
+	assert.NoError(t, err)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	// The PTY echoes the typed command line, which must not be mistaken for
+	// the completion marker.
+	result, err := session.Run(ctx, "echo 'pty says hi'; exit_code_probe() { return 4; }; exit_code_probe")
+	assert.NoError(t, err)
+	assert.True(t, result.ExitCode == 4, "Expected exit code 4, got %d", result.ExitCode)
+	assert.Contains(t, result.Output, "pty says hi")
+}
+
+func TestInteractiveShellTracksScreen(t *testing.T) {
+	sm := shell.NewShellManager(nil)
+	t.Cleanup(func() { _ = sm.TerminateAllShells() })
+
+	session, err := sm.SpawnShellWithOptions(shell.SpawnOptions{
+		Interactive: true,
+		Command:     []string{"bash", "--norc", "-i"},
+		Size:        shell.WindowSize{Rows: 10, Cols: 40},
+	})
+	assert.NoError(t, err)
+
+	// 1. Draw text at a fixed position and wait for it to be rendered.
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	_, err = session.Run(ctx, `printf '\033[2J\033[5;3Hpositioned'`)
+	assert.NoError(t, err)
+
+	snap, err := session.Screen()
+	assert.NoError(t, err)
+	assert.True(t, snap.Rows == 10 && snap.Cols == 40, "Unexpected screen size %dx%d", snap.Rows, snap.Cols)
+	assert.True(t, strings.HasPrefix(snap.Lines()[4], "  positioned"), "Unexpected screen contents:\n%s", snap.Text())
+
+	// 2. Resizing the PTY resizes the screen with it.
+	assert.NoError(t, session.Resize(12, 50))
+	snap, err = session.Screen()
+	assert.NoError(t, err)
+	assert.True(t, snap.Rows == 12 && snap.Cols == 50, "Expected the screen to follow the PTY, got %dx%d", snap.Rows, snap.Cols)
+}
+
+func TestEchoIsOptIn(t *testing.T) {
+	sm := shell.NewShellManager(nil)
+	t.Cleanup(func() { _ = sm.TerminateAllShells() })
+
+	quiet, err := sm.SpawnShell(false, "bash")
+	assert.NoError(t, err)
+
+	// 1. Shells spawned after SetEcho copy their output to the writer.
+	var echo strings.Builder
+	var echoMu sync.Mutex
+	sm.SetEcho(writerFunc(func(p []byte) (int, error) {
+		echoMu.Lock()
+		defer echoMu.Unlock()
+		return echo.Write(p)
+	}))
+	loud, err := sm.SpawnShell(false, "bash")
+	assert.NoError(t, err)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	_, err = loud.Run(ctx, "echo echoed-line")
+	assert.NoError(t, err)
+
+	// 2. Turning the echo off again silences every shell.
+	sm.SetEcho(nil)
+	_, err = quiet.Run(ctx, "echo silent-line")
+	assert.NoError(t, err)
+
+	echoMu.Lock()
+	defer echoMu.Unlock()
+	assert.Contains(t, echo.String(), "echoed-line")
+	assert.True(t, !strings.Contains(echo.String(), "silent-line"), "Expected no echo after SetEcho(nil)")
+}
+
+// writerFunc adapts a function to io.Writer.
+type writerFunc func(p []byte) (int, error)
+
+func (f writerFunc) Write(p []byte) (int, error) { return f(p) }
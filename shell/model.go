@@ -1,11 +1,16 @@
 package shell
 
 import (
-	"bytes"
 	"io"
+	"log/slog"
+	"os"
 	"os/exec"
 	"sync"
+	"syscall"
 	"time"
+
+	"github.com/owen-6936/termplex/cgroup"
+	"github.com/owen-6936/termplex/screen"
 )
 
 // PaneOutput represents a piece of output from a shell within a pane,
@@ -17,17 +22,130 @@ type PaneOutput struct {
 	IsStderr  bool
 }
 
+// WindowSize describes the dimensions of a shell's terminal in character cells.
+// A zero value means "no size set"; new PTYs then use DefaultWindowSize.
+type WindowSize struct {
+	Rows uint16
+	Cols uint16
+}
+
+// DefaultWindowSize is the PTY size used when neither the spawn options nor
+// the manager specify one, matching the classic VT100 screen.
+var DefaultWindowSize = WindowSize{Rows: 24, Cols: 80}
+
+// IsZero reports whether no dimensions have been set.
+func (ws WindowSize) IsZero() bool {
+	return ws.Rows == 0 && ws.Cols == 0
+}
+
+// SpawnOptions configures how a shell process is started.
+type SpawnOptions struct {
+	Interactive bool              // Run the shell on a PTY instead of plain pipes.
+	Command     []string          // The program to run, followed by its arguments.
+	Dir         string            // Working directory; empty inherits the caller's.
+	Env         map[string]string // Variables layered over the caller's environment.
+	Term        string            // Value for TERM; empty inherits the caller's.
+	Lang        string            // Value for LANG; empty inherits the caller's.
+	Size        WindowSize        // Initial PTY size; zero uses the manager's size.
+	Scrollback  *ScrollbackLimits // Output retention; nil uses the manager's limits.
+	Echo        io.Writer         // Receives a copy of everything the shell prints; nil uses the manager's echo.
+	Restart     *RestartOptions   // Supervision for non-interactive shells; nil never restarts.
+	Filters     []Filter          // Output filter chain; nil uses the manager's filters.
+	Dialect     Dialect           // Syntax used by Run; nil detects it from Command.
+	Limits      *ResourceLimits   // setrlimit limits; nil uses the manager's limits.
+	Cgroup      *cgroup.Group     // cgroup v2 group to run in; nil uses the manager's group.
+	// CgroupLimits gives the shell a cgroup of its own, created below Cgroup
+	// with these limits and removed once the shell has exited for good.
+	CgroupLimits *cgroup.Limits
+	// Stdin is streamed into a non-interactive shell's stdin, which is
+	// closed once it is exhausted; see ShellSession.StreamStdin.
+	Stdin io.Reader
+	// Stdout and Stderr receive a copy of the shell's filtered output on
+	// each stream from the start; see ShellSession.AddSink.
+	Stdout io.Writer
+	Stderr io.Writer
+}
+
+// ExitStatus records how a shell process ended. It is delivered on the
+// manager's ExitChan and is available from ShellSession.ExitStatus once the
+// process has been reaped.
+type ExitStatus struct {
+	ShellID  string
+	Code     int            // The exit code, or -1 if the process was terminated by a signal.
+	Signaled bool           // Whether the process was terminated by a signal.
+	Signal   syscall.Signal // The terminating signal when Signaled is true.
+	ExitedAt time.Time      // Timestamp of when the process was reaped.
+	Restarts int            // How many times a supervised shell had been restarted before this exit.
+}
+
 // ShellSession represents an active, managed shell process.
 // It holds references to the process's I/O streams and buffers for capturing output.
 type ShellSession struct {
-	ID          string         // Unique identifier for the session.
-	Cmd         *exec.Cmd      // The underlying command process.
-	Stdin       io.WriteCloser // Pipe for writing to the shell's standard input.
-	Stdout      io.ReadCloser  // Pipe for reading from the shell's standard output.
-	Stderr      io.ReadCloser  // Pipe for reading from the shell's standard error.
-	StartedAt   time.Time      // Timestamp of when the session was created.
-	Interactive bool           // Tracks if the shell is interactive.
-	OutputBuf   bytes.Buffer   // Buffer to capture stdout.
-	StderrBuf   bytes.Buffer   // Buffer to capture stderr.
-	mu          sync.Mutex     // Mutex to protect concurrent access to session buffers.
+	ID          string          // Unique identifier for the session.
+	Cmd         *exec.Cmd       // The underlying command process.
+	Stdin       io.WriteCloser  // Pipe for writing to the shell's standard input.
+	Stdout      io.ReadCloser   // Pipe for reading from the shell's standard output.
+	Stderr      io.ReadCloser   // Pipe for reading from the shell's standard error.
+	StartedAt   time.Time       // Timestamp of when the session was created.
+	Interactive bool            // Tracks if the shell is interactive.
+	OutputBuf   Scrollback      // Bounded scrollback capturing stdout.
+	StderrBuf   Scrollback      // Bounded scrollback capturing stderr (and all output on a PTY).
+	mu          sync.Mutex      // Mutex to protect concurrent access to session buffers.
+	pty         *os.File        // The PTY master for interactive shells; nil for pipe-based shells.
+	screen      *screen.Screen  // Terminal emulator tracking what a PTY shell displays; nil for pipe-based shells.
+	done        chan struct{}   // Closed by the reaper once the process has exited.
+	exit        *ExitStatus     // Set by the reaper before done is closed.
+	waitErr     error           // The error returned by Cmd.Wait.
+	changed     chan struct{}   // Closed and replaced whenever new output is buffered.
+	readDone    chan struct{}   // Closed once StartReading's goroutines have drained their streams.
+	expectMu    sync.Mutex      // Serializes Expect calls so each match consumes output once.
+	cursor      int64           // Read cursor for Expect, as an absolute offset into the primary output stream.
+	logger      *slog.Logger    // Carries the shell_id attribute; nil discards.
+	echo        io.Writer       // Optional console echo of everything the shell prints.
+	taps        []*tapEntry     // Receivers of the shell's traffic; replaced, never mutated, on change.
+	sinks       []*sinkEntry    // Writers teed the shell's filtered output; replaced, never mutated, on change.
+	streaming   bool            // Whether a StreamStdin copy is running.
+	dialect     Dialect         // Syntax used by Run; nil means Sh.
+	limits      *ResourceLimits // setrlimit limits applied to every process started for the shell.
+	cgroup      *cgroup.Group   // The cgroup every process of the shell starts in.
+	ownCgroup   bool            // Whether cgroup was created for this shell alone.
+	owner       *ShellManager   // The manager the shell belongs to; changes when a pooled shell is handed out.
+	history     []HistoryEntry  // Commands sent to the shell, oldest first.
+	historySeq  int             // Sequence number of the latest history entry.
+
+	// Supervision state for shells spawned with a restart policy.
+	restart          *RestartOptions
+	restarts         int
+	restartTimes     []time.Time   // Restarts within the policy's window.
+	crashes          int           // Consecutive restarts, for the backoff.
+	processStartedAt time.Time     // When the current process was started.
+	closing          bool          // Set by Close; stops any further restarts.
+	stopRestart      chan struct{} // Closed by Close to cut a pending backoff short.
+	stdoutHandler    func([]byte)  // The handlers given to StartReading, reused after a restart.
+	stderrHandler    func([]byte)
+
+	// Output filtering; see SetFilters.
+	publish       func(data []byte, isStderr bool) // Receives filtered output for the manager's subscribers.
+	filterMu      sync.Mutex                       // Serializes filtering, so output is delivered in order.
+	filters       []Filter
+	filterStreams [2]filterStream // Held-back partial lines of stdout and stderr.
+}
+
+// ExpectMatch describes output matched by Expect or ExpectAny.
+type ExpectMatch struct {
+	Index  int      // Position of the matching pattern among the ExpectAny arguments.
+	Text   string   // The full matched text.
+	Groups []string // Capture groups, in order; empty when the pattern has none.
+	Before string   // Output consumed between the previous cursor and the match.
+	// Truncated reports that output after the previous cursor was evicted
+	// from the scrollback before it was read, so Before is missing its head.
+	Truncated bool
+}
+
+// RunResult describes a command executed with ShellSession.Run.
+type RunResult struct {
+	Output    string        // Everything the command wrote to the shell's output stream.
+	ExitCode  int           // The command's exit status, as reported by the shell.
+	Duration  time.Duration // Time from sending the command to seeing its completion marker.
+	Truncated bool          // The head of Output was evicted from the scrollback before it was read.
 }
@@ -0,0 +1,325 @@
+package shell
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultPoolSize is the number of idle shells a pool keeps per command
+// signature when PoolOptions.Size is zero.
+const DefaultPoolSize = 2
+
+// DefaultPoolReadyTimeout bounds how long a pooled shell may take to become
+// ready when PoolOptions.ReadyTimeout is zero.
+const DefaultPoolReadyTimeout = 10 * time.Second
+
+// errPoolClosed is returned when a closed pool is asked for shells.
+var errPoolClosed = errors.New("shell pool is closed")
+
+// PoolOptions configure a ShellPool.
+type PoolOptions struct {
+	Size int // Idle shells kept per command signature; zero uses DefaultPoolSize.
+	// Ready blocks until a new shell has finished starting up, such as
+	// running its rc files. Nil waits for the dialect's prompt on PTY shells
+	// and treats shells on plain pipes as ready once started.
+	Ready func(ctx context.Context, s *ShellSession) error
+	// ReadyTimeout bounds Ready; shells that miss it are discarded. Zero
+	// uses DefaultPoolReadyTimeout.
+	ReadyTimeout time.Duration
+}
+
+// ShellPool keeps idle, already initialized shells so panes can start
+// without waiting for a shell's startup. Shells are pooled per command
+// signature: the command, whether it runs on a PTY, its working directory,
+// environment, TERM and LANG. Each signature is refilled in the background
+// as its shells are handed out, so panes that differ only in their working
+// directory each keep their own warm shells.
+type ShellPool struct {
+	mu      sync.Mutex
+	opts    PoolOptions
+	manager *ShellManager // Owns the idle shells until they are handed out.
+	idle    map[string][]*ShellSession
+	warming map[string]int // Shells being started, per signature.
+	closed  bool
+	wg      sync.WaitGroup // Background refills and discards.
+}
+
+// NewShellPool returns an empty pool. Use Warm to fill it ahead of time; Get
+// fills it on first use otherwise.
+func NewShellPool(opts PoolOptions) *ShellPool {
+	if opts.Size <= 0 {
+		opts.Size = DefaultPoolSize
+	}
+	if opts.ReadyTimeout <= 0 {
+		opts.ReadyTimeout = DefaultPoolReadyTimeout
+	}
+	p := &ShellPool{
+		opts:    opts,
+		manager: NewShellManager(nil),
+		idle:    make(map[string][]*ShellSession),
+		warming: make(map[string]int),
+	}
+	go p.drainExits()
+	return p
+}
+
+// drainExits discards the exit notifications of idle shells, which nobody
+// else reads.
+func (p *ShellPool) drainExits() {
+	for {
+		select {
+		case <-p.manager.ExitChan:
+		case <-p.manager.closeChan:
+			return
+		}
+	}
+}
+
+// Warm starts shells for opts's signature until the pool holds Size of them,
+// and waits until they are ready.
+func (p *ShellPool) Warm(ctx context.Context, opts SpawnOptions) error {
+	if err := poolable(opts); err != nil {
+		return err
+	}
+	done := make(chan error, 1)
+	go func() { done <- p.fill(opts) }()
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		return fmt.Errorf("warming shell pool: %w", ctx.Err())
+	}
+}
+
+// Get takes a ready idle shell matching opts out of the pool and starts a
+// replacement in the background. Idle shells that have exited are
+// discarded on the way. It returns false
+// if no matching shell is ready, or if opts ask for something a running
+// shell cannot be given, such as resource limits or a restart policy.
+func (p *ShellPool) Get(opts SpawnOptions) (*ShellSession, bool) {
+	if poolable(opts) != nil {
+		return nil, false
+	}
+	key := poolKey(opts)
+
+	p.mu.Lock()
+	if p.closed {
+		p.mu.Unlock()
+		return nil, false
+	}
+	var found *ShellSession
+	for len(p.idle[key]) > 0 && found == nil {
+		s := p.idle[key][0]
+		p.idle[key] = p.idle[key][1:]
+		if exited(s) {
+			p.discardLocked(s)
+			continue
+		}
+		found = s
+	}
+	p.wg.Add(1)
+	p.mu.Unlock()
+
+	go func() {
+		defer p.wg.Done()
+		if err := p.fill(opts); err != nil && !errors.Is(err, errPoolClosed) {
+			p.manager.log().Warn("failed to refill shell pool", "command", opts.Command, "error", err)
+		}
+	}()
+	return found, found != nil
+}
+
+// Idle returns the number of ready shells pooled for opts's signature.
+func (p *ShellPool) Idle(opts SpawnOptions) int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return len(p.idle[poolKey(opts)])
+}
+
+// Close terminates every idle shell and stops refilling. Shells already
+// handed out are not affected.
+func (p *ShellPool) Close() error {
+	p.mu.Lock()
+	if p.closed {
+		p.mu.Unlock()
+		return nil
+	}
+	p.closed = true
+	p.idle = make(map[string][]*ShellSession)
+	p.mu.Unlock()
+
+	p.wg.Wait()
+	return p.manager.TerminateAllShells()
+}
+
+// fill discards idle shells for opts's signature that have exited and
+// starts enough new ones to bring the pool back to Size.
+func (p *ShellPool) fill(opts SpawnOptions) error {
+	opts = idleOptions(opts)
+	key := poolKey(opts)
+	p.mu.Lock()
+	if p.closed {
+		p.mu.Unlock()
+		return errPoolClosed
+	}
+	kept := p.idle[key][:0]
+	for _, s := range p.idle[key] {
+		if exited(s) {
+			p.discardLocked(s)
+			continue
+		}
+		kept = append(kept, s)
+	}
+	p.idle[key] = kept
+	missing := p.opts.Size - len(kept) - p.warming[key]
+	if missing <= 0 {
+		p.mu.Unlock()
+		return nil
+	}
+	p.warming[key] += missing
+	p.wg.Add(missing)
+	p.mu.Unlock()
+
+	errs := make(chan error, missing)
+	for range missing {
+		go func() {
+			defer p.wg.Done()
+			errs <- p.warmOne(key, opts)
+		}()
+	}
+	var all []error
+	for range missing {
+		if err := <-errs; err != nil {
+			all = append(all, err)
+		}
+	}
+	return errors.Join(all...)
+}
+
+// warmOne starts a shell, waits until it is ready and adds it to the pool.
+func (p *ShellPool) warmOne(key string, opts SpawnOptions) error {
+	s, err := p.manager.SpawnShellWithOptions(opts)
+	if err == nil {
+		ctx, cancel := context.WithTimeout(context.Background(), p.opts.ReadyTimeout)
+		err = p.ready(ctx, s)
+		cancel()
+		if err != nil {
+			_ = p.manager.TerminateShell(s.ID)
+			err = fmt.Errorf("pooled shell %v did not become ready: %w", opts.Command, err)
+		}
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.warming[key]--
+	if err != nil {
+		return err
+	}
+	if p.closed {
+		_ = p.manager.TerminateShell(s.ID)
+		return errPoolClosed
+	}
+	p.idle[key] = append(p.idle[key], s)
+	s.log().Debug("shell pooled", "command", opts.Command)
+	return nil
+}
+
+// ready runs the pool's readiness check on a new shell.
+func (p *ShellPool) ready(ctx context.Context, s *ShellSession) error {
+	if p.opts.Ready != nil {
+		return p.opts.Ready(ctx, s)
+	}
+	if s.pty == nil {
+		return nil
+	}
+	return s.awaitOutput(ctx, s.Dialect().Prompt())
+}
+
+// discardLocked terminates an idle shell in the background. The caller must
+// hold p.mu.
+func (p *ShellPool) discardLocked(s *ShellSession) {
+	p.wg.Add(1)
+	go func() {
+		defer p.wg.Done()
+		_ = p.manager.TerminateShell(s.ID)
+	}()
+}
+
+// awaitOutput blocks until the unread output matches pattern, without
+// consuming it, so a later Expect still sees it.
+func (s *ShellSession) awaitOutput(ctx context.Context, pattern *regexp.Regexp) error {
+	closed := s.outputDone()
+	for {
+		s.mu.Lock()
+		pending, _ := s.primaryBufLocked().Since(s.cursor)
+		matched := pattern.Match(pending)
+		changed := s.outputChangedLocked()
+		s.mu.Unlock()
+
+		if matched {
+			return nil
+		}
+		select {
+		case <-changed:
+		case <-closed:
+			return fmt.Errorf("session %s closed its output before it matched: %w", s.ID, io.EOF)
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// poolable reports why opts cannot be served from a pool: limits, cgroups
+// and supervision are fixed when a process starts.
+func poolable(opts SpawnOptions) error {
+	switch {
+	case len(opts.Command) == 0:
+		return errors.New("SpawnShell requires a command to execute")
+	case opts.Restart != nil && opts.Restart.Policy != RestartNever && opts.Restart.Policy != "":
+		return errors.New("supervised shells cannot be pooled")
+	case opts.Limits != nil || opts.Cgroup != nil || opts.CgroupLimits != nil:
+		return errors.New("shells with resource limits or a cgroup cannot be pooled")
+	}
+	return nil
+}
+
+// idleOptions strips what belongs to the caller rather than the shell from
+// opts. An idle shell must not read the caller's stdin or write to its
+// sinks; adopt attaches them once the shell is handed out.
+func idleOptions(opts SpawnOptions) SpawnOptions {
+	opts.Stdin, opts.Stdout, opts.Stderr, opts.Echo = nil, nil, nil, nil
+	return opts
+}
+
+// poolKey is the command signature shells are pooled under: everything
+// that shapes how a shell is started. Fields are separated by NUL, which
+// cannot occur in arguments or environment variables.
+func poolKey(opts SpawnOptions) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%t\x00%s\x00%s\x00%s\x00%d", opts.Interactive, opts.Dir, opts.Term, opts.Lang, len(opts.Command))
+	for _, arg := range opts.Command {
+		fmt.Fprintf(&b, "\x00%s", arg)
+	}
+	for _, k := range slices.Sorted(maps.Keys(opts.Env)) {
+		fmt.Fprintf(&b, "\x00%s=%s", k, opts.Env[k])
+	}
+	return b.String()
+}
+
+// exited reports whether a shell's process has exited.
+func exited(s *ShellSession) bool {
+	select {
+	case <-s.Done():
+		return true
+	default:
+		return false
+	}
+}
@@ -0,0 +1,141 @@
+package shell_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/owen-6936/termplex/assert"
+	"github.com/owen-6936/termplex/fanout"
+	"github.com/owen-6936/termplex/shell"
+)
+
+func TestShellPoolHandsOutWarmShells(t *testing.T) {
+	pool := shell.NewShellPool(shell.PoolOptions{Size: 2})
+	t.Cleanup(func() { _ = pool.Close() })
+	sm := shell.NewShellManager(nil)
+	t.Cleanup(func() { _ = sm.TerminateAllShells() })
+	sm.SetPool(pool)
+	output, _ := sm.Subscribe(fanout.Options{})
+
+	opts := shell.SpawnOptions{
+		Interactive: true,
+		Command:     []string{"bash", "--norc", "--noprofile"},
+		Env:         map[string]string{"PS1": "$ ", "POOL_VAR": "warm"},
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	// 1. Warming starts Size shells and waits until they show a prompt.
+	assert.NoError(t, pool.Warm(ctx, opts))
+	assert.True(t, pool.Idle(opts) == 2, "Expected 2 idle shells, got %d", pool.Idle(opts))
+
+	// 2. A matching spawn takes a pooled shell, prompt still unread.
+	before := time.Now()
+	session, err := sm.SpawnShellWithOptions(opts)
+	assert.NoError(t, err)
+	assert.True(t, session.StartedAt.Before(before), "Expected a pre-started shell")
+	assert.True(t, len(sm.List()) == 1, "Expected the shell to belong to the manager")
+	_, err = session.WaitForPrompt(ctx)
+	assert.NoError(t, err)
+	result, err := session.Run(ctx, "echo $POOL_VAR")
+	assert.NoError(t, err)
+	assert.Contains(t, result.Output, "warm")
+	select {
+	case out := <-output:
+		assert.True(t, out.ShellID == session.ID, "Expected output from the pooled shell")
+	case <-ctx.Done():
+		t.Fatal("Expected the pooled shell's output on the manager's stream")
+	}
+
+	// 3. The pool refills in the background.
+	assert.True(t, waitFor(10*time.Second, func() bool { return pool.Idle(opts) == 2 }), "Expected the pool to refill")
+
+	// 4. The shell's exit is reported by its new manager.
+	assert.NoError(t, session.SendCommand("exit 4"))
+	select {
+	case status := <-sm.ExitChan:
+		assert.True(t, status.ShellID == session.ID && status.Code == 4, "Unexpected exit %+v", status)
+	case <-ctx.Done():
+		t.Fatal("Expected the exit on the manager's ExitChan")
+	}
+}
+
+func TestShellPoolKeepsShellsPerDirectory(t *testing.T) {
+	pool := shell.NewShellPool(shell.PoolOptions{Size: 1})
+	t.Cleanup(func() { _ = pool.Close() })
+	sm := shell.NewShellManager(nil)
+	t.Cleanup(func() { _ = sm.TerminateAllShells() })
+	sm.SetPool(pool)
+
+	opts := shell.SpawnOptions{Command: []string{"sh"}, Dir: t.TempDir()}
+	other := opts
+	other.Dir = t.TempDir()
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	assert.NoError(t, pool.Warm(ctx, opts))
+	assert.NoError(t, pool.Warm(ctx, other))
+
+	// 1. Each directory has its own warm shell; asking for one leaves the
+	// other's alone.
+	for _, o := range []shell.SpawnOptions{other, opts, other} {
+		before := time.Now()
+		session, err := sm.SpawnShellWithOptions(o)
+		assert.NoError(t, err)
+		assert.True(t, session.StartedAt.Before(before), "Expected a pooled shell for %s", o.Dir)
+		result, err := session.Run(ctx, "pwd")
+		assert.NoError(t, err)
+		assert.Contains(t, result.Output, o.Dir)
+		assert.True(t, waitFor(5*time.Second, func() bool { return pool.Idle(opts) == 1 && pool.Idle(other) == 1 }), "Expected both directories to stay warm")
+	}
+
+	// 2. Shells that need limits are never pooled.
+	limited := other
+	limited.Limits = &shell.ResourceLimits{OpenFiles: 64}
+	_, ok := pool.Get(limited)
+	assert.True(t, !ok, "Expected no pooled shell with resource limits")
+
+	// 3. A closed pool hands out nothing.
+	assert.NoError(t, pool.Close())
+	_, ok = pool.Get(other)
+	assert.True(t, !ok, "Expected no shell from a closed pool")
+}
+
+func TestShellPoolKeepsCallerStreamsAwayFromIdleShells(t *testing.T) {
+	pool := shell.NewShellPool(shell.PoolOptions{Size: 2})
+	t.Cleanup(func() { _ = pool.Close() })
+	sm := shell.NewShellManager(nil)
+	t.Cleanup(func() { _ = sm.TerminateAllShells() })
+	sm.SetPool(pool)
+
+	opts := shell.SpawnOptions{Command: []string{"sh", "-c", "echo started $$; cat"}}
+
+	// 1. The first spawn starts a fresh shell and fills the pool behind it.
+	var first syncBuffer
+	firstOpts := opts
+	firstOpts.Stdin = strings.NewReader("first-input\n")
+	firstOpts.Stdout = &first
+	_, err := sm.SpawnShellWithOptions(firstOpts)
+	assert.NoError(t, err)
+	assert.True(t, waitFor(10*time.Second, func() bool { return pool.Idle(opts) == 2 }), "Expected the pool to fill")
+	assert.True(t, waitFor(5*time.Second, func() bool { return strings.Contains(first.String(), "first-input") }), "Expected the first shell's output")
+
+	// 2. The idle shells wrote nothing to the caller's sink and did not
+	// consume its stdin, so they are still alive.
+	time.Sleep(100 * time.Millisecond)
+	assert.True(t, strings.Count(first.String(), "started") == 1, "Idle shells wrote to the caller's sink: %q", first.String())
+	assert.True(t, pool.Idle(opts) == 2, "Expected the idle shells to stay alive, got %d", pool.Idle(opts))
+
+	// 3. A pooled shell gets the new caller's streams only.
+	var second syncBuffer
+	secondOpts := opts
+	secondOpts.Stdin = strings.NewReader("second-input\n")
+	secondOpts.Stdout = &second
+	before := time.Now()
+	session, err := sm.SpawnShellWithOptions(secondOpts)
+	assert.NoError(t, err)
+	assert.True(t, session.StartedAt.Before(before), "Expected a pooled shell")
+	assert.True(t, waitFor(5*time.Second, func() bool { return strings.Contains(second.String(), "second-input") }), "Expected the pooled shell to read the caller's stdin")
+	assert.True(t, !strings.Contains(first.String(), "second-input"), "The first caller's sink saw the second caller's output")
+}
@@ -0,0 +1,257 @@
+package shell
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// procEntry is the subset of /proc/<pid>/stat needed to track a process tree.
+type procEntry struct {
+	PID     int
+	PPID    int
+	PGID    int
+	SID     int
+	State   byte
+	Start   uint64 // Start time in clock ticks since boot; guards against PID reuse.
+	Command string
+	UTime   uint64 // User CPU time in clock ticks.
+	STime   uint64 // System CPU time in clock ticks.
+	Threads int
+	RSS     int64 // Resident set size in pages.
+}
+
+// sameProcess reports whether e and other describe the same process, not
+// merely a recycled PID.
+func (e procEntry) sameProcess(other procEntry) bool {
+	return e.PID == other.PID && e.Start == other.Start
+}
+
+// readProcEntry parses /proc/<pid>/stat.
+func readProcEntry(pid int) (procEntry, error) {
+	data, err := os.ReadFile("/proc/" + strconv.Itoa(pid) + "/stat")
+	if err != nil {
+		return procEntry{}, err
+	}
+	return parseProcStat(data)
+}
+
+// parseProcStat parses the contents of a /proc/<pid>/stat file. The command
+// name is parenthesized and may itself contain spaces and parentheses.
+func parseProcStat(data []byte) (procEntry, error) {
+	open := bytes.IndexByte(data, '(')
+	end := bytes.LastIndexByte(data, ')')
+	if open < 0 || end < open {
+		return procEntry{}, errors.New("malformed stat line")
+	}
+	pid, err := strconv.Atoi(strings.TrimSpace(string(data[:open])))
+	if err != nil {
+		return procEntry{}, fmt.Errorf("malformed pid: %w", err)
+	}
+
+	// Fields after the command, starting with field 3 (state).
+	fields := strings.Fields(string(data[end+1:]))
+	if len(fields) < 22 {
+		return procEntry{}, errors.New("truncated stat line")
+	}
+	e := procEntry{PID: pid, State: fields[0][0], Command: string(data[open+1 : end])}
+	if e.PPID, err = strconv.Atoi(fields[1]); err != nil {
+		return procEntry{}, err
+	}
+	if e.PGID, err = strconv.Atoi(fields[2]); err != nil {
+		return procEntry{}, err
+	}
+	if e.SID, err = strconv.Atoi(fields[3]); err != nil {
+		return procEntry{}, err
+	}
+	if e.UTime, err = strconv.ParseUint(fields[11], 10, 64); err != nil {
+		return procEntry{}, err
+	}
+	if e.STime, err = strconv.ParseUint(fields[12], 10, 64); err != nil {
+		return procEntry{}, err
+	}
+	if e.Threads, err = strconv.Atoi(fields[17]); err != nil {
+		return procEntry{}, err
+	}
+	if e.Start, err = strconv.ParseUint(fields[19], 10, 64); err != nil {
+		return procEntry{}, err
+	}
+	if e.RSS, err = strconv.ParseInt(fields[21], 10, 64); err != nil {
+		return procEntry{}, err
+	}
+	return e, nil
+}
+
+// listProcesses returns every process visible in /proc. Processes that exit
+// while the table is being read are skipped.
+func listProcesses() ([]procEntry, error) {
+	dirs, err := os.ReadDir("/proc")
+	if err != nil {
+		return nil, err
+	}
+	procs := make([]procEntry, 0, len(dirs))
+	for _, d := range dirs {
+		pid, err := strconv.Atoi(d.Name())
+		if err != nil {
+			continue
+		}
+		if e, err := readProcEntry(pid); err == nil {
+			procs = append(procs, e)
+		}
+	}
+	return procs, nil
+}
+
+// processTree returns the live processes that belong to the shell rooted at
+// root: its descendants, members of its process group or session, and the
+// descendants of any previously tracked process. Tracked processes are
+// matched by start time, so a recycled PID is never mistaken for one. Zombies
+// are already dead and are left out. The root itself is not included.
+//
+// Once the root has been reaped, another process may be given its PID. The
+// kernel does not hand out a PID while it still names a process group or
+// session, so if an unrelated process holds the root's PID, the shell's
+// group and session are gone and recycled is true: only tracked processes
+// and their descendants are returned, and the PID must not be used to
+// signal a group.
+func processTree(root procEntry, tracked []procEntry) (tree []procEntry, recycled bool, err error) {
+	procs, err := listProcesses()
+	if err != nil {
+		return nil, false, err
+	}
+	pid := root.PID
+
+	children := make(map[int][]procEntry)
+	byPID := make(map[int]procEntry, len(procs))
+	for _, p := range procs {
+		children[p.PPID] = append(children[p.PPID], p)
+		byPID[p.PID] = p
+	}
+
+	if p, ok := byPID[pid]; ok && !p.sameProcess(root) {
+		recycled = true
+	}
+
+	seen := map[int]bool{pid: true}
+	var queue []int
+	if !recycled {
+		queue = append(queue, pid)
+	}
+	add := func(p procEntry) {
+		if !seen[p.PID] {
+			seen[p.PID] = true
+			tree = append(tree, p)
+			queue = append(queue, p.PID)
+		}
+	}
+	for _, p := range procs {
+		if !recycled && (p.PGID == pid || p.SID == pid) {
+			add(p)
+		}
+	}
+	for _, t := range tracked {
+		if p, ok := byPID[t.PID]; ok && p.sameProcess(t) {
+			add(p)
+		}
+	}
+	for len(queue) > 0 {
+		next := queue[0]
+		queue = queue[1:]
+		for _, child := range children[next] {
+			add(child)
+		}
+	}
+
+	live := tree[:0]
+	for _, p := range tree {
+		if p.State != 'Z' {
+			live = append(live, p)
+		}
+	}
+	sort.Slice(live, func(i, j int) bool { return live[i].PID < live[j].PID })
+	return live, recycled, nil
+}
+
+// signalProcess sends sig to p if its PID still belongs to the same process.
+func signalProcess(p procEntry, sig syscall.Signal) {
+	if current, err := readProcEntry(p.PID); err == nil && current.sameProcess(p) {
+		_ = syscall.Kill(p.PID, sig)
+	}
+}
+
+// SurvivorsError reports processes from a shell's tree that were still
+// running after the shell was terminated.
+type SurvivorsError struct {
+	ShellID  string
+	PIDs     []int
+	Commands []string // The command name of each surviving process, parallel to PIDs.
+}
+
+func (e *SurvivorsError) Error() string {
+	parts := make([]string, len(e.PIDs))
+	for i, pid := range e.PIDs {
+		parts[i] = fmt.Sprintf("%d (%s)", pid, e.Commands[i])
+	}
+	return fmt.Sprintf("processes of session %s survived termination: %s", e.ShellID, strings.Join(parts, ", "))
+}
+
+const (
+	// sweepInterval is how often killProcessTree rechecks the process table.
+	sweepInterval = 20 * time.Millisecond
+	// sweepGracePeriod is how long leftover processes get to exit after each signal.
+	sweepGracePeriod = time.Second
+)
+
+// killProcessTree terminates whatever remains of the shell rooted at root,
+// first with SIGTERM and then with SIGKILL, and returns a *SurvivorsError
+// if any process outlives both. Processes are only signalled while their
+// PID and start time still match, so a recycled PID is never hit.
+func (s *ShellSession) killProcessTree(root procEntry, tracked []procEntry, gracePeriod time.Duration) error {
+	for _, sig := range []syscall.Signal{syscall.SIGTERM, syscall.SIGKILL} {
+		procs, recycled, err := processTree(root, tracked)
+		if err != nil {
+			return fmt.Errorf("failed to read process tree of session %s: %w", s.ID, err)
+		}
+		if len(procs) == 0 {
+			return nil
+		}
+		tracked = append(tracked, procs...)
+
+		// Signal the group first so nothing can fork past the sweep, then
+		// every process individually to reach those that left the group.
+		if !recycled {
+			_ = syscall.Kill(-root.PID, sig)
+		}
+		for _, p := range procs {
+			signalProcess(p, sig)
+		}
+
+		deadline := time.Now().Add(gracePeriod)
+		for time.Now().Before(deadline) {
+			if procs, _, err := processTree(root, tracked); err == nil && len(procs) == 0 {
+				return nil
+			}
+			time.Sleep(sweepInterval)
+		}
+	}
+
+	procs, _, err := processTree(root, tracked)
+	if err != nil {
+		return fmt.Errorf("failed to read process tree of session %s: %w", s.ID, err)
+	}
+	if len(procs) == 0 {
+		return nil
+	}
+	survivors := &SurvivorsError{ShellID: s.ID}
+	for _, p := range procs {
+		survivors.PIDs = append(survivors.PIDs, p.PID)
+		survivors.Commands = append(survivors.Commands, p.Command)
+	}
+	return survivors
+}
@@ -0,0 +1,78 @@
+package shell_test
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/owen-6936/termplex/assert"
+	"github.com/owen-6936/termplex/shell"
+)
+
+func TestTerminateShellKillsOrphanedGrandchildren(t *testing.T) {
+	sm := shell.NewShellManager(nil)
+	t.Cleanup(func() { _ = sm.TerminateAllShells() })
+
+	session, err := sm.SpawnShell(false, "bash")
+	assert.NoError(t, err)
+
+	// 1. Start a grandchild whose parent exits immediately, so it is
+	// reparented away from the shell, plus an ordinary background job.
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	result, err := session.Run(ctx, "(sleep 300 & echo orphan:$!); sleep 300 & echo job:$!")
+	assert.NoError(t, err)
+	orphan := capturePID(t, result.Output, "orphan")
+	job := capturePID(t, result.Output, "job")
+	assert.True(t, procAlive(orphan) && procAlive(job), "Expected both processes to be running")
+
+	// 2. Terminating the shell takes the whole tree down with it.
+	assert.NoError(t, sm.TerminateShell(session.ID))
+	assert.True(t, !procAlive(orphan), "Orphaned grandchild %d survived", orphan)
+	assert.True(t, !procAlive(job), "Background job %d survived", job)
+}
+
+func TestTerminateInteractiveShellKillsBackgroundJobs(t *testing.T) {
+	sm := shell.NewShellManager(nil)
+	t.Cleanup(func() { _ = sm.TerminateAllShells() })
+
+	session, err := sm.SpawnShell(true, "bash", "--norc", "-i")
+	assert.NoError(t, err)
+
+	// 1. A job that ignores SIGHUP and SIGTERM and sits in its own process group.
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	assert.NoError(t, session.SendCommand("nohup sh -c 'trap \"\" TERM; sleep 300' >/dev/null 2>&1 & echo job:$!"))
+	match, err := session.Expect(ctx, regexp.MustCompile(`job:(\d+)`))
+	assert.NoError(t, err)
+	job, _ := strconv.Atoi(match.Groups[0])
+	assert.True(t, procAlive(job), "Expected the job to be running")
+
+	// 2. The sweep escalates to SIGKILL.
+	assert.NoError(t, sm.TerminateShell(session.ID))
+	assert.True(t, !procAlive(job), "Background job %d survived", job)
+}
+
+// capturePID extracts the PID printed as "<label>:<pid>".
+func capturePID(t *testing.T, output, label string) int {
+	t.Helper()
+	m := regexp.MustCompile(label + `:(\d+)`).FindStringSubmatch(output)
+	assert.True(t, m != nil, "No %s PID in output %q", label, output)
+	pid, _ := strconv.Atoi(m[1])
+	return pid
+}
+
+// procAlive reports whether pid is a running (non-zombie) process.
+func procAlive(pid int) bool {
+	stat, err := os.ReadFile(fmt.Sprintf("/proc/%d/stat", pid))
+	if err != nil {
+		return false
+	}
+	i := strings.LastIndexByte(string(stat), ')')
+	return i < 0 || i+2 >= len(stat) || stat[i+2] != 'Z'
+}
@@ -1,12 +1,20 @@
 package shell
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"io"
+	"log/slog"
+	"os"
+	"os/exec"
 	"strings"
+	"sync"
+	"syscall"
 	"time"
 
-	"github.com/google/uuid"
+	"github.com/creack/pty"
+	"github.com/owen-6936/termplex/screen"
 )
 
 // StartReading launches goroutines to read from a session's stdout and stderr.
@@ -15,26 +23,37 @@ func (s *ShellSession) StartReading(
 	stdoutHandler func(output []byte),
 	stderrHandler func(output []byte),
 ) {
+	var readers sync.WaitGroup
+	readDone := make(chan struct{})
+	s.mu.Lock()
+	s.readDone = readDone
+	s.stdoutHandler, s.stderrHandler = stdoutHandler, stderrHandler
+	// Capture the streams: a supervised shell swaps them when it restarts.
+	stdout, stderr := s.Stdout, s.Stderr
+	s.mu.Unlock()
+
 	// Goroutine for stdout
+	readers.Add(1)
 	go func() {
-		defer s.Stdout.Close()
+		defer readers.Done()
+		defer stdout.Close()
 		// In a PTY, stdout and stderr are the same file. The stderr handler
 		// will be called for all output in this case.
 		handler := stdoutHandler
-		if s.Stdout == s.Stderr {
+		if stdout == stderr {
 			handler = stderrHandler
 		}
 
 		for {
 			buf := make([]byte, 1024)
-			n, err := s.Stdout.Read(buf)
+			n, err := stdout.Read(buf)
 			if n > 0 {
 				handler(buf[:n])
 			}
 			if err != nil {
 				// Don't print an error if the file is intentionally closed.
 				if err != io.EOF && !strings.Contains(err.Error(), "file already closed") {
-					fmt.Printf("Error reading from session %s: %v\n", s.ID, err)
+					s.log().Error("failed to read output", "error", err)
 				}
 				return
 			}
@@ -42,120 +61,298 @@ func (s *ShellSession) StartReading(
 	}()
 
 	// Only start a separate stderr goroutine if it's a different pipe.
-	if s.Stderr != nil && s.Stderr != s.Stdout {
+	if stderr != nil && stderr != stdout {
+		readers.Add(1)
 		go func() {
-			defer s.Stderr.Close()
+			defer readers.Done()
+			defer stderr.Close()
 			for {
 				buf := make([]byte, 1024)
-				n, err := s.Stderr.Read(buf)
+				n, err := stderr.Read(buf)
 				if n > 0 {
 					stderrHandler(buf[:n])
 				}
 				if err != nil {
 					if err != io.EOF && !strings.Contains(err.Error(), "file already closed") {
-						fmt.Printf("Error reading stderr from session %s: %v\n", s.ID, err)
+						s.log().Error("failed to read stderr", "error", err)
 					}
 					return
 				}
 			}
 		}()
 	}
+
+	go func() {
+		readers.Wait()
+		close(readDone)
+	}()
 }
 
-// SendCommand writes a command string to the shell's standard input.
+// SendCommand writes a command string to the shell's standard input and
+// records it in the history under DefaultSender.
 func (s *ShellSession) SendCommand(command string) error {
-	if s.Stdin == nil {
-		return fmt.Errorf("session %s has no stdin", s.ID)
-	}
-	_, err := s.Stdin.Write([]byte(command + "\n"))
-	return err
+	return s.SendCommandAs(DefaultSender, command)
 }
 
-// SendCommandAndWait sends a command and blocks until a unique delimiter is found in the output.
+// SendCommandAndWait sends a command and blocks until it completes, returning
+// its output. It is a convenience wrapper around Run with a five-minute deadline;
+// a non-zero exit status or truncated output is reported as an error alongside
+// the output.
 func (s *ShellSession) SendCommandAndWait(command string) (string, error) {
-	s.mu.Lock()
-	s.OutputBuf.Reset()
-	s.mu.Unlock()
+	ctx, cancel := context.WithTimeout(context.Background(), 300*time.Second)
+	defer cancel()
 
-	delimiter := uuid.New().String()
-	// Use `echo -n` to prevent a trailing newline from the delimiter itself.
-	// Combine with the user's command using '&&' to run sequentially.
-	// This prevents an intermediate shell prompt from leaking into the output.
-	fullCommand := fmt.Sprintf("%s && echo -n %s", command, delimiter)
-
-	if err := s.SendCommand(fullCommand + "\n"); err != nil {
-		return "", fmt.Errorf("failed to write command to session %s: %w", s.ID, err)
-	}
-
-	timeout := time.After(300 * time.Second)
-	tick := time.NewTicker(100 * time.Millisecond)
-	defer tick.Stop()
-
-	for {
-		select {
-		case <-timeout:
-			return "", fmt.Errorf("timed out waiting for command delimiter in session %s", s.ID)
-		case <-tick.C:
-			s.mu.Lock()
-			output := s.OutputBuf.String()
-			s.mu.Unlock()
-
-			if strings.Contains(output, delimiter) {
-				// Split the output by the delimiter and return only what came before it.
-				// This cleanly separates the command's output from our delimiter.
-				content := strings.SplitN(output, delimiter, 2)[0]
-				return content, nil
-			}
+	result, err := s.Run(ctx, command)
+	if err != nil {
+		return "", err
+	}
+	if result.ExitCode != 0 {
+		return result.Output, fmt.Errorf("command in session %s exited with status %d", s.ID, result.ExitCode)
+	}
+	if result.Truncated {
+		return result.Output, fmt.Errorf("output of command in session %s exceeded the scrollback limits and was truncated", s.ID)
+	}
+	return result.Output, nil
+}
+
+// Resize changes the window size of the shell's PTY. The kernel delivers a
+// SIGWINCH to the foreground process group, so full-screen programs redraw.
+func (s *ShellSession) Resize(rows, cols uint16) error {
+	if s.pty == nil && s.screen == nil {
+		return fmt.Errorf("session %s is not attached to a PTY", s.ID)
+	}
+	if s.pty != nil {
+		if err := pty.Setsize(s.pty, &pty.Winsize{Rows: rows, Cols: cols}); err != nil {
+			return fmt.Errorf("failed to resize session %s: %w", s.ID, err)
 		}
 	}
+	if s.screen != nil {
+		s.screen.Resize(int(rows), int(cols))
+	}
+	s.tapResize(rows, cols)
+	return nil
+}
+
+// Size reports the current window size of the shell's PTY.
+func (s *ShellSession) Size() (WindowSize, error) {
+	if s.pty == nil && s.screen != nil {
+		rows, cols := s.screen.Size()
+		return WindowSize{Rows: uint16(rows), Cols: uint16(cols)}, nil
+	}
+	if s.pty == nil {
+		return WindowSize{}, fmt.Errorf("session %s is not attached to a PTY", s.ID)
+	}
+	rows, cols, err := pty.Getsize(s.pty)
+	if err != nil {
+		return WindowSize{}, fmt.Errorf("failed to read size of session %s: %w", s.ID, err)
+	}
+	return WindowSize{Rows: uint16(rows), Cols: uint16(cols)}, nil
+}
+
+// Screen returns a snapshot of what the shell's terminal is displaying right
+// now, as tracked by its built-in terminal emulator. Only PTY shells have a screen.
+func (s *ShellSession) Screen() (screen.Snapshot, error) {
+	if s.screen == nil {
+		return screen.Snapshot{}, fmt.Errorf("session %s is not attached to a PTY", s.ID)
+	}
+	return s.screen.Snapshot(), nil
 }
 
 // OutputHandler is a default handler that processes raw byte output from the shell.
-// It appends the output to the session's buffer and prints it to the console.
+// It appends the output to the session's buffer and copies it to the echo
+// writer, if one is set, after passing it through the session's filters.
 func (s *ShellSession) OutputHandler(output []byte) {
-	// Convert the raw bytes to a string for display
+	s.tapOutput(output, true)
+	s.filterOutput(output, false)
+}
+
+// ErrorOutputHandler is a handler that processes raw byte output from the shell's stderr.
+// It appends the output to the session's StderrBuf and copies it to the echo
+// writer, if one is set, after passing it through the session's filters. The
+// screen and raw taps, such as an attached terminal, see the raw output.
+func (s *ShellSession) ErrorOutputHandler(output []byte) {
 	s.mu.Lock()
-	s.OutputBuf.Write(output)
+	if s.screen != nil {
+		// On a PTY all output arrives here; keep the screen in step.
+		_, _ = s.screen.Write(output)
+	}
 	s.mu.Unlock()
 
-	// Print the output clearly labeled with the session ID
-	fmt.Print(string(output))
+	s.tapOutput(output, true)
+	s.filterOutput(output, true)
 }
 
-// ErrorOutputHandler is a handler that processes raw byte output from the shell's stderr.
-// It appends the output to the session's StderrBuf and prints it to the console as an error.
-func (s *ShellSession) ErrorOutputHandler(output []byte) {
+// deliver appends filtered output to a buffer and passes it on to the taps,
+// the echo writer, the sinks and the manager's subscribers.
+func (s *ShellSession) deliver(data []byte, isStderr bool) {
+	if len(data) == 0 {
+		return
+	}
+	s.mu.Lock()
+	if isStderr {
+		s.StderrBuf.Write(data)
+	} else {
+		s.OutputBuf.Write(data)
+	}
+	s.notifyOutputLocked()
+	echo, publish, sinks := s.echo, s.publish, s.sinks
+	s.mu.Unlock()
+
+	s.tapOutput(data, false)
+	if echo != nil {
+		_, _ = echo.Write(data)
+	}
+	s.writeSinks(sinks, data, isStderr)
+	if publish != nil {
+		publish(data, isStderr)
+	}
+}
+
+// SetEcho copies everything the shell prints from now on to w, such as
+// os.Stdout. A nil writer turns the echo off.
+func (s *ShellSession) SetEcho(w io.Writer) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.echo = w
+}
+
+// SetLogger sets the structured logger for the session. A nil logger
+// discards all records.
+func (s *ShellSession) SetLogger(logger *slog.Logger) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.logger = logger
+}
+
+// log returns the session's logger, or one that discards if none was set.
+func (s *ShellSession) log() *slog.Logger {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.logger == nil {
+		return discardLogger
+	}
+	return s.logger
+}
+
+// Done returns a channel that is closed once the shell process has exited.
+// The first call starts a reaper goroutine that owns the single Cmd.Wait call,
+// so a crashed process is noticed even if nobody ever calls Close.
+func (s *ShellSession) Done() <-chan struct{} {
 	s.mu.Lock()
-	s.StderrBuf.Write(output)
+	defer s.mu.Unlock()
+	if s.done == nil {
+		s.done = make(chan struct{})
+		if s.Cmd == nil || s.Cmd.Process == nil {
+			close(s.done) // Nothing was ever started.
+		} else {
+			go s.reap(s.Cmd, s.done)
+		}
+	}
+	return s.done
+}
+
+// reap waits for the process to exit and records its exit status.
+func (s *ShellSession) reap(cmd *exec.Cmd, done chan struct{}) {
+	err := cmd.Wait()
+
+	status := &ExitStatus{ShellID: s.ID, Code: -1, ExitedAt: time.Now()}
+	if state := cmd.ProcessState; state != nil {
+		status.Code = state.ExitCode()
+		if ws, ok := state.Sys().(syscall.WaitStatus); ok && ws.Signaled() {
+			status.Signaled = true
+			status.Signal = ws.Signal()
+		}
+	}
+
+	s.mu.Lock()
+	status.Restarts = s.restarts
+	s.exit = status
+	s.waitErr = err
 	s.mu.Unlock()
+	close(done)
+}
+
+// Wait blocks until the shell process exits or the context is done.
+// A non-zero exit code is not an error; inspect the returned ExitStatus.
+func (s *ShellSession) Wait(ctx context.Context) (ExitStatus, error) {
+	select {
+	case <-s.Done():
+		status, _ := s.ExitStatus()
+		return status, nil
+	case <-ctx.Done():
+		return ExitStatus{}, ctx.Err()
+	}
+}
 
-	// Print the error output clearly labeled with the session ID
-	fmt.Print(string(output))
+// ExitStatus returns the recorded exit status and whether the process has exited.
+func (s *ShellSession) ExitStatus() (ExitStatus, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.exit == nil {
+		return ExitStatus{}, false
+	}
+	return *s.exit, true
 }
 
-// Close gracefully terminates the shell session.
+// Close gracefully terminates the shell session. Closing stdin asks the
+// shell to exit; once the grace period expires its whole process group is
+// killed. Afterwards any remaining processes the shell started, including
+// background jobs and orphaned grandchildren, are terminated as well. If some
+// of them cannot be stopped, the returned error includes a *SurvivorsError.
 func (s *ShellSession) Close(gracePeriod time.Duration) error {
-	if s.Cmd == nil || s.Cmd.Process == nil {
+	// Stop supervision first, so the process is not restarted under us. After
+	// this the Cmd and streams no longer change.
+	s.mu.Lock()
+	if !s.closing {
+		s.closing = true
+		if s.stopRestart != nil {
+			close(s.stopRestart)
+		}
+	}
+	s.mu.Unlock()
+
+	proc, stdin := s.process()
+	if proc == nil {
 		return nil // Nothing to close
 	}
+	pid := proc.Pid
+
+	// Record the root and its tree while the shell is alive: once it exits,
+	// its children are reparented and can no longer be found by walking down
+	// from pid, and pid itself may be given to an unrelated process. A root
+	// read after the reap could be such a process, so it is only trusted if
+	// the shell had not exited yet.
+	root, err := readProcEntry(pid)
+	if _, exited := s.ExitStatus(); exited || err != nil {
+		root = procEntry{PID: pid}
+	}
+	tracked, _, _ := processTree(root, nil)
 
-	if s.Stdin != nil {
-		_ = s.Stdin.Close() // Signal process to exit
+	if stdin != nil {
+		_ = stdin.Close() // Signal process to exit
 	}
 
-	done := make(chan error, 1)
-	go func() { done <- s.Cmd.Wait() }()
+	done := s.Done()
 
 	select {
 	case <-time.After(gracePeriod):
-		// The grace period expired. Force-kill the process.
-		if err := s.Cmd.Process.Kill(); err != nil {
+		// The grace period expired. Force-kill the process and its group,
+		// unless it was reaped meanwhile and pid is no longer ours.
+		if _, exited := s.ExitStatus(); !exited {
+			_ = syscall.Kill(-pid, syscall.SIGKILL)
+		}
+		if err := proc.Kill(); err != nil && !errors.Is(err, os.ErrProcessDone) {
 			return fmt.Errorf("failed to kill process after timeout: %w", err)
 		}
-		// Wait for the original Wait() call to return with the kill error.
-		return <-done
-	case err := <-done:
+		// Wait for the reaper to record the kill.
+		<-done
+	case <-done:
 		// Process exited gracefully within the grace period.
-		return err
 	}
+
+	treeErr := s.killProcessTree(root, tracked, sweepGracePeriod)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return errors.Join(s.waitErr, treeErr)
 }
@@ -1,6 +1,8 @@
 package shell_test
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"os/exec"
 	"testing"
@@ -137,3 +139,82 @@ func TestShellCloseWithFallback(t *testing.T) {
 
 	t.Logf("Successfully triggered fallback termination for shell %s after %v", session.ID, duration)
 }
+
+func TestRunReportsExitCodeOfFailingCommand(t *testing.T) {
+	session := newTestShell(t, "bash", "-i")
+	time.Sleep(200 * time.Millisecond)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	// A failing command must still complete, carrying its exit status.
+	result, err := session.Run(ctx, "echo 'about to fail'; false")
+	assert.NoError(t, err)
+	assert.True(t, result.ExitCode == 1, "Expected exit code 1, got %d", result.ExitCode)
+	assert.Contains(t, result.Output, "about to fail")
+	assert.True(t, result.Duration > 0, "Expected a positive duration")
+
+	// The session remains usable for the next command.
+	result, err = session.Run(ctx, "echo -n 'still alive'")
+	assert.NoError(t, err)
+	assert.True(t, result.ExitCode == 0, "Expected exit code 0, got %d", result.ExitCode)
+	assert.True(t, result.Output == "still alive", "Expected exactly 'still alive', got %q", result.Output)
+}
+
+func TestRunHonorsContextDeadline(t *testing.T) {
+	session := newTestShell(t, "bash", "-i")
+	time.Sleep(200 * time.Millisecond)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 300*time.Millisecond)
+	defer cancel()
+
+	startTime := time.Now()
+	_, err := session.Run(ctx, "sleep 5")
+	assert.True(t, errors.Is(err, context.DeadlineExceeded), "Expected a deadline error, got %v", err)
+	assert.True(t, time.Since(startTime) < 2*time.Second, "Run did not return promptly after the deadline")
+}
+
+func TestRunAcceptsCommentsBackgroundJobsAndMultipleLines(t *testing.T) {
+	session := newTestShell(t, "bash")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	// 1. A trailing comment must not swallow the end marker.
+	result, err := session.Run(ctx, "echo hi # note")
+	assert.NoError(t, err)
+	assert.True(t, result.Output == "hi\n", "Expected 'hi', got %q", result.Output)
+
+	// 2. A trailing & must not produce a syntax error that kills the shell.
+	result, err = session.Run(ctx, "sleep 0.1 &")
+	assert.NoError(t, err)
+	assert.True(t, result.ExitCode == 0, "Expected exit code 0, got %d", result.ExitCode)
+
+	// 3. A multi-line command runs as a whole and reports its last status.
+	result, err = session.Run(ctx, "echo one\necho two\nfalse")
+	assert.NoError(t, err)
+	assert.True(t, result.Output == "one\ntwo\n", "Unexpected output %q", result.Output)
+	assert.True(t, result.ExitCode == 1, "Expected exit code 1, got %d", result.ExitCode)
+
+	// 4. The shell is still alive afterwards.
+	result, err = session.Run(ctx, "echo -n 'still alive'")
+	assert.NoError(t, err)
+	assert.True(t, result.Output == "still alive", "Expected 'still alive', got %q", result.Output)
+}
+
+func TestRunAcceptsMultipleLinesOnPTY(t *testing.T) {
+	sm := shell.NewShellManager(nil)
+	t.Cleanup(func() { _ = sm.TerminateAllShells() })
+	session, err := sm.SpawnShell(true, "bash", "--norc", "-i")
+	assert.NoError(t, err)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	// The shell parses the whole block before running it, so no prompts
+	// are printed between the lines' output.
+	result, err := session.Run(ctx, "echo one # first\necho two")
+	assert.NoError(t, err)
+	assert.True(t, result.Output == "one\r\ntwo\r\n", "Unexpected output %q", result.Output)
+	assert.True(t, result.ExitCode == 0, "Expected exit code 0, got %d", result.ExitCode)
+}
@@ -0,0 +1,52 @@
+package shell
+
+import (
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/owen-6936/termplex/screen"
+)
+
+// ReplayFeed supplies the output of a session created by NewReplaySession.
+type ReplayFeed struct {
+	s         *ShellSession
+	closeOnce sync.Once
+	readDone  chan struct{}
+}
+
+// NewReplaySession creates a terminal session with no process behind it.
+// Everything written to the returned feed is handled as if a PTY shell had
+// printed it, so Expect, Scrollback and Screen behave as they would against a
+// real shell. It is meant for tests and for replaying recordings.
+func NewReplaySession(size WindowSize) (*ShellSession, *ReplayFeed) {
+	if size.IsZero() {
+		size = DefaultWindowSize
+	}
+	readDone := make(chan struct{})
+	s := &ShellSession{
+		ID:          uuid.New().String(),
+		StartedAt:   time.Now(),
+		Interactive: true,
+		screen:      screen.New(int(size.Rows), int(size.Cols)),
+		readDone:    readDone,
+	}
+	return s, &ReplayFeed{s: s, readDone: readDone}
+}
+
+// Write delivers p to the session as terminal output.
+func (f *ReplayFeed) Write(p []byte) (int, error) {
+	f.s.ErrorOutputHandler(p)
+	return len(p), nil
+}
+
+// Resize changes the size of the session's screen.
+func (f *ReplayFeed) Resize(rows, cols uint16) error {
+	return f.s.Resize(rows, cols)
+}
+
+// Close ends the session's output, so pending Expect calls report io.EOF.
+func (f *ReplayFeed) Close() error {
+	f.closeOnce.Do(func() { close(f.readDone) })
+	return nil
+}
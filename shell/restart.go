@@ -0,0 +1,213 @@
+package shell
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"time"
+)
+
+// RestartPolicy decides whether a supervised shell is restarted after its
+// process exits.
+type RestartPolicy string
+
+const (
+	RestartNever     RestartPolicy = "never"      // Leave the shell stopped. The default.
+	RestartOnFailure RestartPolicy = "on-failure" // Restart after a non-zero exit or a fatal signal.
+	RestartAlways    RestartPolicy = "always"     // Restart whenever the process exits.
+)
+
+// ParseRestartPolicy validates a policy name, as written in a manifest. An
+// empty name means RestartNever.
+func ParseRestartPolicy(name string) (RestartPolicy, error) {
+	switch p := RestartPolicy(name); p {
+	case "":
+		return RestartNever, nil
+	case RestartNever, RestartOnFailure, RestartAlways:
+		return p, nil
+	}
+	return "", fmt.Errorf("unknown restart policy %q", name)
+}
+
+// RestartOptions configures supervision of a non-interactive shell.
+type RestartOptions struct {
+	Policy RestartPolicy
+	// InitialBackoff is the delay before the first restart; it doubles with
+	// every consecutive crash. Defaults to one second.
+	InitialBackoff time.Duration
+	// MaxBackoff caps the delay. A process that stays up at least this long
+	// resets the backoff. Defaults to 30 seconds.
+	MaxBackoff time.Duration
+	// MaxRestarts is the number of restarts allowed within Window before the
+	// supervisor gives up; 0 means unlimited.
+	MaxRestarts int
+	// Window is the sliding period MaxRestarts applies to; 0 counts every
+	// restart over the shell's lifetime.
+	Window time.Duration
+}
+
+// withDefaults fills in the default backoff bounds.
+func (o RestartOptions) withDefaults() RestartOptions {
+	if o.InitialBackoff <= 0 {
+		o.InitialBackoff = time.Second
+	}
+	if o.MaxBackoff <= 0 {
+		o.MaxBackoff = 30 * time.Second
+	}
+	if o.MaxBackoff < o.InitialBackoff {
+		o.MaxBackoff = o.InitialBackoff
+	}
+	return o
+}
+
+// shouldRestart applies the policy to an exit.
+func (o RestartOptions) shouldRestart(status ExitStatus) bool {
+	switch o.Policy {
+	case RestartAlways:
+		return true
+	case RestartOnFailure:
+		return status.Code != 0 || status.Signaled
+	}
+	return false
+}
+
+// errClosing is returned by respawn once the shell is being closed.
+var errClosing = errors.New("shell is closing")
+
+// Restarts returns how many times the supervisor has restarted the shell.
+func (s *ShellSession) Restarts() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.restarts
+}
+
+// process returns the current process and stdin, which change when a
+// supervised shell restarts.
+func (s *ShellSession) process() (*os.Process, io.WriteCloser) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.Cmd == nil {
+		return nil, s.Stdin
+	}
+	return s.Cmd.Process, s.Stdin
+}
+
+// restartAfter applies the shell's restart policy to an exit. It waits out
+// the backoff and starts a new process, returning true once one is running.
+// It returns false if the policy, the restart limit, or a Close says the
+// shell should stay stopped.
+func (sm *ShellManager) restartAfter(s *ShellSession, status ExitStatus) bool {
+	if s.restart == nil || !s.restart.shouldRestart(status) {
+		return false
+	}
+	opts := *s.restart
+
+	for {
+		delay, ok := s.nextRestartDelay(opts, status.ExitedAt)
+		if !ok {
+			return false
+		}
+		s.log().Info("restarting shell", "delay", delay, "restarts", s.Restarts())
+
+		select {
+		case <-time.After(delay):
+		case <-s.stopRestart:
+			return false
+		case <-sm.closeChan:
+			return false
+		}
+
+		err := s.respawn()
+		if err == nil {
+			return true
+		}
+		if errors.Is(err, errClosing) {
+			return false
+		}
+		// A process that cannot even start counts as another crash.
+		s.log().Error("failed to restart shell", "error", err)
+		status.ExitedAt = time.Now()
+	}
+}
+
+// nextRestartDelay records a restart attempt and returns the backoff before
+// it, or false if the shell is closing or has used up its restarts.
+func (s *ShellSession) nextRestartDelay(opts RestartOptions, exitedAt time.Time) (time.Duration, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closing {
+		return 0, false
+	}
+
+	now := time.Now()
+	if opts.Window > 0 {
+		recent := s.restartTimes[:0]
+		for _, t := range s.restartTimes {
+			if now.Sub(t) < opts.Window {
+				recent = append(recent, t)
+			}
+		}
+		s.restartTimes = recent
+	}
+	if opts.MaxRestarts > 0 && len(s.restartTimes) >= opts.MaxRestarts {
+		logger := s.logger
+		if logger == nil {
+			logger = discardLogger
+		}
+		logger.Warn("restart limit reached", "restarts", len(s.restartTimes), "window", opts.Window)
+		return 0, false
+	}
+	s.restartTimes = append(s.restartTimes, now)
+
+	// A process that stayed up for a while was healthy; start the backoff over.
+	if exitedAt.Sub(s.processStartedAt) >= opts.MaxBackoff {
+		s.crashes = 0
+	}
+	delay := opts.InitialBackoff
+	for i := 0; i < s.crashes && delay < opts.MaxBackoff; i++ {
+		delay *= 2
+	}
+	s.crashes++
+	return min(delay, opts.MaxBackoff), true
+}
+
+// respawn starts a fresh process with the same command line, directory and
+// environment, and resumes reading its output through the same handlers, so
+// consumers keep receiving output under the same shell ID.
+func (s *ShellSession) respawn() error {
+	s.mu.Lock()
+	if s.closing {
+		s.mu.Unlock()
+		return errClosing
+	}
+	old := s.Cmd
+	cmd := &exec.Cmd{Path: old.Path, Args: old.Args, Dir: old.Dir, Env: old.Env}
+	var stdio io.ReadWriteCloser
+	var stderr io.ReadCloser
+	err := startConfined(cmd, s.limits, s.cgroup, func() error {
+		var err error
+		stdio, stderr, err = startWithPipes(cmd)
+		return err
+	})
+	if err != nil {
+		if stdio != nil {
+			_ = stdio.Close()
+			_ = stderr.Close()
+		}
+		s.mu.Unlock()
+		return err
+	}
+
+	s.Cmd = cmd
+	s.Stdin, s.Stdout, s.Stderr = stdio, stdio, stderr
+	s.done, s.exit, s.waitErr = nil, nil, nil
+	s.processStartedAt = time.Now()
+	s.restarts++
+	stdoutHandler, stderrHandler := s.stdoutHandler, s.stderrHandler
+	s.mu.Unlock()
+
+	s.StartReading(stdoutHandler, stderrHandler)
+	return nil
+}
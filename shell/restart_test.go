@@ -0,0 +1,141 @@
+package shell_test
+
+import (
+	"context"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/owen-6936/termplex/assert"
+	"github.com/owen-6936/termplex/shell"
+)
+
+func TestOnFailureRestartsWithBackoffUntilLimit(t *testing.T) {
+	sm := shell.NewShellManager(nil)
+	t.Cleanup(func() { _ = sm.TerminateAllShells() })
+
+	// A worker that prints a line and crashes every time.
+	session, err := sm.SpawnShellWithOptions(shell.SpawnOptions{
+		Command: []string{"sh", "-c", "echo run-$$; exit 3"},
+		Restart: &shell.RestartOptions{
+			Policy:         shell.RestartOnFailure,
+			InitialBackoff: 20 * time.Millisecond,
+			MaxBackoff:     80 * time.Millisecond,
+			MaxRestarts:    2,
+			Window:         time.Minute,
+		},
+	})
+	assert.NoError(t, err)
+
+	// 1. The original run plus two restarts are reported, then supervision stops.
+	for i := range 3 {
+		select {
+		case status := <-sm.ExitChan:
+			assert.True(t, status.ShellID == session.ID && status.Code == 3, "Unexpected exit %+v", status)
+			assert.True(t, status.Restarts == i, "Expected exit %d to follow %d restarts, got %d", i, i, status.Restarts)
+		case <-time.After(3 * time.Second):
+			t.Fatalf("Timed out waiting for exit %d", i)
+		}
+	}
+	select {
+	case status := <-sm.ExitChan:
+		t.Fatalf("Expected no restart past the limit, got %+v", status)
+	case <-time.After(300 * time.Millisecond):
+	}
+	assert.True(t, session.Restarts() == 2, "Expected 2 restarts, got %d", session.Restarts())
+
+	// 2. Every run's output landed in the same shell's scrollback.
+	runs := regexp.MustCompile(`run-\d+`).FindAllString(session.Scrollback().String(), -1)
+	assert.True(t, len(runs) == 3 && runs[0] != runs[1], "Expected output from 3 distinct processes, got %q", runs)
+}
+
+func TestAlwaysRestartsCleanExitsAndStopsOnTerminate(t *testing.T) {
+	sm := shell.NewShellManager(nil)
+	t.Cleanup(func() { _ = sm.TerminateAllShells() })
+
+	session, err := sm.SpawnShellWithOptions(shell.SpawnOptions{
+		Command: []string{"bash"},
+		Restart: &shell.RestartOptions{Policy: shell.RestartAlways, InitialBackoff: 10 * time.Millisecond},
+	})
+	assert.NoError(t, err)
+	firstPID := session.Cmd.Process.Pid
+
+	// 1. A clean exit still restarts the shell, which accepts commands again.
+	assert.NoError(t, session.SendCommand("exit 0"))
+	select {
+	case status := <-sm.ExitChan:
+		assert.True(t, status.Code == 0, "Unexpected exit %+v", status)
+	case <-time.After(3 * time.Second):
+		t.Fatal("Timed out waiting for the clean exit")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	var result *shell.RunResult
+	for {
+		// The restart happens in the background; retry until the new process answers.
+		if result, err = session.Run(ctx, "echo pid-$$"); err == nil || ctx.Err() != nil {
+			break
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	assert.NoError(t, err)
+	assert.True(t, !strings.Contains(result.Output, "pid-"+strconv.Itoa(firstPID)), "Expected a new process, got %q", result.Output)
+	assert.True(t, session.Restarts() == 1, "Expected 1 restart, got %d", session.Restarts())
+
+	// 2. Terminating the shell is final.
+	assert.NoError(t, sm.TerminateShell(session.ID))
+	time.Sleep(100 * time.Millisecond)
+	assert.True(t, session.Restarts() == 1, "Expected no restart after termination, got %d", session.Restarts())
+}
+
+func TestRestartPolicyValidation(t *testing.T) {
+	sm := shell.NewShellManager(nil)
+	t.Cleanup(func() { _ = sm.TerminateAllShells() })
+
+	_, err := sm.SpawnShellWithOptions(shell.SpawnOptions{
+		Interactive: true,
+		Command:     []string{"bash", "--norc", "-i"},
+		Restart:     &shell.RestartOptions{Policy: shell.RestartAlways},
+	})
+	assert.True(t, err != nil, "Expected interactive shells to reject a restart policy")
+
+	policy, err := shell.ParseRestartPolicy("on-failure")
+	assert.NoError(t, err)
+	assert.True(t, policy == shell.RestartOnFailure, "Unexpected policy %q", policy)
+	_, err = shell.ParseRestartPolicy("sometimes")
+	assert.True(t, err != nil, "Expected an unknown policy to be rejected")
+}
+
+func TestRestartsDoNotWaitForExitChanReaders(t *testing.T) {
+	sm := shell.NewShellManager(nil)
+	t.Cleanup(func() { _ = sm.TerminateAllShells() })
+
+	// Nobody reads ExitChan, and it is already full.
+	for len(sm.ExitChan) < cap(sm.ExitChan) {
+		sm.ExitChan <- shell.ExitStatus{ShellID: "stale"}
+	}
+	session, err := sm.SpawnShellWithOptions(shell.SpawnOptions{
+		Command: []string{"sh", "-c", "exit 3"},
+		Restart: &shell.RestartOptions{Policy: shell.RestartOnFailure, InitialBackoff: 10 * time.Millisecond, MaxRestarts: 2},
+	})
+	assert.NoError(t, err)
+	// A discarding logger must survive reaching the restart limit.
+	session.SetLogger(nil)
+
+	// 1. Supervision carries on up to the limit regardless.
+	assert.True(t, waitFor(5*time.Second, func() bool { return session.Restarts() == 2 }), "Expected 2 restarts, got %d", session.Restarts())
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	_, err = session.Wait(ctx)
+	assert.NoError(t, err)
+
+	// 2. The oldest notifications made room for the newest.
+	var last shell.ExitStatus
+	for len(sm.ExitChan) > 0 {
+		last = <-sm.ExitChan
+	}
+	assert.True(t, last.ShellID == session.ID && last.Restarts == 2, "Expected the final exit last, got %+v", last)
+}
@@ -0,0 +1,129 @@
+package shell
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	beginMarker = "__termplex_begin"
+	endMarker   = "__termplex_end"
+)
+
+// Run sends a command to the shell and blocks until it completes or the
+// context is done. The session's dialect brackets the command with begin/end
+// markers, and the end marker always prints, carrying the exit status, so a
+// failing command returns promptly with its exit code instead of hanging.
+// If the command prints more than the scrollback retains, the head of its
+// output is lost and the result is marked Truncated.
+func (s *ShellSession) Run(ctx context.Context, command string) (*RunResult, error) {
+	id := uuid.New().String()
+	begin := []byte(beginMarker + ":" + id)
+	end := regexp.MustCompile(endMarker + ":" + id + `:(-?\d+)`)
+	closed := s.outputDone()
+
+	// Only output produced after this point belongs to the command.
+	start := s.Scrollback().Total()
+
+	seq := s.recordCommand(senderFrom(ctx), command)
+	sentAt := time.Now()
+	if err := s.writeInput([]byte(s.Dialect().WrapCommand(command, string(begin), endMarker+":"+id) + "\n")); err != nil {
+		return nil, fmt.Errorf("failed to write command to session %s: %w", s.ID, err)
+	}
+
+	for {
+		s.mu.Lock()
+		output, from := s.primaryBufLocked().Since(start)
+		changed := s.outputChangedLocked()
+		s.mu.Unlock()
+
+		if m := end.FindSubmatchIndex(output); m != nil {
+			code, _ := strconv.Atoi(string(output[m[2]:m[3]]))
+			s.completeCommand(seq, code)
+			return &RunResult{
+				Output:    extractCommandOutput(output[:m[0]], begin),
+				ExitCode:  code,
+				Duration:  time.Since(sentAt),
+				Truncated: from > start,
+			}, nil
+		}
+
+		select {
+		case <-changed:
+		case <-closed:
+			return nil, fmt.Errorf("session %s closed its output before the command completed: %w", s.ID, io.EOF)
+		case <-ctx.Done():
+			return nil, fmt.Errorf("waiting for command in session %s: %w", s.ID, ctx.Err())
+		}
+	}
+}
+
+// extractCommandOutput returns the text between the begin marker's line and
+// the end of the given output. If the begin marker is missing, everything is kept.
+func extractCommandOutput(output, begin []byte) string {
+	if i := bytes.Index(output, begin); i >= 0 {
+		output = output[i+len(begin):]
+		output = bytes.TrimPrefix(output, []byte("\r"))
+		output = bytes.TrimPrefix(output, []byte("\n"))
+	}
+	return string(output)
+}
+
+// Scrollback returns the buffer that receives the shell's main output.
+// On a PTY, stdout and stderr are the same stream and land in StderrBuf.
+func (s *ShellSession) Scrollback() *Scrollback {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.primaryBufLocked()
+}
+
+// SetScrollbackLimits bounds both of the session's output buffers.
+func (s *ShellSession) SetScrollbackLimits(limits ScrollbackLimits) {
+	s.OutputBuf.SetLimits(limits)
+	s.StderrBuf.SetLimits(limits)
+}
+
+func (s *ShellSession) primaryBufLocked() *Scrollback {
+	// Terminal sessions, whether on a PTY or replayed, deliver everything as one stream.
+	if s.screen != nil || (s.Stdout != nil && s.Stdout == s.Stderr) {
+		return &s.StderrBuf
+	}
+	return &s.OutputBuf
+}
+
+// outputDone returns a channel that is closed once the session's readers have
+// drained their streams. Sessions that were never started report the process
+// exit instead.
+func (s *ShellSession) outputDone() <-chan struct{} {
+	s.mu.Lock()
+	readDone := s.readDone
+	s.mu.Unlock()
+	if readDone == nil {
+		return s.Done()
+	}
+	return readDone
+}
+
+// outputChangedLocked returns a channel that is closed the next time output
+// is buffered, letting waiters block instead of polling.
+func (s *ShellSession) outputChangedLocked() <-chan struct{} {
+	if s.changed == nil {
+		s.changed = make(chan struct{})
+	}
+	return s.changed
+}
+
+// notifyOutputLocked wakes every goroutine waiting on outputChangedLocked.
+func (s *ShellSession) notifyOutputLocked() {
+	if s.changed != nil {
+		close(s.changed)
+		s.changed = nil
+	}
+}
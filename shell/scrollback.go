@@ -0,0 +1,233 @@
+package shell
+
+import (
+	"bytes"
+	"sort"
+	"sync"
+)
+
+// ScrollbackLimits bounds how much output a Scrollback retains. A zero field
+// means that dimension is unlimited.
+type ScrollbackLimits struct {
+	MaxLines int // Maximum number of lines retained, counting a trailing partial line.
+	MaxBytes int // Maximum number of bytes retained.
+}
+
+// DefaultScrollbackLimits applies to any Scrollback whose limits were never set.
+var DefaultScrollbackLimits = ScrollbackLimits{MaxLines: 10000, MaxBytes: 8 << 20}
+
+// Scrollback is a bounded, line-indexed output buffer. Once a limit is
+// exceeded, the oldest lines are evicted. Lines are numbered from the first
+// line ever written and byte offsets count every byte ever written, so both
+// stay stable as old output is dropped. The zero value is ready to use with
+// DefaultScrollbackLimits.
+type Scrollback struct {
+	mu         sync.Mutex
+	limits     ScrollbackLimits
+	configured bool         // Whether SetLimits has been called.
+	lines      []scrollLine // Retained lines, oldest first; only the last may be partial.
+	firstLine  int64        // Absolute index of lines[0].
+	start      int64        // Absolute byte offset of the first retained byte.
+	size       int          // Number of retained bytes.
+}
+
+// scrollLine is one line of output, including its trailing newline if complete.
+type scrollLine struct {
+	offset int64 // Absolute byte offset of data[0].
+	data   []byte
+}
+
+// SetLimits changes the retention limits, evicting old output immediately if needed.
+func (sb *Scrollback) SetLimits(limits ScrollbackLimits) {
+	sb.mu.Lock()
+	defer sb.mu.Unlock()
+	sb.limits = limits
+	sb.configured = true
+	sb.trimLocked()
+}
+
+// Limits returns the retention limits in effect.
+func (sb *Scrollback) Limits() ScrollbackLimits {
+	sb.mu.Lock()
+	defer sb.mu.Unlock()
+	return sb.limitsLocked()
+}
+
+func (sb *Scrollback) limitsLocked() ScrollbackLimits {
+	if !sb.configured {
+		return DefaultScrollbackLimits
+	}
+	return sb.limits
+}
+
+// Write appends output to the scrollback. It never fails.
+func (sb *Scrollback) Write(p []byte) (int, error) {
+	sb.mu.Lock()
+	defer sb.mu.Unlock()
+
+	end := sb.start + int64(sb.size)
+	rest := p
+	for len(rest) > 0 {
+		chunk := rest
+		if i := bytes.IndexByte(rest, '\n'); i >= 0 {
+			chunk = rest[:i+1]
+		}
+		rest = rest[len(chunk):]
+
+		if n := len(sb.lines); n > 0 && !isComplete(sb.lines[n-1].data) {
+			sb.lines[n-1].data = append(sb.lines[n-1].data, chunk...)
+		} else {
+			sb.lines = append(sb.lines, scrollLine{offset: end, data: bytes.Clone(chunk)})
+		}
+		end += int64(len(chunk))
+		sb.size += len(chunk)
+	}
+	sb.trimLocked()
+	return len(p), nil
+}
+
+// trimLocked evicts the oldest output until the scrollback is within its limits.
+func (sb *Scrollback) trimLocked() {
+	limits := sb.limitsLocked()
+	drop := 0
+	for drop < len(sb.lines)-1 &&
+		((limits.MaxLines > 0 && len(sb.lines)-drop > limits.MaxLines) ||
+			(limits.MaxBytes > 0 && sb.size > limits.MaxBytes)) {
+		sb.size -= len(sb.lines[drop].data)
+		sb.start += int64(len(sb.lines[drop].data))
+		drop++
+	}
+	if drop > 0 {
+		// Clear the evicted slots so their memory can be reclaimed.
+		clear(sb.lines[:drop])
+		sb.lines = sb.lines[drop:]
+		sb.firstLine += int64(drop)
+	}
+
+	// A single line longer than the byte limit keeps only its newest bytes.
+	if limits.MaxBytes > 0 && sb.size > limits.MaxBytes && len(sb.lines) == 1 {
+		excess := sb.size - limits.MaxBytes
+		line := &sb.lines[0]
+		line.data = bytes.Clone(line.data[excess:])
+		line.offset += int64(excess)
+		sb.start += int64(excess)
+		sb.size -= excess
+	}
+
+	// Compact the backing array once most of it is evicted slots.
+	if cap(sb.lines) > 64 && len(sb.lines) < cap(sb.lines)/4 {
+		sb.lines = append([]scrollLine(nil), sb.lines...)
+	}
+}
+
+// Len returns the number of bytes currently retained.
+func (sb *Scrollback) Len() int {
+	sb.mu.Lock()
+	defer sb.mu.Unlock()
+	return sb.size
+}
+
+// Total returns the number of bytes ever written, which is also the absolute
+// offset at which the next write will start.
+func (sb *Scrollback) Total() int64 {
+	sb.mu.Lock()
+	defer sb.mu.Unlock()
+	return sb.start + int64(sb.size)
+}
+
+// Bytes returns a copy of all retained output.
+func (sb *Scrollback) Bytes() []byte {
+	data, _ := sb.Since(0)
+	return data
+}
+
+// String returns all retained output as a string.
+func (sb *Scrollback) String() string {
+	return string(sb.Bytes())
+}
+
+// Since returns a copy of the retained output from the absolute byte offset
+// onwards, along with the offset the returned data actually starts at. If the
+// requested offset has already been evicted, the data starts at the oldest
+// retained byte instead.
+func (sb *Scrollback) Since(offset int64) ([]byte, int64) {
+	sb.mu.Lock()
+	defer sb.mu.Unlock()
+
+	end := sb.start + int64(sb.size)
+	offset = max(offset, sb.start)
+	if offset >= end {
+		return nil, end
+	}
+
+	// Find the line containing the offset.
+	i := sort.Search(len(sb.lines), func(i int) bool {
+		line := sb.lines[i]
+		return line.offset+int64(len(line.data)) > offset
+	})
+	out := make([]byte, 0, end-offset)
+	out = append(out, sb.lines[i].data[offset-sb.lines[i].offset:]...)
+	for _, line := range sb.lines[i+1:] {
+		out = append(out, line.data...)
+	}
+	return out, offset
+}
+
+// Reset discards all retained output. Offsets and line numbers keep counting
+// from where they were.
+func (sb *Scrollback) Reset() {
+	sb.mu.Lock()
+	defer sb.mu.Unlock()
+	sb.start += int64(sb.size)
+	sb.firstLine += int64(len(sb.lines))
+	sb.size = 0
+	sb.lines = nil
+}
+
+// LineCount returns the number of lines currently retained, including a
+// trailing partial line.
+func (sb *Scrollback) LineCount() int {
+	sb.mu.Lock()
+	defer sb.mu.Unlock()
+	return len(sb.lines)
+}
+
+// FirstLine returns the absolute index of the oldest retained line.
+func (sb *Scrollback) FirstLine() int64 {
+	sb.mu.Lock()
+	defer sb.mu.Unlock()
+	return sb.firstLine
+}
+
+// Tail returns up to the last n retained lines, without their trailing newlines.
+func (sb *Scrollback) Tail(n int) []string {
+	sb.mu.Lock()
+	defer sb.mu.Unlock()
+	n = min(max(n, 0), len(sb.lines))
+	return linesText(sb.lines[len(sb.lines)-n:])
+}
+
+// Lines returns the retained lines with absolute indices in [from, to),
+// without their trailing newlines. Evicted or unwritten lines are skipped.
+func (sb *Scrollback) Lines(from, to int64) []string {
+	sb.mu.Lock()
+	defer sb.mu.Unlock()
+	lo := min(max(from-sb.firstLine, 0), int64(len(sb.lines)))
+	hi := min(max(to-sb.firstLine, lo), int64(len(sb.lines)))
+	return linesText(sb.lines[lo:hi])
+}
+
+// linesText converts lines to strings, stripping line terminators.
+func linesText(lines []scrollLine) []string {
+	out := make([]string, len(lines))
+	for i, line := range lines {
+		text := bytes.TrimSuffix(line.data, []byte("\n"))
+		out[i] = string(bytes.TrimSuffix(text, []byte("\r")))
+	}
+	return out
+}
+
+// isComplete reports whether a line ends with a newline.
+func isComplete(line []byte) bool {
+	return len(line) > 0 && line[len(line)-1] == '\n'
+}
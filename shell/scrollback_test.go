@@ -0,0 +1,106 @@
+package shell_test
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/owen-6936/termplex/assert"
+	"github.com/owen-6936/termplex/shell"
+)
+
+func TestScrollbackEvictsByLines(t *testing.T) {
+	var sb shell.Scrollback
+	sb.SetLimits(shell.ScrollbackLimits{MaxLines: 3})
+
+	// Write five lines, split awkwardly across chunks.
+	_, _ = sb.Write([]byte("line 0\nline 1\nli"))
+	_, _ = sb.Write([]byte("ne 2\nline 3\nline 4\n"))
+
+	assert.True(t, sb.LineCount() == 3, "Expected 3 retained lines, got %d", sb.LineCount())
+	assert.True(t, sb.FirstLine() == 2, "Expected the oldest retained line to be 2, got %d", sb.FirstLine())
+	assert.True(t, sb.String() == "line 2\nline 3\nline 4\n", "Unexpected retained output %q", sb.String())
+
+	// Line ranges use absolute indices, so evicted lines are simply skipped.
+	assert.True(t, fmt.Sprint(sb.Lines(0, 4)) == "[line 2 line 3]", "Unexpected range %q", sb.Lines(0, 4))
+	assert.True(t, fmt.Sprint(sb.Tail(2)) == "[line 3 line 4]", "Unexpected tail %q", sb.Tail(2))
+}
+
+func TestScrollbackEvictsByBytesAndKeepsOffsets(t *testing.T) {
+	var sb shell.Scrollback
+	sb.SetLimits(shell.ScrollbackLimits{MaxBytes: 16})
+
+	_, _ = sb.Write([]byte("aaaaaaa\nbbbbbbb\n")) // 16 bytes: fits exactly.
+	_, _ = sb.Write([]byte("ccccccc\n"))          // Evicts the first line.
+
+	assert.True(t, sb.Len() == 16, "Expected 16 retained bytes, got %d", sb.Len())
+	assert.True(t, sb.Total() == 24, "Expected 24 total bytes, got %d", sb.Total())
+
+	// Reading from an absolute offset works across evictions.
+	data, start := sb.Since(12)
+	assert.True(t, start == 12 && string(data) == "bbb\nccccccc\n", "Unexpected data %q at %d", data, start)
+
+	// An evicted offset is clamped to the oldest retained byte.
+	data, start = sb.Since(0)
+	assert.True(t, start == 8 && string(data) == "bbbbbbb\nccccccc\n", "Unexpected data %q at %d", data, start)
+
+	// A single line that never ends is still bounded.
+	_, _ = sb.Write([]byte(strings.Repeat("x", 100)))
+	assert.True(t, sb.Len() == 16, "Expected a runaway line to be bounded to 16 bytes, got %d", sb.Len())
+}
+
+func TestShellManagerAppliesScrollbackLimits(t *testing.T) {
+	sm := shell.NewShellManager(nil)
+	t.Cleanup(func() { _ = sm.TerminateAllShells() })
+	sm.SetScrollbackLimits(shell.ScrollbackLimits{MaxLines: 10})
+
+	session, err := sm.SpawnShell(false, "bash", "-c", "for i in $(seq 1 1000); do echo \"log line $i\"; done")
+	assert.NoError(t, err)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	_, err = session.Wait(ctx)
+	assert.NoError(t, err)
+	time.Sleep(100 * time.Millisecond) // Let the reader drain the pipe.
+
+	assert.True(t, session.OutputBuf.LineCount() == 10, "Expected 10 retained lines, got %d", session.OutputBuf.LineCount())
+	tail := session.OutputBuf.Tail(1)
+	assert.True(t, len(tail) == 1 && tail[0] == "log line 1000", "Unexpected last line %q", tail)
+}
+
+func TestRunAndExpectReportTruncatedOutput(t *testing.T) {
+	sm := shell.NewShellManager(nil)
+	t.Cleanup(func() { _ = sm.TerminateAllShells() })
+	sm.SetScrollbackLimits(shell.ScrollbackLimits{MaxLines: 10})
+	session, err := sm.SpawnShell(false, "bash")
+	assert.NoError(t, err)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	// 1. Output that fits is not truncated.
+	assert.NoError(t, session.SendCommand("echo ready"))
+	match, err := session.Expect(ctx, regexp.MustCompile(`ready\n`))
+	assert.NoError(t, err)
+	assert.True(t, !match.Truncated, "Expected an untruncated match")
+	result, err := session.Run(ctx, "seq 1 5")
+	assert.NoError(t, err)
+	assert.True(t, !result.Truncated, "Expected untruncated output, got %q", result.Output)
+
+	// 2. A command that overflows the scrollback is flagged, keeping its tail.
+	result, err = session.Run(ctx, "seq 1 100")
+	assert.NoError(t, err)
+	assert.True(t, result.Truncated, "Expected the output to be marked truncated")
+	assert.True(t, strings.HasSuffix(result.Output, "99\n100\n"), "Unexpected output %q", result.Output)
+	_, err = session.SendCommandAndWait("seq 1 100")
+	assert.True(t, err != nil, "Expected SendCommandAndWait to report the truncation")
+
+	// 3. Expect's cursor was evicted too.
+	assert.NoError(t, session.SendCommand("echo done"))
+	match, err = session.Expect(ctx, regexp.MustCompile(`done\n`))
+	assert.NoError(t, err)
+	assert.True(t, match.Truncated, "Expected the match to be marked truncated")
+}
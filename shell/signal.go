@@ -0,0 +1,144 @@
+package shell
+
+import (
+	"fmt"
+	"os"
+	"syscall"
+	"unsafe"
+)
+
+// Signal delivers sig to whatever the shell is currently running. On a PTY
+// that is the terminal's foreground process group, so a command started from
+// the prompt is signaled rather than the shell itself. Pipe-based shells have
+// no terminal and the signal goes to the shell's process group.
+func (s *ShellSession) Signal(sig os.Signal) error {
+	proc, _ := s.process()
+	if proc == nil {
+		return fmt.Errorf("session %s has no process", s.ID)
+	}
+	if _, exited := s.ExitStatus(); exited {
+		return fmt.Errorf("failed to signal session %s: %w", s.ID, os.ErrProcessDone)
+	}
+
+	if s.pty != nil {
+		sysSig, ok := sig.(syscall.Signal)
+		if !ok {
+			return fmt.Errorf("unsupported signal %v for session %s", sig, s.ID)
+		}
+		pgrp, err := foregroundProcessGroup(s.pty)
+		if err != nil {
+			return fmt.Errorf("failed to find foreground process group of session %s: %w", s.ID, err)
+		}
+		if err := syscall.Kill(-pgrp, sysSig); err != nil {
+			return fmt.Errorf("failed to signal session %s: %w", s.ID, err)
+		}
+		return nil
+	}
+
+	pid := proc.Pid
+	if sysSig, ok := sig.(syscall.Signal); ok {
+		if pgid, err := syscall.Getpgid(pid); err == nil && pgid == pid {
+			if err := syscall.Kill(-pid, sysSig); err != nil {
+				return fmt.Errorf("failed to signal session %s: %w", s.ID, err)
+			}
+			return nil
+		}
+	}
+	if err := proc.Signal(sig); err != nil {
+		return fmt.Errorf("failed to signal session %s: %w", s.ID, err)
+	}
+	return nil
+}
+
+// Interrupt sends SIGINT, the equivalent of pressing Ctrl-C.
+func (s *ShellSession) Interrupt() error {
+	return s.Signal(syscall.SIGINT)
+}
+
+// Suspend sends SIGTSTP, the equivalent of pressing Ctrl-Z.
+func (s *ShellSession) Suspend() error {
+	return s.Signal(syscall.SIGTSTP)
+}
+
+// Resume sends SIGCONT to continue a suspended process.
+func (s *ShellSession) Resume() error {
+	return s.Signal(syscall.SIGCONT)
+}
+
+// SendControl sends the control character for key, so SendControl('c') is
+// Ctrl-C and SendControl('d') is Ctrl-D. On a PTY the byte is written to the
+// terminal and the line discipline turns it into a signal or EOF. Pipe-based
+// shells have no line discipline, so Ctrl-C, Ctrl-Z and Ctrl-\ are delivered
+// as signals and Ctrl-D closes stdin.
+func (s *ShellSession) SendControl(key byte) error {
+	ctrl, err := controlByte(key)
+	if err != nil {
+		return fmt.Errorf("session %s: %w", s.ID, err)
+	}
+	return s.sendControlByte(ctrl)
+}
+
+// sendControlByte delivers a control character, emulating the line
+// discipline on pipe-based shells.
+func (s *ShellSession) sendControlByte(ctrl byte) error {
+	_, stdin := s.process()
+	if stdin == nil {
+		return fmt.Errorf("session %s has no stdin", s.ID)
+	}
+
+	if s.pty == nil {
+		switch ctrl {
+		case 0x03:
+			return s.Signal(syscall.SIGINT)
+		case 0x1a:
+			return s.Signal(syscall.SIGTSTP)
+		case 0x1c:
+			return s.Signal(syscall.SIGQUIT)
+		case 0x04:
+			return stdin.Close()
+		}
+	}
+
+	return s.writeInput([]byte{ctrl})
+}
+
+// isSignalControl reports whether a terminal's line discipline turns ctrl
+// into a signal or EOF.
+func isSignalControl(ctrl byte) bool {
+	return ctrl == 0x03 || ctrl == 0x1a || ctrl == 0x1c || ctrl == 0x04
+}
+
+// controlByte maps a key such as 'c' or 'C' to its control character.
+func controlByte(key byte) (byte, error) {
+	switch {
+	case key >= 'a' && key <= 'z':
+		return key - 'a' + 1, nil
+	case key >= '@' && key <= '_':
+		return key & 0x1f, nil
+	case key == '?':
+		return 0x7f, nil
+	}
+	return 0, fmt.Errorf("no control character for key %q", key)
+}
+
+// foregroundProcessGroup returns the process group in the foreground of the
+// terminal whose master side is f.
+func foregroundProcessGroup(f *os.File) (int, error) {
+	conn, err := f.SyscallConn()
+	if err != nil {
+		return 0, err
+	}
+	var pgrp int32
+	var errno syscall.Errno
+	// Use the raw descriptor rather than f.Fd(), which would switch the PTY
+	// to blocking mode and break concurrent reads.
+	if err := conn.Control(func(fd uintptr) {
+		_, _, errno = syscall.Syscall(syscall.SYS_IOCTL, fd, uintptr(syscall.TIOCGPGRP), uintptr(unsafe.Pointer(&pgrp)))
+	}); err != nil {
+		return 0, err
+	}
+	if errno != 0 {
+		return 0, errno
+	}
+	return int(pgrp), nil
+}
@@ -0,0 +1,92 @@
+package shell_test
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/owen-6936/termplex/assert"
+	"github.com/owen-6936/termplex/shell"
+)
+
+func TestInterruptStopsForegroundCommandOnPTY(t *testing.T) {
+	sm := shell.NewShellManager(nil)
+	t.Cleanup(func() { _ = sm.TerminateAllShells() })
+
+	session, err := sm.SpawnShell(true, "bash", "--norc", "-i")
+	assert.NoError(t, err)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	// 1. Start a long-running command and wait until it is in the foreground.
+	assert.NoError(t, session.SendCommand("sleep 30; echo after-$((40 + 2))"))
+	_, err = session.Expect(ctx, regexp.MustCompile(`sleep 30`))
+	assert.NoError(t, err)
+	time.Sleep(200 * time.Millisecond)
+
+	// 2. Ctrl-C kills sleep, not the shell, and the rest of the line is abandoned.
+	assert.NoError(t, sm.Interrupt(session.ID))
+	result, err := session.Run(ctx, "echo still-here")
+	assert.NoError(t, err)
+	assert.Contains(t, result.Output, "still-here")
+	assert.True(t, !strings.Contains(session.Scrollback().String(), "after-42"), "Expected the interrupted command line to be abandoned")
+
+	// 3. SendControl writes through the line discipline just like a keypress.
+	assert.NoError(t, session.SendCommand("sleep 30"))
+	time.Sleep(200 * time.Millisecond)
+	assert.NoError(t, session.SendControl('c'))
+	result, err = session.Run(ctx, "echo back-again")
+	assert.NoError(t, err)
+	assert.Contains(t, result.Output, "back-again")
+}
+
+func TestSuspendAndResumePipeShell(t *testing.T) {
+	sm := shell.NewShellManager(nil)
+	t.Cleanup(func() { _ = sm.TerminateAllShells() })
+
+	session, err := sm.SpawnShell(false, "sleep", "30")
+	assert.NoError(t, err)
+
+	// 1. Suspend stops the process; the kernel reports it in state T.
+	assert.NoError(t, sm.Suspend(session.ID))
+	assert.True(t, waitForProcState(session.Cmd.Process.Pid, 'T'), "Expected the process to be stopped")
+
+	// 2. Resume lets it run again.
+	assert.NoError(t, sm.Resume(session.ID))
+	assert.True(t, waitForProcState(session.Cmd.Process.Pid, 'S'), "Expected the process to be running again")
+
+	// 3. Interrupt terminates it with SIGINT.
+	assert.NoError(t, session.Interrupt())
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	status, err := session.Wait(ctx)
+	assert.NoError(t, err)
+	assert.True(t, status.Signaled && status.Signal == syscall.SIGINT, "Expected SIGINT exit, got %+v", status)
+
+	// 4. Signaling an exited shell reports that the process is done.
+	err = session.Signal(syscall.SIGTERM)
+	assert.True(t, err != nil && strings.Contains(err.Error(), os.ErrProcessDone.Error()), "Expected ErrProcessDone, got %v", err)
+	assert.True(t, sm.Interrupt("missing") != nil, "Expected an error for an unknown shell")
+}
+
+// waitForProcState polls /proc until the process reaches the given state.
+func waitForProcState(pid int, state byte) bool {
+	deadline := time.Now().Add(2 * time.Second)
+	for time.Now().Before(deadline) {
+		stat, err := os.ReadFile(fmt.Sprintf("/proc/%d/stat", pid))
+		if err == nil {
+			// The state follows the parenthesized command name.
+			if i := strings.LastIndexByte(string(stat), ')'); i >= 0 && i+2 < len(stat) && stat[i+2] == state {
+				return true
+			}
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	return false
+}
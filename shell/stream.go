@@ -0,0 +1,135 @@
+package shell
+
+import (
+	"errors"
+	"fmt"
+	"io"
+)
+
+// Stream selects the output streams a sink receives.
+type Stream int
+
+const (
+	StreamStdout Stream = 1 << iota
+	StreamStderr
+	StreamBoth = StreamStdout | StreamStderr
+)
+
+// sinkEntry wraps a registered sink so it can be removed by identity.
+type sinkEntry struct {
+	w       io.Writer
+	streams Stream
+}
+
+// AddSink copies the shell's filtered output on the selected streams to w,
+// alongside its buffers and subscribers. A PTY merges everything the shell
+// prints into one stream, which every sink receives. A sink whose Write
+// fails is logged and removed. The returned function removes the sink.
+func (s *ShellSession) AddSink(w io.Writer, streams Stream) (remove func()) {
+	entry := &sinkEntry{w: w, streams: streams}
+	s.mu.Lock()
+	s.sinks = append(s.sinks, entry)
+	s.mu.Unlock()
+	return func() { s.removeSink(entry) }
+}
+
+// addSinks registers the sinks given in the spawn options.
+func (s *ShellSession) addSinks(opts SpawnOptions) {
+	if opts.Stdout != nil {
+		s.AddSink(opts.Stdout, StreamStdout)
+	}
+	if opts.Stderr != nil {
+		s.AddSink(opts.Stderr, StreamStderr)
+	}
+}
+
+// removeSink unregisters a sink.
+func (s *ShellSession) removeSink(entry *sinkEntry) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i, e := range s.sinks {
+		if e == entry {
+			s.sinks = append(s.sinks[:i:i], s.sinks[i+1:]...)
+			return
+		}
+	}
+}
+
+// writeSinks passes delivered output to the sinks that selected its stream.
+func (s *ShellSession) writeSinks(sinks []*sinkEntry, data []byte, isStderr bool) {
+	stream := StreamStdout
+	if isStderr {
+		stream = StreamStderr
+	}
+	for _, sink := range sinks {
+		if s.pty == nil && sink.streams&stream == 0 {
+			continue
+		}
+		if _, err := sink.w.Write(data); err != nil {
+			s.log().Warn("removing output sink after a failed write", "error", err)
+			s.removeSink(sink)
+		}
+	}
+}
+
+// StreamStdin copies r into the stdin of a shell on plain pipes in the
+// background, and closes stdin once r is exhausted, so the shell sees EOF.
+// A read error closes stdin too. Only one stream may feed a shell at a time.
+// When a supervised shell restarts, a stream still running feeds the new
+// process.
+func (s *ShellSession) StreamStdin(r io.Reader) error {
+	if s.pty != nil {
+		return fmt.Errorf("session %s is on a PTY; stdin can only be streamed to shells on pipes", s.ID)
+	}
+	s.mu.Lock()
+	if s.streaming {
+		s.mu.Unlock()
+		return fmt.Errorf("session %s is already streaming stdin", s.ID)
+	}
+	s.streaming = true
+	s.mu.Unlock()
+
+	go s.copyStdin(r)
+	return nil
+}
+
+// copyStdin feeds r to the shell through writeInput, so taps see the data.
+func (s *ShellSession) copyStdin(r io.Reader) {
+	defer func() {
+		s.mu.Lock()
+		s.streaming = false
+		s.mu.Unlock()
+	}()
+
+	buf := make([]byte, 32*1024)
+	for {
+		n, err := r.Read(buf)
+		if n > 0 {
+			if werr := s.writeInput(buf[:n]); werr != nil {
+				s.log().Warn("stopped streaming stdin", "error", werr)
+				return
+			}
+		}
+		if err != nil {
+			if !errors.Is(err, io.EOF) {
+				s.log().Warn("failed to read stdin stream", "error", err)
+			}
+			if err := s.CloseStdin(); err != nil {
+				s.log().Warn("failed to close stdin", "error", err)
+			}
+			return
+		}
+	}
+}
+
+// CloseStdin closes the stdin of a shell on plain pipes, so the shell reads
+// EOF, while its output keeps flowing. Nothing can be sent to the process
+// afterwards.
+func (s *ShellSession) CloseStdin() error {
+	_, stdin := s.process()
+	closer, ok := stdin.(interface{ CloseWrite() error })
+	if !ok {
+		return fmt.Errorf("session %s has no stdin of its own to close", s.ID)
+	}
+	return closer.CloseWrite()
+}
@@ -0,0 +1,113 @@
+package shell_test
+
+import (
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/owen-6936/termplex/assert"
+	"github.com/owen-6936/termplex/shell"
+)
+
+func TestStreamStdinPropagatesEOF(t *testing.T) {
+	sm := shell.NewShellManager(nil)
+	t.Cleanup(func() { _ = sm.TerminateAllShells() })
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	// 1. A reader given at spawn is fed to stdin, and its EOF ends cat.
+	var stdout syncBuffer
+	session, err := sm.SpawnShellWithOptions(shell.SpawnOptions{
+		Command: []string{"cat"},
+		Stdin:   strings.NewReader("first\nsecond\n"),
+		Stdout:  &stdout,
+	})
+	assert.NoError(t, err)
+	status, err := session.Wait(ctx)
+	assert.NoError(t, err)
+	assert.True(t, status.Code == 0, "Expected cat to exit cleanly, got %+v", status)
+	assert.True(t, waitFor(time.Second, func() bool { return stdout.String() == "first\nsecond\n" }), "Unexpected sink output %q", stdout.String())
+	assert.True(t, session.Scrollback().String() == "first\nsecond\n", "Expected the buffer to see the output too")
+
+	// 2. Data written by another goroutine is streamed as it arrives.
+	counter, err := sm.SpawnShell(false, "wc", "-l")
+	assert.NoError(t, err)
+	r, w := io.Pipe()
+	assert.NoError(t, sm.StreamStdin(counter.ID, r))
+	assert.True(t, counter.StreamStdin(strings.NewReader("")) != nil, "Expected a second stream to be refused")
+	go func() {
+		for range 3 {
+			_, _ = w.Write([]byte("line\n"))
+		}
+		_ = w.Close()
+	}()
+	_, err = counter.Wait(ctx)
+	assert.NoError(t, err)
+	assert.True(t, waitFor(time.Second, func() bool { return strings.TrimSpace(counter.Scrollback().String()) == "3" }), "Expected 3 lines, got %q", counter.Scrollback().String())
+
+	// 3. CloseStdin ends input sent line by line.
+	sorter, err := sm.SpawnShell(false, "sort")
+	assert.NoError(t, err)
+	assert.NoError(t, sorter.SendCommand("b"))
+	assert.NoError(t, sorter.SendCommand("a"))
+	assert.NoError(t, sm.CloseStdin(sorter.ID))
+	_, err = sorter.Wait(ctx)
+	assert.NoError(t, err)
+	assert.True(t, waitFor(time.Second, func() bool { return sorter.Scrollback().String() == "a\nb\n" }), "Unexpected output %q", sorter.Scrollback().String())
+
+	// 4. PTY shells have no stdin of their own to stream into.
+	_, err = sm.SpawnShellWithOptions(shell.SpawnOptions{Interactive: true, Command: []string{"cat"}, Stdin: strings.NewReader("x")})
+	assert.True(t, err != nil, "Expected an error for an interactive shell")
+}
+
+// failingWriter fails every write after the first.
+type failingWriter struct {
+	writes int
+}
+
+func (w *failingWriter) Write(p []byte) (int, error) {
+	w.writes++
+	if w.writes > 1 {
+		return 0, errors.New("sink is full")
+	}
+	return len(p), nil
+}
+
+func TestSinksReceiveSelectedStreams(t *testing.T) {
+	sm := shell.NewShellManager(nil)
+	t.Cleanup(func() { _ = sm.TerminateAllShells() })
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	// 1. Stdout and stderr sinks each see their own stream.
+	var stdout, stderr, both syncBuffer
+	session, err := sm.SpawnShellWithOptions(shell.SpawnOptions{
+		Command: []string{"sh", "-c", "read -r _; echo out; echo err >&2"},
+		Stdout:  &stdout,
+		Stderr:  &stderr,
+	})
+	assert.NoError(t, err)
+	_, err = sm.AddSink(session.ID, &both, shell.StreamBoth)
+	assert.NoError(t, err)
+	assert.NoError(t, session.SendCommand("go"))
+	_, err = session.Wait(ctx)
+	assert.NoError(t, err)
+	assert.True(t, waitFor(time.Second, func() bool { return stdout.String() == "out\n" && stderr.String() == "err\n" }),
+		"Unexpected sinks %q and %q", stdout.String(), stderr.String())
+	assert.Contains(t, both.String(), "out\n")
+	assert.Contains(t, both.String(), "err\n")
+
+	// 2. A sink that fails is dropped and the shell carries on.
+	failing := &failingWriter{}
+	echo, err := sm.SpawnShell(false, "cat")
+	assert.NoError(t, err)
+	echo.AddSink(failing, shell.StreamStdout)
+	for _, line := range []string{"one", "two", "three"} {
+		assert.NoError(t, echo.SendCommand(line))
+		assert.True(t, waitFor(time.Second, func() bool { return strings.Contains(echo.Scrollback().String(), line) }), "Expected %q in the output", line)
+	}
+	assert.True(t, failing.writes == 2, "Expected the sink to be removed after its failure, got %d writes", failing.writes)
+}
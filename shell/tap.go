@@ -0,0 +1,97 @@
+package shell
+
+import "fmt"
+
+// Tap receives a copy of a shell's terminal traffic as it happens, for
+// example to record it. Output has been through the session's filters, so
+// redacted secrets never reach a recording. Errors are logged and otherwise
+// ignored, so a failing tap never disturbs the shell.
+type Tap interface {
+	Output(data []byte) error       // Everything the shell prints.
+	Input(data []byte) error        // Everything written to the shell's stdin.
+	Resize(rows, cols uint16) error // Every change of the PTY's window size.
+}
+
+// tapEntry wraps a registered Tap so it can be removed by identity.
+type tapEntry struct {
+	tap Tap
+	raw bool // Receives output as read from the shell, before the filters.
+}
+
+// AddTap starts copying the shell's traffic to t. The returned function
+// removes the tap again.
+func (s *ShellSession) AddTap(t Tap) (remove func()) {
+	return s.addTap(&tapEntry{tap: t})
+}
+
+// addRawTap is like AddTap, but t gets the unfiltered bytes as they are
+// read, like the screen. A terminal attached to the shell needs every
+// escape sequence, with nothing held back or inserted.
+func (s *ShellSession) addRawTap(t Tap) (remove func()) {
+	return s.addTap(&tapEntry{tap: t, raw: true})
+}
+
+// addTap registers entry and returns the function that removes it.
+func (s *ShellSession) addTap(entry *tapEntry) (remove func()) {
+	s.mu.Lock()
+	s.taps = append(s.taps, entry)
+	s.mu.Unlock()
+
+	return func() {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		for i, e := range s.taps {
+			if e == entry {
+				s.taps = append(s.taps[:i:i], s.taps[i+1:]...)
+				return
+			}
+		}
+	}
+}
+
+// currentTaps returns a snapshot of the registered taps.
+func (s *ShellSession) currentTaps() []*tapEntry {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.taps
+}
+
+// tapOutput forwards printed output to every tap that takes it raw or,
+// with raw unset, filtered.
+func (s *ShellSession) tapOutput(data []byte, raw bool) {
+	for _, t := range s.currentTaps() {
+		if t.raw != raw {
+			continue
+		}
+		if err := t.tap.Output(data); err != nil {
+			s.log().Warn("tap failed to record output", "error", err)
+		}
+	}
+}
+
+// tapResize forwards a window size change to every tap.
+func (s *ShellSession) tapResize(rows, cols uint16) {
+	for _, t := range s.currentTaps() {
+		if err := t.tap.Resize(rows, cols); err != nil {
+			s.log().Warn("tap failed to record resize", "error", err)
+		}
+	}
+}
+
+// writeInput is the single path for bytes sent to the shell's stdin, so
+// taps see every keystroke and command.
+func (s *ShellSession) writeInput(p []byte) error {
+	_, stdin := s.process()
+	if stdin == nil {
+		return fmt.Errorf("session %s has no stdin", s.ID)
+	}
+	n, err := stdin.Write(p)
+	if n > 0 {
+		for _, t := range s.currentTaps() {
+			if err := t.tap.Input(p[:n]); err != nil {
+				s.log().Warn("tap failed to record input", "error", err)
+			}
+		}
+	}
+	return err
+}
@@ -1,13 +1,65 @@
 package shell
 
-import "regexp"
+import (
+	"log/slog"
+	"os"
+	"regexp"
+	"slices"
+	"strings"
+)
 
 var (
-	// ansiRegex is a regular expression to find and remove ANSI escape codes.
-	ansiRegex = regexp.MustCompile("[\u001B\u009B][[\\]()#;?]*.?[a-zA-Z0-9]")
+	// ansiRegex matches ANSI escape sequences: CSI sequences such as colors
+	// and cursor movement, OSC sequences such as window titles, string
+	// sequences, and two-byte escapes.
+	ansiRegex = regexp.MustCompile(`\x1b\[[0-?]*[ -/]*[@-~]|\x{9b}[0-?]*[ -/]*[@-~]|\x1b\][^\x07\x1b]*(?:\x07|\x1b\\)|\x1b[PX^_][^\x1b]*\x1b\\|\x1b[ -/]*[0-~]`)
 )
 
 // StripANSI removes all ANSI escape codes from a byte slice.
 func StripANSI(data []byte) []byte {
 	return ansiRegex.ReplaceAll(data, []byte{})
 }
+
+// environ builds the environment for a spawned process. It returns nil, which
+// makes exec inherit the caller's environment, when no overrides are set.
+func (opts SpawnOptions) environ() []string {
+	overrides := make(map[string]string, len(opts.Env)+2)
+	for k, v := range opts.Env {
+		overrides[k] = v
+	}
+	if opts.Term != "" {
+		overrides["TERM"] = opts.Term
+	}
+	if opts.Lang != "" {
+		overrides["LANG"] = opts.Lang
+	}
+	if len(overrides) == 0 {
+		return nil
+	}
+	return mergeEnv(os.Environ(), overrides)
+}
+
+// mergeEnv returns base with every key in overrides replaced or appended.
+// Overrides are appended in sorted order so the result is deterministic.
+func mergeEnv(base []string, overrides map[string]string) []string {
+	env := make([]string, 0, len(base)+len(overrides))
+	for _, kv := range base {
+		key, _, _ := strings.Cut(kv, "=")
+		if _, overridden := overrides[key]; !overridden {
+			env = append(env, kv)
+		}
+	}
+	keys := make([]string, 0, len(overrides))
+	for k := range overrides {
+		keys = append(keys, k)
+	}
+	slices.Sort(keys)
+	for _, k := range keys {
+		env = append(env, k+"="+overrides[k])
+	}
+	return env
+}
+
+// discardLogger is the default logger: termplex is silent unless the
+// embedding program asks for logs.
+var discardLogger = slog.New(slog.DiscardHandler)
@@ -2,11 +2,14 @@ package window
 
 import (
 	"errors"
-	"fmt"
+	"log/slog"
 	"time"
 
 	"github.com/google/uuid"
+	"github.com/owen-6936/termplex/cgroup"
+	"github.com/owen-6936/termplex/fanout"
 	"github.com/owen-6936/termplex/pane"
+	"github.com/owen-6936/termplex/shell"
 )
 
 // NewWindowManager creates a new window with optional tags and name.
@@ -17,6 +20,7 @@ func NewWindowManager(name string, tags map[string]string) *WindowManager {
 		CreatedAt: time.Now(),
 		Tags:      tags,
 		Panes:     make(map[string]*PaneManager),
+		logger:    slog.New(slog.DiscardHandler),
 	}
 }
 
@@ -28,11 +32,47 @@ func (wm *WindowManager) AddPane(name string) (string, error) {
 		return "", errors.New("pane ID collision")
 	}
 
-	wm.Panes[paneID] = pane.NewPaneManager(paneID, name)
-	fmt.Printf("🪞 Pane created: %s in window %s\n", paneID, wm.ID)
+	pm := pane.NewPaneManager(paneID, name)
+	if wm.Scrollback != nil {
+		pm.SetScrollbackLimits(*wm.Scrollback)
+	}
+	if wm.Limits != nil {
+		pm.SetResourceLimits(*wm.Limits)
+	}
+	if wm.Cgroup != nil {
+		pm.SetCgroup(wm.Cgroup)
+	}
+	if wm.Pool != nil {
+		pm.SetShellPool(wm.Pool)
+	}
+	if wm.Logs != nil {
+		if err := pm.EnableLogs(*wm.Logs); err != nil {
+			return "", err
+		}
+	}
+	pm.SetLogger(wm.logger)
+	paneOutput, _ := pm.Subscribe(fanout.Options{Policy: fanout.Block})
+	go wm.forwardPaneOutput(paneOutput)
+	wm.Panes[paneID] = pm
+	wm.logger.Info("pane created", "pane_id", paneID, "name", name)
 	return paneID, nil
 }
 
+// forwardPaneOutput relays a pane's output to the window's subscribers until
+// the pane terminates.
+func (wm *WindowManager) forwardPaneOutput(paneOutput <-chan pane.PaneOutput) {
+	for output := range paneOutput {
+		wm.output.Publish(output)
+	}
+}
+
+// Subscribe returns a new, independent stream of output from every pane in
+// the window, including panes added later. The channel is closed when the
+// subscription is cancelled or the window terminates.
+func (wm *WindowManager) Subscribe(opts fanout.Options) (<-chan pane.PaneOutput, *fanout.Subscription) {
+	return wm.output.Subscribe(opts)
+}
+
 // GetPane retrieves a pane by ID.
 func (wm *WindowManager) GetPane(paneID string) (*PaneManager, bool) {
 	pane, exists := wm.Panes[paneID]
@@ -50,8 +90,82 @@ func (wm *WindowManager) GetPaneByName(name string) (*PaneManager, bool) {
 	return nil, false
 }
 
-// TerminateWindow cleans up all panes in the window.
-func (wm *WindowManager) TerminateWindow() {
+// Resize pushes a terminal size down to every pane in the window.
+func (wm *WindowManager) Resize(rows, cols uint16) error {
+	var errs []error
+	for _, p := range wm.Panes {
+		if err := p.Resize(rows, cols); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// SetScrollbackLimits bounds the output retained by shells in every pane of
+// the window, including panes added later.
+func (wm *WindowManager) SetScrollbackLimits(limits shell.ScrollbackLimits) {
+	wm.Scrollback = &limits
+	for _, p := range wm.Panes {
+		p.SetScrollbackLimits(limits)
+	}
+}
+
+// SetResourceLimits sets the setrlimit limits of shells spawned in every pane
+// of the window from now on, including panes added later.
+func (wm *WindowManager) SetResourceLimits(limits shell.ResourceLimits) {
+	wm.Limits = &limits
+	for _, p := range wm.Panes {
+		p.SetResourceLimits(limits)
+	}
+}
+
+// SetCgroup runs shells spawned in every pane of the window from now on in
+// group, including panes added later.
+func (wm *WindowManager) SetCgroup(group *cgroup.Group) {
+	wm.Cgroup = group
+	for _, p := range wm.Panes {
+		p.SetCgroup(group)
+	}
+}
+
+// SetShellPool makes every pane of the window, including panes added later,
+// take new shells from pool where it can.
+func (wm *WindowManager) SetShellPool(pool *shell.ShellPool) {
+	wm.Pool = pool
+	for _, p := range wm.Panes {
+		p.SetShellPool(pool)
+	}
+}
+
+// SetLogOptions persists the output of every pane of the window to disk,
+// including panes added later.
+func (wm *WindowManager) SetLogOptions(opts pane.LogOptions) error {
+	wm.Logs = &opts
+	var errs []error
+	for _, p := range wm.Panes {
+		if err := p.EnableLogs(opts); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// SetLogger sets the structured logger for the window and every pane in it,
+// including panes added later. Records carry a window_id attribute; a nil
+// logger discards them.
+func (wm *WindowManager) SetLogger(logger *slog.Logger) {
+	if logger == nil {
+		logger = slog.New(slog.DiscardHandler)
+	}
+	wm.logger = logger.With("window_id", wm.ID)
+	for _, pm := range wm.Panes {
+		pm.SetLogger(wm.logger)
+	}
+}
+
+// TerminateWindow cleans up all panes in the window, reporting any processes
+// their shells left behind.
+func (wm *WindowManager) TerminateWindow() error {
 	// Create a slice of pane IDs to iterate over, as deleting from a map
 	// while iterating over it is not safe.
 	var paneIDs []string
@@ -59,10 +173,15 @@ func (wm *WindowManager) TerminateWindow() {
 		paneIDs = append(paneIDs, id)
 	}
 
+	var errs []error
 	for _, paneID := range paneIDs {
-		wm.Panes[paneID].TerminatePane(2 * time.Second)
-		fmt.Printf("🧹 Pane terminated: %s in window %s\n", paneID, wm.ID)
+		if err := wm.Panes[paneID].TerminatePane(2 * time.Second); err != nil {
+			errs = append(errs, err)
+		}
+		wm.logger.Info("pane terminated", "pane_id", paneID)
 		delete(wm.Panes, paneID)
 	}
-	fmt.Printf("🧹 Window terminated: %s\n", wm.ID)
+	wm.output.Close()
+	wm.logger.Info("window terminated")
+	return errors.Join(errs...)
 }
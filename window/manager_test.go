@@ -3,6 +3,7 @@ package window_test
 import (
 	"testing"
 
+	"github.com/owen-6936/termplex/shell"
 	"github.com/owen-6936/termplex/window"
 )
 
@@ -47,3 +48,28 @@ func TestWindowLifecycle(t *testing.T) {
 		t.Errorf("Expected 0 panes after TerminateWindow, but found %d", len(wm.Panes))
 	}
 }
+
+func TestWindowScrollbackLimitsReachNewPanes(t *testing.T) {
+	wm := window.NewWindowManager("bounded-window", nil)
+	t.Cleanup(func() { _ = wm.TerminateWindow() })
+
+	existingID, err := wm.AddPane("existing")
+	if err != nil {
+		t.Fatalf("Failed to add pane: %v", err)
+	}
+
+	// Limits apply to panes that already exist and to panes added later.
+	wm.SetScrollbackLimits(shell.ScrollbackLimits{MaxLines: 500})
+	laterID, err := wm.AddPane("later")
+	if err != nil {
+		t.Fatalf("Failed to add pane: %v", err)
+	}
+
+	for _, id := range []string{existingID, laterID} {
+		p, _ := wm.GetPane(id)
+		limits := p.Shells.ScrollbackLimits()
+		if limits == nil || limits.MaxLines != 500 {
+			t.Errorf("Expected pane %s to retain 500 lines, got %+v", id, limits)
+		}
+	}
+}
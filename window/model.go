@@ -1,9 +1,13 @@
 package window
 
 import (
+	"log/slog"
 	"time"
 
+	"github.com/owen-6936/termplex/cgroup"
+	"github.com/owen-6936/termplex/fanout"
 	"github.com/owen-6936/termplex/pane"
+	"github.com/owen-6936/termplex/shell"
 )
 
 type PaneManager = pane.PaneManager
@@ -16,4 +20,19 @@ type WindowManager struct {
 	CreatedAt time.Time               // Timestamp of window creation
 	Tags      map[string]string       // Metadata (e.g. project, owner, type)
 	Panes     map[string]*PaneManager // Map of pane IDs to their managers
+	// Scrollback bounds the output retained by shells in every pane of this
+	// window; nil leaves each pane at its own limits.
+	Scrollback *shell.ScrollbackLimits
+	// Limits and Cgroup apply to shells spawned in every pane of this window,
+	// including panes added later; nil leaves each pane at its own settings.
+	Limits *shell.ResourceLimits
+	Cgroup *cgroup.Group
+	// Pool supplies pre-started shells to every pane of this window; nil
+	// always spawns.
+	Pool *shell.ShellPool
+	// Logs persists the output of every pane of this window, including
+	// panes added later; nil leaves logging to each pane.
+	Logs   *pane.LogOptions
+	logger *slog.Logger // Structured logger carrying window_id; discards by default.
+	output fanout.Hub[pane.PaneOutput]
 }